@@ -0,0 +1,141 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+// Package clipboard reads images from the system clipboard
+// with the clipboard tools of the platform: wl-paste
+// (wl-clipboard) on Wayland, xclip on X11, osascript on macOS
+// and PowerShell on Windows.
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var (
+	// ErrNoImage is returned when the clipboard doesn't hold
+	// an image (e.g. it holds text).
+	ErrNoImage = errors.New("no image on the clipboard")
+	// ErrUnsupported is returned on platforms whose clipboard
+	// can't be read.
+	ErrUnsupported = errors.New("reading the clipboard is not supported on this platform")
+)
+
+// ReadImage returns the encoded image (e.g. PNG) held by the
+// clipboard.
+func ReadImage() ([]byte, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return readMac()
+	case "windows":
+		return readWindows()
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		switch {
+		case os.Getenv("WAYLAND_DISPLAY") != "":
+			return readTool("wl-paste", []string{"--list-types"}, func(t string) []string {
+				return []string{"--no-newline", "--type", t}
+			})
+		case os.Getenv("DISPLAY") != "":
+			return readTool("xclip", []string{"-selection", "clipboard", "-t", "TARGETS", "-o"}, func(t string) []string {
+				return []string{"-selection", "clipboard", "-t", t, "-o"}
+			})
+		}
+		return nil, errors.New("no display to read the clipboard from")
+	}
+	return nil, ErrUnsupported
+}
+
+// readTool lists the types of the clipboard content with a
+// tool, and reads the content in the preferred image type.
+func readTool(tool string, listArgs []string, readArgs func(mimeType string) []string) ([]byte, error) {
+	types, err := run(tool, listArgs...)
+	if err != nil {
+		return nil, err
+	}
+	t := imageType(strings.Fields(string(types)))
+	if t == "" {
+		return nil, ErrNoImage
+	}
+	return run(tool, readArgs(t)...)
+}
+
+// imageType returns the preferred image type among the MIME
+// types of the clipboard content, or "" if none is an image.
+// PNG is preferred as screenshots are copied losslessly.
+func imageType(types []string) string {
+	preferred := ""
+	for _, t := range types {
+		if t == "image/png" {
+			return t
+		}
+		if strings.HasPrefix(t, "image/") && preferred == "" {
+			preferred = t
+		}
+	}
+	return preferred
+}
+
+// readMac reads the clipboard as PNG with AppleScript, which
+// prints it as «data PNGf<hex>».
+func readMac() ([]byte, error) {
+	out, err := run("osascript", "-e", "get the clipboard as «class PNGf»")
+	if err != nil {
+		if strings.Contains(err.Error(), "-1700") { //errAECoercionFail: no PNG on the clipboard
+			return nil, ErrNoImage
+		}
+		return nil, err
+	}
+	return parseAppleScriptData(out)
+}
+
+// parseAppleScriptData decodes a «data PNGf<hex>» value.
+func parseAppleScriptData(out []byte) ([]byte, error) {
+	s := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(s, "«data PNGf") || !strings.HasSuffix(s, "»") {
+		return nil, ErrNoImage
+	}
+	return hex.DecodeString(strings.TrimSuffix(strings.TrimPrefix(s, "«data PNGf"), "»"))
+}
+
+// readWindows reads the clipboard as PNG with PowerShell,
+// which prints it in base64 (or nothing if there is no image).
+func readWindows() ([]byte, error) {
+	script := "Add-Type -AssemblyName System.Windows.Forms,System.Drawing; " +
+		"$i = [Windows.Forms.Clipboard]::GetImage(); " +
+		"if ($i) { $m = New-Object IO.MemoryStream; $i.Save($m, [Drawing.Imaging.ImageFormat]::Png); " +
+		"[Convert]::ToBase64String($m.ToArray()) }"
+	out, err := run("powershell", "-NoProfile", "-STA", "-Command", script)
+	if err != nil {
+		return nil, err
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, ErrNoImage
+	}
+	return base64.StdEncoding.DecodeString(string(out))
+}
+
+// run runs a tool and returns its output, with its error
+// messages in the error.
+func run(tool string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(tool, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%s is needed to read the clipboard: %v", tool, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %v %s", tool, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package clipboard
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestImageType(t *testing.T) {
+	for _, tc := range []struct {
+		types    []string
+		expected string
+	}{
+		{[]string{"TARGETS", "image/bmp", "image/png", "text/plain"}, "image/png"},
+		{[]string{"image/jpeg", "image/bmp"}, "image/jpeg"},
+		{[]string{"text/plain", "UTF8_STRING"}, ""},
+		{nil, ""},
+	} {
+		if got := imageType(tc.types); got != tc.expected {
+			t.Errorf("%v: expected %q, got %q", tc.types, tc.expected, got)
+		}
+	}
+}
+
+func TestParseAppleScriptData(t *testing.T) {
+	b, err := parseAppleScriptData([]byte("«data PNGf89504E470D0A1A0A»\n"))
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if !bytes.Equal(b, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Errorf("expected the PNG signature, got %q", b)
+	}
+	if _, err := parseAppleScriptData([]byte("some text")); err != ErrNoImage {
+		t.Errorf("expected %v, got %v", ErrNoImage, err)
+	}
+}
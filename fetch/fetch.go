@@ -0,0 +1,142 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+// Package fetch downloads images over HTTP(S), retrying
+// transient failures and optionally caching the images on
+// disk, revalidated with If-Modified-Since.
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBytes is the default maximum size of the
+// downloads (see Options.MaxBytes), the same as the one of the
+// images read into memory by viz.
+const DefaultMaxBytes = 256 << 20
+
+// Options configure Get.
+type Options struct {
+	// Number of retries after the first failed attempt, on network errors and on 5xx and 429 responses.
+	Retries int
+	// Delay before the first retry, doubled before each subsequent one.
+	Backoff time.Duration
+	// Directory caching the downloads (none if empty). A cached image is only downloaded again if the
+	// server reports it was modified since.
+	CacheDir string
+	// Client making the requests (http.DefaultClient if nil).
+	Client *http.Client
+	// Maximum size of the downloads, larger ones failing. Defaults to DefaultMaxBytes if zero.
+	MaxBytes int64
+}
+
+// IsURL reports whether name is an HTTP(S) URL rather than a
+// file name.
+func IsURL(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Get returns the body of the resource at url.
+func Get(url string, opts Options) ([]byte, error) {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	cached := ""
+	var modified time.Time
+	if opts.CacheDir != "" {
+		sum := sha256.Sum256([]byte(url))
+		cached = filepath.Join(opts.CacheDir, hex.EncodeToString(sum[:]))
+		if fi, err := os.Stat(cached); err == nil {
+			modified = fi.ModTime()
+		}
+	}
+
+	limit := opts.MaxBytes
+	if limit <= 0 {
+		limit = DefaultMaxBytes
+	}
+	backoff := opts.Backoff
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var b []byte
+		var retry bool
+		b, retry, err = get(client, url, cached, modified, limit)
+		if err == nil {
+			return b, nil
+		}
+		if !retry {
+			return nil, fmt.Errorf("fetching %s: %v", url, err)
+		}
+	}
+	return nil, fmt.Errorf("fetching %s: giving up after %d attempts: %v", url, opts.Retries+1, err)
+}
+
+// get makes one attempt at fetching url, revalidating the
+// cached file if it has a modification time, and reports
+// whether a failure is transient. Bodies larger than limit
+// bytes fail.
+func get(client *http.Client, url, cached string, modified time.Time, limit int64) (b []byte, retry bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if !modified.IsZero() {
+		req.Header.Set("If-Modified-Since", modified.UTC().Format(http.TimeFormat))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && !modified.IsZero():
+		b, err = os.ReadFile(cached)
+		return b, false, err
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, true, fmt.Errorf("server responded %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("server responded %s", resp.Status)
+	}
+	b, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, true, err //connection dropped mid-body
+	}
+	if int64(len(b)) > limit {
+		return nil, false, fmt.Errorf("image is larger than the maximum of %v bytes", limit)
+	}
+	if cached != "" {
+		store(cached, b, resp.Header.Get("Last-Modified"))
+	}
+	return b, false, nil
+}
+
+// store caches b, dated with the Last-Modified time of the
+// response (or now) to revalidate it. Failing to cache isn't
+// an error: the image was fetched.
+func store(cached string, b []byte, lastModified string) {
+	if os.MkdirAll(filepath.Dir(cached), 0755) != nil || os.WriteFile(cached, b, 0644) != nil {
+		return
+	}
+	t, err := http.ParseTime(lastModified)
+	if err != nil {
+		t = time.Now()
+	}
+	os.Chtimes(cached, t, t)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetRetries(t *testing.T) {
+	failures := 2
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("image"))
+	}))
+	defer srv.Close()
+
+	b, err := Get(srv.URL, Options{Retries: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if string(b) != "image" || attempts != 3 {
+		t.Errorf("expected the image after 3 attempts, got %q after %v", b, attempts)
+	}
+
+	attempts, failures = 0, 5
+	_, err = Get(srv.URL, Options{Retries: 2, Backoff: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "giving up after 3 attempts") || attempts != 3 {
+		t.Errorf("expected giving up after 3 attempts, got %v after %v", err, attempts)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	if _, err := Get(srv.URL, Options{Retries: 3, Backoff: time.Millisecond}); err == nil || attempts != 1 {
+		t.Errorf("expected an error without retrying, got %v after %v attempts", err, attempts)
+	}
+}
+
+func TestGetMaxBytes(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Write([]byte("image"))
+	}))
+	defer srv.Close()
+
+	if _, err := Get(srv.URL, Options{Retries: 3, MaxBytes: 4}); err == nil || !strings.Contains(err.Error(), "maximum of 4 bytes") || attempts != 1 {
+		t.Errorf("expected an error without retrying, got %v after %v attempts", err, attempts)
+	}
+	if b, err := Get(srv.URL, Options{MaxBytes: 5}); err != nil || string(b) != "image" {
+		t.Errorf("expected the image, got %q, %v", b, err)
+	}
+}
+
+func TestGetCache(t *testing.T) {
+	lastModified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var since []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since = append(since, r.Header.Get("If-Modified-Since"))
+		if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Write([]byte("image"))
+	}))
+	defer srv.Close()
+
+	opts := Options{CacheDir: t.TempDir()}
+	for i := 0; i < 2; i++ {
+		b, err := Get(srv.URL, opts)
+		if err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if string(b) != "image" {
+			t.Errorf("%v: expected the image, got %q", i, b)
+		}
+	}
+	if len(since) != 2 || since[0] != "" || since[1] != lastModified.Format(http.TimeFormat) {
+		t.Errorf("expected revalidating the cached image, got If-Modified-Since %q", since)
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"https://example.com/a.png": true,
+		"HTTP://example.com/a.png":  true,
+		"a.png":                     false,
+		"http.png":                  false,
+	} {
+		if got := IsURL(name); got != expected {
+			t.Errorf("%q: expected %v, got %v", name, expected, got)
+		}
+	}
+}
@@ -6,11 +6,27 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"log"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/codeliveroil/img/clipboard"
+	"github.com/codeliveroil/img/fetch"
+	"github.com/codeliveroil/img/terminal"
 	"github.com/codeliveroil/img/viz"
 	"github.com/codeliveroil/niceflags"
 )
@@ -20,12 +36,12 @@ func main() {
 	flags := niceflags.NewFlags(
 		args[0],
 		"Image viewer for Linux terminal emulators",
-		"Supports PNG, JPEG and GIF.\n"+
+		"Supports PNG, JPEG, GIF, WebP, SVG and Netpbm (PBM, PGM, PPM) files and HTTP(S) URLs, and replays ANSI art (.ans) as is.\n"+
 			"Images can be rendered on screen (default) or exported to a shell script to be "+
 			"rendered later (e.g. to display a logo during SSH login).\n"+
-			"GIFs are animated and restricted to a 40 character width by default.\n"+
+			"GIFs and animated WebPs are animated and restricted to a 40 character width by default.\n"+
 			"To obtain best quality rendering, try reducing the font size of the terminal.",
-		"[options] file",
+		"[options] file (- for stdin)",
 		"help",
 		false,
 	)
@@ -33,14 +49,113 @@ func main() {
 		"car.png",
 		"logo.gif",
 		"-l 2 wheel.gif",
+		"-l auto wheel.gif",
+		"-t -o logo.sh logo.gif",
+		"-show gallery.json",
+		"-pattern palette",
 	}
 
 	userWidth := flags.Int("w", 0, "Use specified `width` instead of auto-computing it.")
-	exportFilename := flags.String("o", "", "Export image as a shell script to specified `file`.")
-	loopCount := flags.Int("l", 1, "Specify the `num`ber of times the GIF should be looped or set to 0 to render the first frame only.")
+	userHeight := flags.Int("H", 0, "Use specified `height` in pixels (two per line) instead of auto-computing it. "+
+		"The image is stretched if the width is specified too, unless -k is set.")
+	keepAspect := flags.Bool("k", false, "Keep the aspect ratio when both -w and -H are specified, fitting the image within them.")
+	exportFilename := flags.String("o", "", "Export image as a shell script to specified `file`, "+
+		"or as a PNG, a GIF at the rendered resolution, an asciinema recording, ANSI art (replayed by passing the .ans file to img) "+
+		"or the palette indices of the frames in JSON or binary (see -raw) if the name ends with .png, .gif, .cast, .ans, .json "+
+		"or .idx respectively.")
+	raw := flags.Bool("raw", false, "Write the palette indices of the frames to the output (stdout, or see -fd) in a compact binary format instead of drawing them, "+
+		"for programs doing their own rendering (see viz.EncodeIndices for the layout).")
+	metadata := flags.Bool("m", false, "Record the source file and render parameters in exported PNGs.")
+	animate := flags.Bool("a", false, "Animate GIFs even when the output is not a terminal (e.g. redirected to a file).")
+	fromClipboard := flags.Bool("clipboard", false, "Render the image on the clipboard instead of an image file (e.g. a copied screenshot).")
+	retries := flags.Int("retries", 3, "Retry fetching an image URL this many `times` on network errors and server errors.")
+	backoff := flags.Int("backoff", 500, "Wait this many `milliseconds` before retrying to fetch an image URL, doubling the wait for each retry.")
+	cacheURL := flags.Bool("cache", false, "Cache fetched image URLs on disk, only downloading them again when the server reports them modified.")
+	diff := flags.String("diff", "", "Render the difference between the image and the specified `file` as a heatmap (magma unless -c is set), "+
+		"to spot the changes between e.g. two screenshots. Images of different sizes are compared at the smaller width and height.")
+	pattern := flags.String("pattern", "", "Render a test `pattern` (bars, palette or gradient) instead of an image file, to check the colors of the terminal.")
+	identify := flags.Bool("identify", false, "Print the width, height, format and number of frames of the image and exit, without rendering it.")
+	slideshow := flags.Bool("show", false, "Treat the file as a JSON slideshow manifest listing images with their durations, captions and transitions, "+
+		`e.g. {"slides": [{"file": "car.png", "duration": 2, "caption": "The car", "transition": "replace"}]}.`)
+	tee := flags.Bool("t", false, "Render the image on screen as well when exporting with -o.")
+	loop := flags.String("l", "1", "Specify the `num`ber of times the GIF should be looped or set to 0 to render the first frame only. "+
+		"Set to 'forever' to loop until interrupted or to 'auto' to use the loop count recorded in the GIF.")
+	reverse := flags.Bool("r", false, "Play GIFs in reverse.")
 	delayMultiplier := flags.Float64("s", 1.0, "Specify a multiplier to change the `speed` of animation. "+
 		"Larger the multiplier, slower the speed of animation. "+
 		"For example, 2 decreases the speed to 50% and 0.5 increases the speed to 200%.")
+	minDelay := flags.Int("mindelay", 0, "Display each frame for at least the specified number of `ms` "+
+		"(e.g. 100 as browsers do for GIFs with 0ms delays, which otherwise play as a blur).")
+	maxDelay := flags.Int("maxdelay", 0, "Display each frame for at most the specified number of `ms`, to shorten long pauses.")
+	maxFPS := flags.Float64("fps", 0, "Play animations at no more than the specified number of `frames` per second, to save CPU.")
+	lines := flags.Int("n", 0, "Scale the image to the specified `num`ber of lines, within the terminal width.")
+	padMultiple := flags.Int("pad", 0, "Pad the image on the right so its width in columns is a multiple of `num` (e.g. 2 for an even width).")
+	fillPercent := flags.Float64("p", 100, "Fill the specified `percent`age of the terminal width and height.")
+	usedLines := flags.Int("used", 0, "Fit the image below the specified number of `lines` already used (e.g. by the banners of an MOTD).")
+	below := flags.Bool("below", false, "Fit the image below the cursor, querying its position from the terminal.")
+	mode := flags.String("mode", "halfblocks", "Draw pixels with half blocks, sextants, shade characters or Braille dots "+
+		"(`mode`: halfblocks, sextants, shades, braille). "+
+		"Sextants have a higher resolution but need a font that supports Unicode 13 block characters. Braille has the highest "+
+		"resolution but draws all the dots in one color.")
+	fullBlocks := flags.Bool("fb", false, "Draw full blocks in the foreground color only, for terminals where background colors "+
+		"don't fill the cells properly (halves the vertical resolution).")
+	colorShades := flags.Bool("cs", false, "Color the shade characters of the shades mode.")
+	dots := flags.Float64("dots", 0, "Draw the pixels of the braille mode brighter than the `threshold` (0-1) as dots.")
+	dotColor := flags.String("dotcolor", "", "Draw the dots of the braille mode in the comma separated `rgb` color (e.g. 0,255,0).")
+	ascii := flags.Bool("ascii", false, "Draw the shades mode with ASCII characters, for terminals without Unicode support.")
+	colormap := flags.String("c", "", "Colorize the image by mapping its luminance through a `colormap` (viridis, magma or jet).")
+	gain := flags.String("gain", "", "Multiply the red, green and blue channels by the comma separated `factors` (e.g. 1.1,1,0.9).")
+	overlay := flags.String("overlay", "", "Blend every pixel toward the comma separated `rgb` color (e.g. 0,0,0 to dim the image).")
+	overlayAlpha := flags.Float64("overlayalpha", 0.5, "Blend the pixels toward the -overlay color by this `factor` (0-1).")
+	offset := flags.String("offset", "", "Add the comma separated `values` (-255 to 255) to the red, green and blue channels.")
+	resample := flags.String("resample", "auto", "Scale the image with the specified `filter` (auto, nearest, bilinear, bicubic, lanczos, box); "+
+		"auto picks bilinear for animations with many frames and lanczos otherwise; box averages pixels, for faithful thumbnails of screenshots.")
+	maxColors := flags.Int("colors", 0, "Reduce the image to at most the specified `num`ber of colors, chosen from its own with median-cut.")
+	grayLevels := flags.Int("gray", 0, "Render the image in the specified `num`ber of gray levels (e.g. 4 for an e-ink look); combine with -dither bayer4.")
+	preview := flags.Bool("preview", false, "Sample the image instead of resizing it, for an instant low-fidelity preview of huge images.")
+	decimation := flags.Int("decimate", 0, "Keep only every `n`th pixel of every nth row before scaling, for a faster and coarser render "+
+		"of enormous images on weak hardware.")
+	dither := flags.String("dither", "none", "Reduce banding in gradients by dithering the colors (`mode`: none, floydsteinberg, bayer2, bayer4, bayer8).")
+	compact := flags.Bool("compact", false, "Draw runs of identical characters with one escape sequence each and skip over the main color once filled in, for smaller output on flat images (e.g. logos).")
+	mirror := flags.String("mirror", "none", "Draw the image mirrored (`symmetry`: none, horizontal, vertical, quad).")
+	reset := flags.String("reset", "cells", "Reset the colors after each character, or only at the end of each line or frame "+
+		"for smaller output (`mode`: cells, lines, frames).")
+	redraw := flags.String("redraw", "lineup", "Move the cursor back over animations by moving it up, restoring its saved position, "+
+		"or clearing the screen, for terminals or multiplexers where moving it up glitches (`mode`: lineup, save, clear).")
+	transparent := flags.Bool("tr", false, "Leave the transparent pixels of the image undrawn, showing the terminal content underneath.")
+	alphaThreshold := flags.Float64("alpha", 0.5, "Opacity (0-1) below which pixels are transparent with -tr, or drawn as spaces with -mode shades.")
+	key := flags.String("key", "", "Draw the pixels close to the comma separated red, green and blue `color` (e.g. 0,255,0) as transparent.")
+	tolerance := flags.Float64("tol", 32, "Maximum distance between the red, green and blue values of a pixel and the -key color for it to be transparent.")
+	caption := flags.String("caption", "", "Print the `text` below the image, wrapped to its width.")
+	captionAlign := flags.String("ca", "left", "Align the caption with the left edge of the image or center it (`alignment`: left, center).")
+	reveal := flags.String("reveal", "none", "Reveal still images incrementally, line by line or column by column (`direction`: none, rows, columns).")
+	revealDelay := flags.Int("rd", 20, "Wait the specified `ms` between the lines or columns revealed with -reveal.")
+	ease := flags.String("ease", "linear", "Pace the lines or columns revealed with -reveal along an easing `curve` (linear, in, out, inout), "+
+		"taking as long as with -rd in total.")
+	theme := flags.Bool("theme", false, "Query the 16 system colors of the terminal's theme and quantize to them, so the image matches the color scheme.")
+	colorMode := flags.String("colormode", "all", "Map pixels to all the 256 colors, all but the 16 system colors (which terminal themes "+
+		"often redefine), or the 16 system colors only, for terminals that don't support 256 colors (`mode`: all, nosystem, basic).")
+	excludeSystemColors := flags.Bool("x", false, "Exclude the 16 system colors, which are often redefined by terminal themes "+
+		"(same as -colormode nosystem).")
+	forceColors := flags.Bool("256", false, "Use 256 colors even if $TERM says the terminal doesn't support them "+
+		"(otherwise img falls back to 16 colors or uncolored ASCII characters).")
+	trueColor := flags.Bool("truecolor", false, "Draw with 24-bit colors even if $COLORTERM doesn't say the terminal supports them.")
+	fullHeight := flags.Bool("f", false, "Use the full terminal height instead of leaving a line for the shell prompt (e.g. when piping the output).")
+	scroll := flags.Bool("scroll", false, "Render the image at its original size and scroll it with the arrow keys (q to quit).")
+	disposal := flags.String("disposal", "", "Advanced: dispose of all the frames of GIFs with the specified `method` (none, background or previous) "+
+		"instead of the declared ones, for animations that show ghosts or flicker.")
+	page := flags.Bool("page", false, "Render the image at the terminal width and page through it a screenful at a time (space, b, q to quit).")
+	skipFrames := flags.Bool("skip", false, "Skip frames of animations if the terminal can't keep pace with them.")
+	fd := flags.Int("fd", 1, "Render the image to the specified file descriptor `num`ber (e.g. 3 to keep the image apart from logs).")
+	frameCounter := flags.Bool("counter", false, "Overlay the frame number and the elapsed time on animations, to check their timing.")
+	trim := flags.Float64("trim", -1, "Crop the borders of uniform color, whose pixels are within the `tolerance` (e.g. 0 or 10) in 8-bit RGBA.")
+	bottomUp := flags.Bool("bottomup", false, "Draw the lines of the image from the bottom up, for displays anchored to the bottom of the terminal.")
+	firstFrame := flags.Bool("first", false, "Draw the first frame of animations as soon as it is ready, before preparing the others "+
+		"(e.g. for large GIFs over slow SSH connections).")
+	quiet := flags.Bool("q", false, "Don't draw a spinner on stderr while a large or animated image is being prepared.")
+	bell := flags.Bool("bell", false, "Ring the terminal bell once the image is drawn, e.g. when a finite animation finishes playing.")
+	stats := flags.Bool("stats", false, "Print timing and performance statistics (format, decode and scale times, output size, frame rate) to stderr after rendering.")
+	debug := flags.Bool("d", false, "Print diagnostic messages, such as warnings about slow terminals, to stderr.")
 	version := flags.Bool("v", false, "Display version.")
 
 	check(flags.Parse(args[1:]))
@@ -56,33 +171,455 @@ func main() {
 		os.Exit(0)
 	}
 	filename := args[argc-1]
-	if filename == "" {
+	if *pattern != "" {
+		filename = "" //the last argument is the pattern
+	} else if *fromClipboard {
+		filename = "clipboard"
+	} else if filename == "" {
 		niceflags.PrintErr("image file not specified.\n")
 		flags.Usage()
 		os.Exit(1)
 	}
 
+	loopCount, err := parseLoopCount(*loop)
+	check(err)
+
+	if *identify {
+		info, err := viz.Identify(filename)
+		check(err)
+		fmt.Println(info)
+		return
+	}
+
 	//Render/Export image
 	img := viz.Image{
 		Filename:        filename,
 		ExportFilename:  *exportFilename,
-		LoopCount:       *loopCount,
+		LoopCount:       loopCount,
+		Reverse:         *reverse,
 		DelayMultiplier: *delayMultiplier,
-		UserWidth:       *userWidth,
+		MaxFPS:          *maxFPS,
+		MinDelay:        *minDelay,
+		MaxDelay:        *maxDelay,
+		Size:            viz.Size{Width: *userWidth, Height: *userHeight, Fit: *keepAspect},
+		FullHeight:      *fullHeight,
+		FillPercent:     *fillPercent,
+		UsedLines:       *usedLines,
+		PadMultiple:     *padMultiple,
+		Lines:           *lines,
+		ColorShades:     *colorShades,
+		ASCII:           *ascii,
+		ForceColors:     *forceColors || *raw || (*exportFilename != "" && !*tee), //exports are drawn elsewhere
+		Transparent:     *transparent,
+		AlphaThreshold:  *alphaThreshold,
+		CompactRuns:     *compact,
+		Preview:         *preview,
+		Decimate:        *decimation,
+		MaxColors:       *maxColors,
+		GrayLevels:      *grayLevels,
+		FrameCounter:    *frameCounter,
+		Bell:            *bell,
+		Spinner:         !*quiet,
+		TrueColor:       *trueColor,
+		BottomUp:        *bottomUp,
+		Caption:         *caption,
+		NoShrink:        *scroll,
+		RevealDelay:     time.Duration(*revealDelay) * time.Millisecond,
+	}
+	var ok bool
+	if img.Mode, ok = viz.RenderModes[*mode]; !ok {
+		niceflags.PrintErr("unknown render mode %q.\n", *mode)
+		os.Exit(1)
+	}
+	if img.Reveal, ok = viz.RevealDirections[*reveal]; !ok {
+		niceflags.PrintErr("unknown reveal direction %q.\n", *reveal)
+		os.Exit(1)
+	}
+	if e, ok := viz.Easings[*ease]; !ok {
+		niceflags.PrintErr("unknown easing curve %q.\n", *ease)
+		os.Exit(1)
+	} else if *ease != "linear" {
+		img.RevealDelays = viz.EaseDelays(e, img.RevealDelay)
+	}
+	if img.CaptionAlign, ok = viz.Aligns[*captionAlign]; !ok {
+		niceflags.PrintErr("unknown caption alignment %q.\n", *captionAlign)
+		os.Exit(1)
+	}
+	if img.Resampling, ok = viz.Resamplings[*resample]; !ok {
+		niceflags.PrintErr("unknown resampling filter %q.\n", *resample)
+		os.Exit(1)
+	}
+	if img.Mirror, ok = viz.Mirrors[*mirror]; !ok {
+		niceflags.PrintErr("unknown symmetry %q.\n", *mirror)
+		os.Exit(1)
+	}
+	if img.Reset, ok = viz.ResetModes[*reset]; !ok {
+		niceflags.PrintErr("unknown reset mode %q.\n", *reset)
+		os.Exit(1)
+	}
+	if img.Redraw, ok = viz.RedrawModes[*redraw]; !ok {
+		niceflags.PrintErr("unknown redraw mode %q.\n", *redraw)
+		os.Exit(1)
+	}
+	if img.ColorMode, ok = viz.ColorModes[*colorMode]; !ok {
+		niceflags.PrintErr("unknown color mode %q.\n", *colorMode)
+		os.Exit(1)
+	}
+	if img.Dither, ok = viz.DitherModes[*dither]; !ok {
+		niceflags.PrintErr("unknown dither mode %q.\n", *dither)
+		os.Exit(1)
+	}
+	if *colormap != "" {
+		if img.Colormap, ok = viz.Colormaps[*colormap]; !ok {
+			niceflags.PrintErr("unknown colormap %q.\n", *colormap)
+			os.Exit(1)
+		}
+	}
+	gains, err := parseTriple(*gain)
+	check(err)
+	offsets, err := parseTriple(*offset)
+	check(err)
+	for i, ch := range []*viz.Channel{&img.ChannelAdjust.R, &img.ChannelAdjust.G, &img.ChannelAdjust.B} {
+		if *gain != "" { //a gain of 0 clears the channel
+			ch.Scale = &gains[i]
+		}
+		ch.Offset = offsets[i]
+	}
+	if *trim >= 0 {
+		img.AutoTrim, img.TrimTolerance = true, *trim
+	}
+	if *overlay != "" {
+		c, err := parseColor(*overlay)
+		if err != nil {
+			niceflags.PrintErr("invalid -overlay: %v.\n", err)
+			os.Exit(1)
+		}
+		img.Overlay = c
+		img.OverlayAlpha = *overlayAlpha
+	}
+	img.DotThreshold = *dots
+	if *dotColor != "" {
+		c, err := parseColor(*dotColor)
+		if err != nil {
+			niceflags.PrintErr("invalid -dotcolor: %v.\n", err)
+			os.Exit(1)
+		}
+		img.DotColor = c
+	}
+	if *key != "" {
+		c, err := parseColor(*key)
+		if err != nil {
+			niceflags.PrintErr("invalid -key: %v.\n", err)
+			os.Exit(1)
+		}
+		img.ChromaKey = c
+		img.ChromaTolerance = *tolerance
+	}
+	if *fullBlocks {
+		img.Glyph = viz.GlyphFullBlock
+	}
+	if *excludeSystemColors {
+		img.ColorMode = viz.NoSystemColors
+	}
+	if *below {
+		if row, err := terminal.CursorRow(200 * time.Millisecond); err == nil {
+			img.UsedLines = row - 1
+		}
+	}
+	if *theme {
+		viz.SetSystemColors(terminal.Palette(200 * time.Millisecond))
+	}
+	if *disposal != "" {
+		methods := map[string]byte{"none": gif.DisposalNone, "background": gif.DisposalBackground, "previous": gif.DisposalPrevious}
+		if img.ForceDisposal, ok = methods[*disposal]; !ok {
+			niceflags.PrintErr("unknown disposal method %q.\n", *disposal)
+			os.Exit(1)
+		}
+	}
+	if *skipFrames {
+		img.SlowTerminal = viz.SlowTerminalSkip
+	}
+	if *debug {
+		img.Logger = log.New(os.Stderr, "img: ", 0)
 	}
 
-	check(img.Init())
+	out := os.Stdout
+	if *fd != 1 {
+		out = os.NewFile(uintptr(*fd), fmt.Sprintf("fd%v", *fd))
+		if out == nil {
+			niceflags.PrintErr("invalid file descriptor %v.\n", *fd)
+			os.Exit(1)
+		}
+		if _, err := out.Stat(); err != nil { //e.g. not open
+			niceflags.PrintErr("invalid file descriptor %v: %v.\n", *fd, err)
+			os.Exit(1)
+		}
+		img.Output = out
+	}
+
+	if img.ExportFilename == "" && !*animate && !*raw && !terminal.IsTerminal(out) {
+		//Cursor movement and delays are meaningless when the output is redirected,
+		//so render the first frame only and don't leave room for a prompt.
+		img.LoopCount = 0
+		img.FullHeight = true
+	}
+
+	if *scroll || *page {
+		img.LoopCount = 0
+	}
+	if *page && img.UserWidth == 0 && img.Size == (viz.Size{}) {
+		tw, _, err := terminalSize(out)
+		check(err)
+		img.UserWidth = tw
+	}
+
+	if strings.HasSuffix(strings.ToLower(img.Filename), ".ans") {
+		//Replay a pre-rendered image, e.g. exported with -o file.ans.
+		a, err := viz.LoadANSI(img.Filename)
+		check(err)
+		check(a.Draw(viz.NewStdoutCanvas(out), img.LoopCount, img.DelayMultiplier))
+		return
+	}
+
+	if *slideshow {
+		show, err := viz.LoadSlideshow(img.Filename)
+		check(err)
+		check(show.Draw(viz.NewStdoutCanvas(out), img))
+		return
+	}
+
+	if *fromClipboard {
+		img.Data, err = clipboard.ReadImage()
+		check(err)
+	} else if img.Filename == "-" {
+		//Read the image piped from another command, e.g. a GIF generated on the fly, as a file.
+		img.Filename = "stdin"
+		img.Reader = os.Stdin
+	} else if fetch.IsURL(img.Filename) {
+		opts := fetch.Options{Retries: *retries, Backoff: time.Duration(*backoff) * time.Millisecond}
+		if *cacheURL {
+			dir, err := os.UserCacheDir()
+			check(err)
+			opts.CacheDir = filepath.Join(dir, "img")
+		}
+		img.Data, err = fetch.Get(img.Filename, opts)
+		check(err)
+	}
+
+	stdout := viz.NewStdoutCanvas(out)
+	if *firstFrame && img.ExportFilename == "" {
+		img.FirstFrameCanvas = stdout
+	}
+
+	if *pattern != "" {
+		p, ok := viz.Patterns[*pattern]
+		if !ok {
+			niceflags.PrintErr("unknown test pattern %q.\n", *pattern)
+			os.Exit(1)
+		}
+		check(img.InitFrames([]image.Image{viz.NewPattern(p, 256, 128)}, []time.Duration{0}))
+	} else if *diff != "" {
+		d, err := viz.DiffFiles(img.Filename, *diff)
+		check(err)
+		if img.Colormap == nil {
+			img.Colormap = viz.Magma
+		}
+		check(img.InitFrames([]image.Image{d}, []time.Duration{0}))
+	} else {
+		check(img.Init())
+	}
+	exportGIF := strings.HasSuffix(strings.ToLower(img.ExportFilename), ".gif")
+	if img.LoopCount == viz.LoopForever && img.ExportFilename != "" && !exportGIF {
+		check(errors.New("cannot export an animation that loops forever; specify a loop count with -l"))
+	}
+
+	setMode := restoreOnInterrupt(out)
+	if *scroll {
+		tw, th, err := terminalSize(out)
+		check(err)
+		restore, err := terminal.MakeRaw()
+		check(err)
+		setMode(restore)
+		err = img.Scroll(viz.NewStdoutCanvas(out), os.Stdin, tw, th-1)
+		setMode(nil)
+		restore()
+		check(err)
+		return
+	}
+	if *page {
+		_, th, err := terminalSize(out)
+		check(err)
+		restore, err := terminal.MakeRaw()
+		check(err)
+		setMode(restore)
+		err = img.Page(viz.NewStdoutCanvas(out), os.Stdin, th-1)
+		setMode(nil)
+		restore()
+		check(err)
+		return
+	}
+
+	if strings.HasSuffix(strings.ToLower(img.ExportFilename), ".png") {
+		f, err := os.Create(img.ExportFilename)
+		check(err)
+		check(img.EncodePNG(f, *metadata))
+		check(f.Close())
+		return
+	}
+
+	if exportGIF {
+		f, err := os.Create(img.ExportFilename)
+		check(err)
+		check(img.EncodeGIF(f))
+		check(f.Close())
+		return
+	}
+
+	if strings.HasSuffix(strings.ToLower(img.ExportFilename), ".ans") {
+		f, err := os.Create(img.ExportFilename)
+		check(err)
+		check(img.EncodeANSI(f))
+		check(f.Close())
+		return
+	}
+
+	if *raw {
+		check(img.EncodeIndices(out))
+		return
+	}
+
+	if strings.HasSuffix(strings.ToLower(img.ExportFilename), ".idx") {
+		f, err := os.Create(img.ExportFilename)
+		check(err)
+		check(img.EncodeIndices(f))
+		check(f.Close())
+		return
+	}
+
+	if strings.HasSuffix(strings.ToLower(img.ExportFilename), ".json") {
+		f, err := os.Create(img.ExportFilename)
+		check(err)
+		check(img.EncodeJSON(f))
+		check(f.Close())
+		return
+	}
 
 	var canvas viz.Canvas
 	if img.ExportFilename == "" {
-		canvas = &viz.StdoutCanvas{}
+		canvas = stdout
 	} else {
+		var fc viz.Canvas
 		var err error
-		canvas, err = viz.NewFileCanvas(img.ExportFilename)
+		if strings.HasSuffix(strings.ToLower(img.ExportFilename), ".cast") {
+			fc, err = viz.NewCastCanvas(img.ExportFilename, img.Columns(), img.Rows()+1)
+		} else {
+			fc, err = viz.NewFileCanvas(img.ExportFilename)
+		}
 		check(err)
+		canvas = fc
+		if *tee {
+			canvas = viz.NewMultiCanvas(viz.NewStdoutCanvas(out), fc)
+		}
 	}
 
 	check(img.Draw(canvas))
+	if *stats {
+		if f := img.Format(); f != "" {
+			fmt.Fprintln(os.Stderr, "format:", f)
+		}
+		fmt.Fprintln(os.Stderr, img.Stats())
+	}
+}
+
+// terminalSize returns the dimensions of the terminal that
+// out is connected to.
+func terminalSize(out *os.File) (width, height int, err error) {
+	if out == os.Stdout {
+		return terminal.Size()
+	}
+	return terminal.SizeOf(out)
+}
+
+// parseLoopCount parses the loop count flag.
+func parseLoopCount(loop string) (int, error) {
+	switch loop {
+	case "forever":
+		return viz.LoopForever, nil
+	case "auto":
+		return viz.LoopAuto, nil
+	}
+	n, err := strconv.Atoi(loop)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid loop count %q", loop)
+	}
+	return n, nil
+}
+
+// parseTriple parses three comma separated numbers, such
+// as the red, green and blue components of a color.
+// An empty string results in zeros.
+func parseTriple(s string) ([3]float64, error) {
+	var t [3]float64
+	if s == "" {
+		return t, nil
+	}
+	fields := strings.Split(s, ",")
+	if len(fields) != len(t) {
+		return t, fmt.Errorf("expecting three comma separated numbers, got %q", s)
+	}
+	for i, f := range fields {
+		var err error
+		if t[i], err = strconv.ParseFloat(strings.TrimSpace(f), 64); err != nil {
+			return t, fmt.Errorf("invalid number %q", f)
+		}
+	}
+	return t, nil
+}
+
+// parseColor parses the comma separated red, green and blue
+// components (0-255) of an opaque color.
+func parseColor(s string) (color.RGBA, error) {
+	rgb, err := parseTriple(s)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	for _, v := range rgb {
+		if v < 0 || v > 255 || v != math.Trunc(v) {
+			return color.RGBA{}, fmt.Errorf("expecting components between 0 and 255, got %q", s)
+		}
+	}
+	return color.RGBA{uint8(rgb[0]), uint8(rgb[1]), uint8(rgb[2]), 0xff}, nil
+}
+
+// restoreOnInterrupt restores the terminal when the program
+// is interrupted (e.g. with Ctrl-C while an animation loops
+// forever), so that the prompt doesn't show up without a
+// cursor, in the colors of the image or next to it. Nothing is
+// written when out isn't a terminal (e.g. redirected to a
+// file). The returned function sets how to restore the mode of
+// the terminal while it's changed (e.g. raw mode), or nil.
+func restoreOnInterrupt(out *os.File) (setMode func(restore func() error)) {
+	interactive := terminal.IsTerminal(out)
+	var mu sync.Mutex
+	var restoreMode func() error
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		mu.Lock()
+		if restoreMode != nil {
+			restoreMode()
+		}
+		if interactive {
+			fmt.Fprint(out, viz.RestoreSequence+"\n")
+		}
+		os.Exit(128 + int(sig.(syscall.Signal)))
+	}()
+	return func(restore func() error) {
+		mu.Lock()
+		restoreMode = restore
+		mu.Unlock()
+	}
 }
 
 // check prints the error message and exits
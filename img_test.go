@@ -7,6 +7,7 @@ package main
 
 import (
 	"fmt"
+	"image/color"
 	"io/ioutil"
 	"math"
 	"os"
@@ -41,7 +42,8 @@ func export(testfile string, loopCount int, delayMultiplier float64, width int)
 		"-w", fmt.Sprintf("%v", img.UserWidth),
 		testData + testfile,
 	}
-	main() //invoke main to test flag parsing as well.
+	os.Setenv("COLORFGBG", "15;0") //test data was generated on a dark terminal
+	main()                         //invoke main to test flag parsing as well.
 
 	return img
 }
@@ -75,3 +77,14 @@ func TestGIF(t *testing.T) {
 	img := export("disposalNone.gif", 3, 2, 60)
 	validate("all.sh", img, t)
 }
+
+func TestParseColor(t *testing.T) {
+	if c, err := parseColor("0, 128,255"); err != nil || c != (color.RGBA{0, 128, 255, 255}) {
+		t.Errorf("expected 0,128,255, got %v, %v", c, err)
+	}
+	for _, s := range []string{"256,0,0", "-1,0,0", "0.5,0,0", "0,0"} {
+		if _, err := parseColor(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
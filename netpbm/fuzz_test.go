@@ -0,0 +1,44 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package netpbm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzDecode checks that decoding untrusted images fails
+// gracefully and agrees with DecodeConfig.
+func FuzzDecode(f *testing.F) {
+	for _, data := range []string{
+		"P1\n# comment\n2 1\n10",
+		"P4 2 1\n\x80",
+		"P2 2 1 4\n4 0\n",
+		"P5 2 1 65535\n\xff\xfe\x80\x00",
+		"P3 2 1 255\n255 0 0 0 0 0\n",
+		"P6 2 1 255\n\xff\x00\x00\x00\x00\x00",
+		"P6 100000 100000 255\n\xff",
+	} {
+		f.Add([]byte(data))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal("expecting no error decoding the config of a decodable image, got", err)
+		}
+		if b := img.Bounds(); b.Dx() != cfg.Width || b.Dy() != cfg.Height {
+			t.Errorf("expected %vx%v pixels, got %v", cfg.Width, cfg.Height, b)
+		}
+		if img.ColorModel() != cfg.ColorModel {
+			t.Error("expected the color model of the config")
+		}
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+// Package netpbm implements a decoder for the Netpbm PBM, PGM
+// and PPM image formats, in both their ASCII (P1-P3) and binary
+// (P4-P6) variants.
+//
+// Importing this package registers the formats with the image
+// package.
+package netpbm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+func init() {
+	for _, f := range []struct{ name, magic string }{
+		{"pbm", "P1"}, {"pgm", "P2"}, {"ppm", "P3"},
+		{"pbm", "P4"}, {"pgm", "P5"}, {"ppm", "P6"},
+	} {
+		image.RegisterFormat(f.name, f.magic, Decode, DecodeConfig)
+	}
+}
+
+// header is the header of a Netpbm image.
+type header struct {
+	magic         byte //the digit following P
+	width, height int
+	maxVal        int
+}
+
+// MaxPixels is the maximum number of pixels (width x height)
+// of the images decoded, whose headers are rejected beyond it
+// so that a few bytes can't claim gigabytes of memory.
+const MaxPixels = 1 << 27
+
+// Decode reads a Netpbm image from r. Truncated rasters fail
+// with io.ErrUnexpectedEOF; the image grows as the raster is
+// read rather than being allocated from the dimensions of the
+// header, so short inputs can't exhaust memory either.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var pix []uint8 //big endian 16-bit samples, as in image.Gray16 and image.RGBA64
+	put := func(v uint16) { pix = append(pix, uint8(v>>8), uint8(v)) }
+	binary := h.magic >= '4'
+	for y := 0; y < h.height; y++ {
+		var bits byte
+		for x := 0; x < h.width; x++ {
+			switch h.magic {
+			case '1', '4':
+				var v int
+				if binary {
+					if x%8 == 0 { //rows are padded to a byte
+						if bits, err = br.ReadByte(); err != nil {
+							return nil, truncated(err)
+						}
+					}
+					v = int(bits>>uint(7-x%8)) & 1
+				} else if v, err = readBit(br); err != nil {
+					return nil, truncated(err)
+				}
+				put(uint16(0xffff * (1 - v))) //1 is black
+			case '2', '5':
+				v, err := readSample(br, h, binary)
+				if err != nil {
+					return nil, truncated(err)
+				}
+				put(v)
+			default:
+				for i := 0; i < 3; i++ {
+					v, err := readSample(br, h, binary)
+					if err != nil {
+						return nil, truncated(err)
+					}
+					put(v)
+				}
+				put(0xffff)
+			}
+		}
+	}
+	rect := image.Rect(0, 0, h.width, h.height)
+	if h.magic == '3' || h.magic == '6' {
+		return &image.RGBA64{Pix: pix, Stride: 8 * h.width, Rect: rect}, nil
+	}
+	return &image.Gray16{Pix: pix, Stride: 2 * h.width, Rect: rect}, nil
+}
+
+// truncated reports the end of the input within the raster
+// as io.ErrUnexpectedEOF.
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// DecodeConfig returns the color model and dimensions of a
+// Netpbm image without decoding the entire image.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := color.Gray16Model
+	if h.magic == '3' || h.magic == '6' {
+		model = color.RGBA64Model
+	}
+	return image.Config{ColorModel: model, Width: h.width, Height: h.height}, nil
+}
+
+func readHeader(br *bufio.Reader) (header, error) {
+	var h header
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return h, err
+	}
+	if magic[0] != 'P' || magic[1] < '1' || magic[1] > '6' {
+		return h, errors.New("netpbm: invalid format")
+	}
+	h.magic = magic[1]
+
+	fields := []*int{&h.width, &h.height, &h.maxVal}
+	h.maxVal = 1
+	if h.magic == '1' || h.magic == '4' {
+		fields = fields[:2]
+	}
+	for _, f := range fields {
+		v, err := readInt(br)
+		if err != nil {
+			return h, err
+		}
+		*f = v
+	}
+	if h.width <= 0 || h.height <= 0 || h.maxVal <= 0 || h.maxVal > 0xffff {
+		return h, fmt.Errorf("netpbm: invalid header %vx%v (max %v)", h.width, h.height, h.maxVal)
+	}
+	if int64(h.width)*int64(h.height) > MaxPixels {
+		return h, fmt.Errorf("netpbm: image is %vx%v, more than the maximum of %v pixels", h.width, h.height, MaxPixels)
+	}
+
+	if h.magic >= '4' { //a single whitespace separates the header from the binary raster
+		if _, err := br.ReadByte(); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// readSample reads a sample and scales it to 16 bits.
+func readSample(br *bufio.Reader, h header, binary bool) (uint16, error) {
+	var v int
+	var err error
+	switch {
+	case !binary:
+		v, err = readInt(br)
+	case h.maxVal < 256:
+		var b byte
+		b, err = br.ReadByte()
+		v = int(b)
+	default:
+		var b [2]byte
+		_, err = io.ReadFull(br, b[:])
+		v = int(b[0])<<8 | int(b[1])
+	}
+	if err != nil {
+		return 0, err
+	}
+	if v > h.maxVal {
+		v = h.maxVal
+	}
+	return uint16(uint32(v) * 0xffff / uint32(h.maxVal)), nil //in uint32: v*0xffff overflows a 32-bit int
+}
+
+// readBit reads a single 0 or 1 digit from an ASCII bitmap
+// where the digits need not be separated by whitespace.
+func readBit(br *bufio.Reader) (int, error) {
+	if err := skipSpace(br); err != nil {
+		return 0, err
+	}
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != '0' && b != '1' {
+		return 0, fmt.Errorf("netpbm: invalid bit %q", b)
+	}
+	return int(b - '0'), nil
+}
+
+// readInt reads a decimal number, skipping any whitespace
+// and comments before it.
+func readInt(br *bufio.Reader) (int, error) {
+	if err := skipSpace(br); err != nil {
+		return 0, err
+	}
+	v, digits := 0, 0
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF && digits > 0 {
+			return v, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if b < '0' || b > '9' {
+			br.UnreadByte()
+			break
+		}
+		if v = v*10 + int(b-'0'); v > 1<<24 {
+			return 0, errors.New("netpbm: number too large")
+		}
+		digits++
+	}
+	if digits == 0 {
+		return 0, errors.New("netpbm: expecting a number")
+	}
+	return v, nil
+}
+
+// skipSpace skips whitespace and comments.
+func skipSpace(br *bufio.Reader) error {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+		case '#':
+			if _, err := br.ReadString('\n'); err != nil {
+				return err
+			}
+		default:
+			return br.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package netpbm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	white := color.RGBA64{0xffff, 0xffff, 0xffff, 0xffff}
+	black := color.RGBA64{0, 0, 0, 0xffff}
+	red := color.RGBA64{0xffff, 0, 0, 0xffff}
+	for _, tc := range []struct {
+		format   string
+		data     string
+		expected [2]color.RGBA64 //first two pixels
+	}{
+		{"pbm", "P1\n# comment\n2 1\n10", [2]color.RGBA64{black, white}},
+		{"pbm", "P4 2 1\n\x80", [2]color.RGBA64{black, white}},
+		{"pgm", "P2 2 1 4\n4 0\n", [2]color.RGBA64{white, black}},
+		{"pgm", "P5 2 1 255\n\xff\x00", [2]color.RGBA64{white, black}},
+		{"ppm", "P3 2 1 255\n255 0 0 0 0 0\n", [2]color.RGBA64{red, black}},
+		{"ppm", "P6 2 1 65535\n\xff\xff\x00\x00\x00\x00\xff\xff\xff\xff\xff\xff", [2]color.RGBA64{red, white}},
+		{"pgm", "P5 2 1 65535\n\xff\xfe\x80\x00", [2]color.RGBA64{{0xfffe, 0xfffe, 0xfffe, 0xffff}, {0x8000, 0x8000, 0x8000, 0xffff}}},
+		{"pgm", "P2 2 1 60000\n59999 30000\n", [2]color.RGBA64{{0xfffd, 0xfffd, 0xfffd, 0xffff}, {0x7fff, 0x7fff, 0x7fff, 0xffff}}},
+	} {
+		img, format, err := image.Decode(bytes.NewBufferString(tc.data))
+		if err != nil {
+			t.Fatalf("%q: expecting no error, got %v", tc.data, err)
+		}
+		if format != tc.format {
+			t.Errorf("%q: expected format %v, got %v", tc.data, tc.format, format)
+		}
+		for x, c := range tc.expected {
+			if got := color.RGBA64Model.Convert(img.At(x, 0)); got != c {
+				t.Errorf("%q: expected %v at %v, got %v", tc.data, c, x, got)
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, data := range []string{"P7 1 1", "P2 0 1 255\n", "P2 1 1 255\n", "P6 2 1 255\n\xff"} {
+		if _, err := Decode(bytes.NewBufferString(data)); err == nil {
+			t.Errorf("%q: expecting an error", data)
+		}
+	}
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	data := "P6 100000 100000 255\n\xff"
+	if _, err := Decode(bytes.NewBufferString(data)); err == nil {
+		t.Errorf("%q: expecting an error", data)
+	}
+	if _, err := DecodeConfig(bytes.NewBufferString(data)); err == nil {
+		t.Errorf("%q: expecting an error from DecodeConfig", data)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	for _, data := range []string{"P1 2 1\n1", "P4 9 1\n\x80", "P2 2 1 255\n0", "P5 2 1 65535\n\xff\xff\xff", "P6 2 1 255\n\xff", "P6 10000 10000 255\n"} {
+		if _, err := Decode(bytes.NewBufferString(data)); err != io.ErrUnexpectedEOF {
+			t.Errorf("%q: expected %v, got %v", data, io.ErrUnexpectedEOF, err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	systerm "golang.org/x/crypto/ssh/terminal"
+)
+
+// XtermColors are the default definitions of the 16 system
+// colors in xterm.
+var XtermColors = []color.Color{
+	color.RGBA{0x00, 0x00, 0x00, 0xff}, color.RGBA{0xcd, 0x00, 0x00, 0xff},
+	color.RGBA{0x00, 0xcd, 0x00, 0xff}, color.RGBA{0xcd, 0xcd, 0x00, 0xff},
+	color.RGBA{0x00, 0x00, 0xee, 0xff}, color.RGBA{0xcd, 0x00, 0xcd, 0xff},
+	color.RGBA{0x00, 0xcd, 0xcd, 0xff}, color.RGBA{0xe5, 0xe5, 0xe5, 0xff},
+	color.RGBA{0x7f, 0x7f, 0x7f, 0xff}, color.RGBA{0xff, 0x00, 0x00, 0xff},
+	color.RGBA{0x00, 0xff, 0x00, 0xff}, color.RGBA{0xff, 0xff, 0x00, 0xff},
+	color.RGBA{0x5c, 0x5c, 0xff, 0xff}, color.RGBA{0xff, 0x00, 0xff, 0xff},
+	color.RGBA{0x00, 0xff, 0xff, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff},
+}
+
+// Palette queries the definitions of the 16 system colors
+// from the terminal with OSC 4 control sequences, which most
+// terminal emulators answer with the colors of their theme.
+// Colors that aren't reported within the timeout (e.g. because
+// the terminal doesn't support the queries) are XtermColors.
+func Palette(timeout time.Duration) []color.Color {
+	colors := append([]color.Color(nil), XtermColors...)
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return colors
+	}
+	defer tty.Close()
+	state, err := systerm.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return colors
+	}
+	defer systerm.Restore(int(tty.Fd()), state)
+
+	//Read the replies until all of them arrived or the deadline
+	//passed (ttys support deadlines as they can be polled). Without
+	//a deadline reading could block forever, so the terminal isn't
+	//queried.
+	if err := tty.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return colors
+	}
+	var query strings.Builder
+	for i := range colors {
+		fmt.Fprintf(&query, "\x1b]4;%v;?\x07", i)
+	}
+	if _, err := tty.WriteString(query.String()); err != nil {
+		return colors
+	}
+	var replies []byte
+	buf := make([]byte, 256)
+	for reported := 0; reported < len(colors); {
+		n, err := tty.Read(buf)
+		replies = append(replies, buf[:n]...)
+		if err != nil {
+			break
+		}
+		reported = len(parsePalette(replies))
+	}
+	for i, c := range parsePalette(replies) {
+		colors[i] = c
+	}
+	return colors
+}
+
+// parsePalette parses the OSC 4 replies, of the form
+// ESC ] 4 ; index ; rgb:RRRR/GGGG/BBBB terminated by BEL or
+// ST, where the components have 1 to 4 hex digits.
+func parsePalette(replies []byte) map[int]color.Color {
+	colors := make(map[int]color.Color)
+	for _, reply := range bytes.Split(replies, []byte("\x1b]4;"))[1:] {
+		if end := bytes.IndexAny(reply, "\x07\x1b"); end >= 0 {
+			reply = reply[:end]
+		} else {
+			continue //incomplete
+		}
+		fields := strings.SplitN(string(reply), ";", 2)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "rgb:") {
+			continue
+		}
+		i, err := strconv.Atoi(fields[0])
+		components := strings.Split(strings.TrimPrefix(fields[1], "rgb:"), "/")
+		if err != nil || i < 0 || i > 15 || len(components) != 3 {
+			continue
+		}
+		r, okR := component(components[0])
+		g, okG := component(components[1])
+		b, okB := component(components[2])
+		if okR && okG && okB {
+			colors[i] = color.RGBA{r, g, b, 0xff}
+		}
+	}
+	return colors
+}
+
+// component scales a color component of 1 to 4 hex digits
+// to 8 bits.
+func component(hex string) (uint8, bool) {
+	if len(hex) == 0 || len(hex) > 4 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	max := uint64(1)<<uint(4*len(hex)) - 1
+	return uint8(v * 255 / max), true
+}
+
+// CursorRow queries the line the cursor is on (from 1 at the
+// top of the screen) with the DSR (device status report)
+// control sequence, e.g. to know how many lines a banner
+// printed before an image took. It fails if the terminal
+// doesn't reply within the timeout.
+func CursorRow(timeout time.Duration) (int, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer tty.Close()
+	state, err := systerm.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return 0, err
+	}
+	defer systerm.Restore(int(tty.Fd()), state)
+
+	if err := tty.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err //reading could block forever
+	}
+	if _, err := tty.WriteString("\x1b[6n"); err != nil {
+		return 0, err
+	}
+	var reply []byte
+	buf := make([]byte, 32)
+	for {
+		n, err := tty.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if row, ok := parseCursorPosition(reply); ok {
+			return row, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// parseCursorPosition parses the row of the DSR reply, of the
+// form ESC [ row ; column R.
+func parseCursorPosition(reply []byte) (int, bool) {
+	start := bytes.LastIndex(reply, []byte("\x1b["))
+	if start < 0 {
+		return 0, false
+	}
+	reply = reply[start+2:]
+	end := bytes.IndexByte(reply, 'R')
+	if end < 0 {
+		return 0, false //incomplete
+	}
+	fields := strings.Split(string(reply[:end]), ";")
+	if len(fields) != 2 {
+		return 0, false
+	}
+	row, err := strconv.Atoi(fields[0])
+	if err != nil || row < 1 {
+		return 0, false
+	}
+	return row, true
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package terminal
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParsePalette(t *testing.T) {
+	replies := "\x1b]4;0;rgb:2828/2c2c/3434\x1b\\" + //ST terminated, 16 bit components
+		"\x1b]4;1;rgb:e0/6c/75\x07" + //BEL terminated, 8 bit components
+		"\x1b]4;2;rgb:f/0/8\x07" + //4 bit components
+		"\x1b]4;3;rgb:zz/00/00\x07" + //malformed
+		"\x1b]4;4;rgb:61/af" //incomplete
+	colors := parsePalette([]byte(replies))
+
+	expected := map[int]color.Color{
+		0: color.RGBA{0x28, 0x2c, 0x34, 0xff},
+		1: color.RGBA{0xe0, 0x6c, 0x75, 0xff},
+		2: color.RGBA{0xff, 0x00, 0x88, 0xff},
+	}
+	if len(colors) != len(expected) {
+		t.Errorf("expected %v colors, got %v", len(expected), colors)
+	}
+	for i, c := range expected {
+		if colors[i] != c {
+			t.Errorf("color %v: expected %v, got %v", i, c, colors[i])
+		}
+	}
+}
+
+func TestParseCursorPosition(t *testing.T) {
+	for reply, expected := range map[string]int{
+		"\x1b[12;1R":           12,
+		"\x1b]4;0;x\x1b[3;40R": 3, //after an unrelated reply
+		"\x1b[12;1":            0, //incomplete
+		"\x1b[x;1R":            0,
+		"":                     0,
+	} {
+		row, ok := parseCursorPosition([]byte(reply))
+		if row != expected || ok != (expected > 0) {
+			t.Errorf("%q: expected row %v, got %v (%v)", reply, expected, row, ok)
+		}
+	}
+}
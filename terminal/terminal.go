@@ -5,12 +5,134 @@
 
 package terminal
 
-import systerm "golang.org/x/crypto/ssh/terminal"
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	systerm "golang.org/x/crypto/ssh/terminal"
+)
 
 // Size returns the dimensions of the terminal.
 // This function can be overriden for test cases
 // as system calls fail with "operation not supported"
 // in test environments
 var Size = func() (width int, height int, err error) {
-	return systerm.GetSize(0)
+	// Standard streams may be redirected (e.g. in SSH commands),
+	// so try each of them before the controlling terminal.
+	for _, fd := range []int{0, 1, 2} {
+		if w, h, e := systerm.GetSize(fd); e == nil {
+			return w, h, nil
+		} else if err == nil {
+			err = e
+		}
+	}
+	if tty, e := os.Open("/dev/tty"); e == nil {
+		w, h, e := systerm.GetSize(int(tty.Fd()))
+		tty.Close()
+		if e == nil {
+			return w, h, nil
+		}
+	}
+
+	// Fall back to the size exported by the shell.
+	return envSize(err)
+}
+
+// SizeOf returns the dimensions of the terminal f is connected
+// to (e.g. a file descriptor other than stdout that the image
+// is rendered to), falling back to the size exported by the
+// shell if f isn't a terminal.
+// This function can be overriden for test cases.
+var SizeOf = func(f *os.File) (width int, height int, err error) {
+	w, h, err := systerm.GetSize(int(f.Fd()))
+	if err != nil {
+		return envSize(err)
+	}
+	return w, h, nil
+}
+
+// envSize returns the size exported by the shell in the
+// COLUMNS and LINES environment variables, or err if they
+// aren't set.
+func envSize(err error) (int, int, error) {
+	w, e1 := strconv.Atoi(os.Getenv("COLUMNS"))
+	h, e2 := strconv.Atoi(os.Getenv("LINES"))
+	if e1 == nil && e2 == nil && w > 0 && h > 0 {
+		return w, h, nil
+	}
+	return 0, 0, err
+}
+
+// Interactive reports whether stdout is a terminal, as
+// opposed to being redirected to a file or a pipe.
+// This function can be overriden for test cases.
+var Interactive = func() bool {
+	return IsTerminal(os.Stdout)
+}
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	return systerm.IsTerminal(int(f.Fd()))
+}
+
+// MakeRaw puts the terminal into raw mode so that key
+// presses can be read as they are typed. The returned
+// function restores the previous state of the terminal.
+func MakeRaw() (restore func() error, err error) {
+	state, err := systerm.MakeRaw(0)
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return systerm.Restore(0, state) }, nil
+}
+
+// Dark reports whether the terminal has a dark background
+// as advertised by the COLORFGBG environment variable
+// (e.g. "15;0" or "0;default;15"), where the last field is
+// the palette index of the background color.
+// A dark background is assumed if the variable is absent
+// or malformed.
+func Dark() bool {
+	fields := strings.Split(os.Getenv("COLORFGBG"), ";")
+	bg, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return true
+	}
+	return bg != 7 && (bg < 9 || bg > 15) //light gray and the bright colors are light backgrounds
+}
+
+// TrueColor is the color depth of terminals supporting 24-bit
+// colors (see ColorDepth).
+const TrueColor = 1 << 24
+
+// ColorDepth returns the number of colors that the terminal
+// supports according to the COLORTERM and TERM environment
+// variables: TrueColor if COLORTERM is truecolor or 24bit, 0
+// for terminals without colors (e.g. dumb and vt100), 16 for
+// those with the basic ANSI colors only (e.g. linux) and 256
+// otherwise, including if TERM isn't set or COLORTERM
+// advertises more colors.
+// This function can be overriden for test cases.
+var ColorDepth = func() int {
+	return colorDepth(os.Getenv("TERM"), os.Getenv("COLORTERM"))
+}
+
+// colorDepth returns the number of colors supported by the
+// terminal type and the advertised colors (see ColorDepth).
+func colorDepth(term, colorterm string) int {
+	switch {
+	case colorterm == "truecolor" || colorterm == "24bit":
+		return TrueColor
+	case colorterm != "":
+		return 256
+	case term == "dumb" || strings.HasPrefix(term, "vt52") || strings.HasPrefix(term, "vt100") ||
+		strings.HasPrefix(term, "vt102"):
+		return 0
+	case term == "linux" || term == "ansi" || term == "cons25" || strings.HasSuffix(term, "-color") ||
+		strings.HasSuffix(term, "-8color") || strings.HasSuffix(term, "-16color"):
+		return 16
+	default:
+		return 256
+	}
 }
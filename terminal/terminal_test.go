@@ -0,0 +1,77 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package terminal
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestColorDepth(t *testing.T) {
+	for term, expected := range map[string]int{
+		"dumb":           0,
+		"vt100":          0,
+		"vt102-nsgr":     0,
+		"linux":          16,
+		"xterm-color":    16,
+		"rxvt-16color":   16,
+		"xterm":          256,
+		"xterm-256color": 256,
+		"screen":         256,
+		"":               256,
+	} {
+		if got := colorDepth(term, ""); got != expected {
+			t.Errorf("%q: expected %v colors, got %v", term, expected, got)
+		}
+	}
+
+	for colorterm, expected := range map[string]int{
+		"truecolor": TrueColor,
+		"24bit":     TrueColor,
+		"yes":       256,
+	} {
+		if got := colorDepth("linux", colorterm); got != expected {
+			t.Errorf("COLORTERM %q: expected %v colors, got %v", colorterm, expected, got)
+		}
+	}
+}
+
+func TestEnvSize(t *testing.T) {
+	fallback := errors.New("not a terminal")
+	for _, tc := range []struct {
+		columns, lines string //unset if empty
+		w, h           int
+		ok             bool
+	}{
+		{"100", "40", 100, 40, true},
+		{"", "", 0, 0, false},
+		{"100", "", 0, 0, false},
+		{"", "40", 0, 0, false},
+		{"0", "40", 0, 0, false},
+		{"100", "0", 0, 0, false},
+		{"-1", "40", 0, 0, false},
+		{"wide", "40", 0, 0, false},
+		{"100", "40x", 0, 0, false},
+	} {
+		for name, value := range map[string]string{"COLUMNS": tc.columns, "LINES": tc.lines} {
+			t.Setenv(name, value) //restored at the end of the test
+			if value == "" {
+				os.Unsetenv(name)
+			}
+		}
+		w, h, err := envSize(fallback)
+		if tc.ok && err != nil {
+			t.Errorf("COLUMNS=%q LINES=%q: expecting no error, got %v", tc.columns, tc.lines, err)
+		}
+		if !tc.ok && err != fallback {
+			t.Errorf("COLUMNS=%q LINES=%q: expected %v, got %v", tc.columns, tc.lines, fallback, err)
+		}
+		if w != tc.w || h != tc.h {
+			t.Errorf("COLUMNS=%q LINES=%q: expected %vx%v, got %vx%v", tc.columns, tc.lines, tc.w, tc.h, w, h)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ansiMarker starts the separator preceding each frame of an
+// ANSI file, followed by the delay of the frame and ansiEnd.
+// The separator is an APC string, which terminals ignore, so
+// that files can still be displayed with cat.
+const (
+	ansiMarker = "\x1b_img;frame;"
+	ansiEnd    = "\x1b\\"
+)
+
+// EncodeANSI writes the rendered frames to w as an ANSI art
+// file (.ans) that can be replayed with LoadANSI without
+// decoding and scaling the image again. The file holds the
+// text of each frame, as drawn by Draw, preceded by the
+// separator
+//
+//	ESC _ img;frame;<delay> ESC \
+//
+// where delay is the number of milliseconds for which the
+// frame is displayed (DelayMultiplier included). The frames
+// are written once, in the order they are played.
+func (img *Image) EncodeANSI(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, frame := range img.sequence() {
+		tc := &textCanvas{}
+		if err := img.drawFrame(tc, frame); err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "%s%v%s", ansiMarker, frame.delay, ansiEnd)
+		if _, err := bw.Write(tc.b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ANSI is a pre-rendered image loaded from an ANSI art file,
+// ready to be replayed.
+type ANSI struct {
+	frames []ansiFrame
+}
+
+type ansiFrame struct {
+	lines []string
+	delay int //ms
+}
+
+// LoadANSI reads an ANSI art file, such as one written by
+// EncodeANSI. Files without frame separators (e.g. ANSI art
+// drawn by other programs) are a single frame. Carriage
+// returns at the end of lines and SAUCE records (metadata
+// following a Ctrl-Z at the end of ANSI art) are ignored.
+func LoadANSI(filename string) (*ANSI, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	text := string(b)
+	if i := strings.IndexByte(text, 0x1a); i >= 0 {
+		text = text[:i]
+	}
+
+	a := &ANSI{}
+	parts := strings.Split(text, ansiMarker)
+	if parts[0] != "" {
+		a.frames = append(a.frames, newANSIFrame(parts[0], 0))
+	}
+	for i, part := range parts[1:] {
+		end := strings.Index(part, ansiEnd)
+		if end < 0 {
+			return nil, fmt.Errorf("invalid ANSI file %s: frame %v has no delay", filename, i+1)
+		}
+		delay, err := strconv.Atoi(part[:end])
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("invalid ANSI file %s: frame %v has an invalid delay %q", filename, i+1, part[:end])
+		}
+		a.frames = append(a.frames, newANSIFrame(part[end+len(ansiEnd):], delay))
+	}
+	if len(a.frames) == 0 {
+		return nil, fmt.Errorf("ANSI file %s is empty", filename)
+	}
+	return a, nil
+}
+
+func newANSIFrame(text string, delay int) ansiFrame {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return ansiFrame{lines: lines, delay: delay}
+}
+
+// Frames returns the number of frames.
+func (a *ANSI) Frames() int {
+	return len(a.frames)
+}
+
+// Draw replays the frames onto the canvas as Image.Draw does,
+// loopCount times (or forever if LoopForever, or the first
+// frame only if 0; LoopAuto plays once), with their delays
+// multiplied by delayMultiplier, and closes the canvas.
+// Still images are drawn once.
+func (a *ANSI) Draw(canvas Canvas, loopCount int, delayMultiplier float64) error {
+	frames := a.frames
+	if loopCount == 0 || len(frames) == 1 {
+		frames, loopCount = frames[:1], 1
+	} else if loopCount == LoopAuto {
+		loopCount = 1
+	}
+	lines := 0 //of the previous frame
+	delay := 0
+	for i := 0; loopCount == LoopForever || i < loopCount; i++ {
+		for _, frame := range frames {
+			if lines > 0 {
+				if err := canvas.LineUp(lines); err != nil {
+					return err
+				}
+				if err := canvas.Sleep(int(math.Ceil(float64(delay) * delayMultiplier))); err != nil {
+					return err
+				}
+			}
+			for _, l := range frame.lines {
+				if err := printText(canvas, l); err != nil {
+					return err
+				}
+				if err := canvas.NewLine(); err != nil {
+					return err
+				}
+			}
+			lines, delay = len(frame.lines), frame.delay
+		}
+	}
+	return canvas.Close()
+}
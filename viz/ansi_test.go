@@ -0,0 +1,91 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// timing returns the cursor movements and delays recorded by
+// the canvas.
+func timing(rc *recordingCanvas) []string {
+	var calls []string
+	for _, c := range rc.calls {
+		if strings.HasPrefix(c, "lineup") || strings.HasPrefix(c, "sleep") {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func TestANSIRoundTrip(t *testing.T) {
+	img := initGIF(writeGIF(t, []uint8{0, 1, 2}, []int{10, 20, 30}, nil), t)
+	img.LoopCount = 2
+	filename := filepath.Join(t.TempDir(), "test.ans")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if err := img.EncodeANSI(f); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	f.Close()
+
+	a, err := LoadANSI(filename)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if a.Frames() != 3 {
+		t.Fatalf("expected 3 frames, got %v", a.Frames())
+	}
+	replayed := &recordingCanvas{}
+	if err := a.Draw(replayed, img.LoopCount, 1); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	drawn := &recordingCanvas{}
+	if err := img.Draw(drawn); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if got, expected := timing(replayed), timing(drawn); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the timing of the image %v, got %v", expected, got)
+	}
+
+	tc := &textCanvas{}
+	if err := img.drawFrame(tc, img.frames[1]); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	var second strings.Builder
+	for _, l := range a.frames[1].lines {
+		second.WriteString(l + "\n")
+	}
+	if second.String() != tc.b.String() {
+		t.Errorf("expected the second frame to be replayed as rendered, got %q", second.String())
+	}
+}
+
+func TestLoadANSIArt(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "art.ans")
+	art := "\x1b[31mred\x1b[0m\r\nline 2\r\n\x1aSAUCE00title"
+	if err := os.WriteFile(filename, []byte(art), 0644); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	a, err := LoadANSI(filename)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := a.Draw(rc, LoopForever, 1); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	expected := []string{`print "\x1b[31mred\x1b[0m"`, "newline", `print "line 2"`, "newline", "close"}
+	if !reflect.DeepEqual(rc.calls, expected) {
+		t.Errorf("expected the art to be drawn once as %v, got %v", expected, rc.calls)
+	}
+}
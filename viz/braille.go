@@ -0,0 +1,76 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image/color"
+	"math"
+)
+
+// brailleBits are the bits of the dots of a Braille pattern,
+// indexed by the x and y of their pixel within the character.
+var brailleBits = [2][4]uint{{0, 1, 2, 6}, {3, 4, 5, 7}}
+
+// dotThreshold returns the resolved DotThreshold.
+func (img *Image) dotThreshold() float64 {
+	if img.DotThreshold == 0 {
+		return 0.5
+	}
+	return img.DotThreshold
+}
+
+// threshold maps the luminance of c so that DotThreshold falls
+// halfway between black and white, where the pixels of the
+// Braille mode are split into dots and blanks (see
+// brailleColors), keeping the gradients on both sides for
+// dithering.
+func (img *Image) threshold(c color.Color) color.Color {
+	t := img.dotThreshold()
+	y := float64(color.GrayModel.Convert(c).(color.Gray).Y) / 255
+	if y < t {
+		y = y / t / 2
+	} else {
+		y = 0.5 + (y-t)/(1-t)/2
+	}
+	return color.Gray{uint8(math.Round(y * 255))}
+}
+
+// brailleColors returns the black and white terminal colors
+// that the pixels of the Braille mode are reduced to.
+func (img *Image) brailleColors() []uint8 {
+	return grayColors(2, img.firstColor())
+}
+
+// dotted reports whether a pixel of the Braille mode is drawn
+// as a dot: if it's white on dark backgrounds, black on light
+// ones where the dots are drawn in a dark foreground color.
+func (img *Image) dotted(c uint8) bool {
+	return (luminance(Colors[c]) > 128) != (luminance(img.bg) > 128)
+}
+
+// brailleRune returns the Braille pattern of the 2x4 pixels
+// whose top left pixel is at x,y.
+func (img *Image) brailleRune(picture [][]uint8, x, y int) rune {
+	var bits rune
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 4; j++ {
+			if img.dotted(picture[x+i][y+j]) {
+				bits |= 1 << brailleBits[i][j]
+			}
+		}
+	}
+	return 0x2800 + bits
+}
+
+// braille returns the Braille pattern of the 2x4 pixels whose
+// top left pixel is at x,y, in DotColor if set.
+func (img *Image) braille(picture [][]uint8, x, y int) string {
+	text := string(img.brailleRune(picture, x, y))
+	if img.dot != defaultColor {
+		return foreground(text, uint8(img.dot))
+	}
+	return text
+}
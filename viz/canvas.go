@@ -9,26 +9,68 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 )
 
 // Canvas is the destination (e.g. stdout vs file) where the
 // image will be rendered.
+//
+// Draw renders each frame line by line, left to right, with a
+// call to Paint or Print (see Printer) per character and a call
+// to NewLine at the end of each line. Before every frame but the first, it
+// calls LineUp with the number of lines of a frame, to move the
+// cursor back to where the previous frame started, and Sleep
+// with the delay of the previous frame. Close is called once,
+// after the last frame, with the cursor below it; no method is
+// called after Close. A canvas writing to a terminal must leave
+// the cursor in the first column after NewLine, and LineUp is
+// only called in the first column.
+//
+// See the viztest package for a test that custom canvases can
+// run to check that they honor this contract.
 type Canvas interface {
 	// Paint renders two pixels at a time - the top (y) and the
-	// bottom (y+1) ones.
+	// bottom (y+1) ones - as one character, advancing the cursor
+	// by one column.
 	Paint(topColor, bottomColor uint8) error
-	// NewLine moves the cursor to the next line
+	// NewLine moves the cursor to the first column of the next
+	// line.
 	NewLine() error
-	// Moves the cursor up one line 'count' times.
+	// LineUp moves the cursor up 'count' lines, staying in the
+	// same column.
 	LineUp(count int) error
-	// Sleep sleeps for the specified time.
+	// Sleep displays what has been rendered so far (e.g. by
+	// flushing buffers) and sleeps for the specified time.
 	Sleep(delayMS int) error
-	// Close closes the canvas.
+	// Close displays what remains to be displayed and closes
+	// the canvas.
 	Close() error
 }
 
+// Printer is implemented by the canvases that can write text
+// at the cursor, which Draw needs for everything but the half
+// blocks painted by default (e.g. the other render modes,
+// labels, captions). All the canvases of this package are
+// printers; drawing text onto a canvas that isn't fails.
+type Printer interface {
+	// Print writes text at the cursor. The text may contain
+	// escape sequences but no new lines, and may span several
+	// characters.
+	Print(text string) error
+}
+
+// printText writes text onto the canvas (see Printer).
+func printText(canvas Canvas, text string) error {
+	p, ok := canvas.(Printer)
+	if !ok {
+		return fmt.Errorf("%T can't print text (see Printer)", canvas)
+	}
+	return p.Print(text)
+}
+
 // NewFileCanvas returns a FileCanvas.
 func NewFileCanvas(filename string) (*FileCanvas, error) {
 	if _, err := os.Stat(filename); !os.IsNotExist(err) {
@@ -67,6 +109,10 @@ func (fc *FileCanvas) Paint(topColor, bottomColor uint8) error {
 	return fc.write(makeTwoPixels(topColor, bottomColor))
 }
 
+func (fc *FileCanvas) Print(text string) error {
+	return fc.write(strings.Replace(text, "'", `'\''`, -1)) //text is written inside single quotes
+}
+
 func (fc *FileCanvas) NewLine() error {
 	return fc.write("\n")
 }
@@ -99,9 +145,32 @@ func (fc *FileCanvas) Close() error {
 	return nil
 }
 
-// StdoutCanvas renders the image to stdout.
+// NewStdoutCanvas returns a StdoutCanvas rendering to f
+// instead of stdout, e.g. a file descriptor passed by a
+// wrapper script (os.NewFile(3, "fd3")) to keep the image
+// apart from the logs. Set Image.Output to f as well so the
+// image is sized for the terminal f is connected to.
+func NewStdoutCanvas(f *os.File) *StdoutCanvas {
+	return &StdoutCanvas{out: f}
+}
+
+// StdoutCanvas renders the image to stdout, or the file
+// specified to NewStdoutCanvas.
 type StdoutCanvas struct {
-	b bytes.Buffer
+	b   bytes.Buffer
+	p   pacer
+	out *os.File //nil for stdout
+}
+
+// flush writes the buffered output.
+func (sc *StdoutCanvas) flush() error {
+	out := sc.out
+	if out == nil {
+		out = os.Stdout
+	}
+	_, err := out.Write(sc.b.Bytes())
+	sc.b.Reset()
+	return err
 }
 
 func (sc *StdoutCanvas) Paint(topColor, bottomColor uint8) error {
@@ -109,31 +178,348 @@ func (sc *StdoutCanvas) Paint(topColor, bottomColor uint8) error {
 	return nil
 }
 
+func (sc *StdoutCanvas) Print(text string) error {
+	sc.b.WriteString(text)
+	return nil
+}
+
 func (sc *StdoutCanvas) NewLine() error {
 	sc.b.WriteString("\n")
 	return nil
 }
 
 func (sc *StdoutCanvas) LineUp(count int) error {
-	fmt.Printf(sc.b.String())
-	sc.b.Reset()
+	err := sc.flush()
 	sc.b.WriteString(fmt.Sprintf("\033[%dA", count))
-	return nil
+	return err
 }
 
 func (sc *StdoutCanvas) Sleep(delayMS int) error {
-	time.Sleep(time.Millisecond * time.Duration(delayMS))
+	sc.p.begin()
+	if err := sc.flush(); err != nil {
+		return err
+	}
+	sc.p.sleep(delayMS)
 	return nil
 }
 
 func (sc *StdoutCanvas) Close() error {
-	fmt.Printf(sc.b.String())
+	return sc.flush()
+}
+
+// pacer times the Sleep calls of canvases displaying frames
+// in real time. The time spent rendering and writing a frame
+// counts towards the delay of the previous one instead of
+// adding to it, so animations play at their intended speed
+// however long the output takes, within the delays.
+type pacer struct {
+	next  time.Time //when the current delay is over
+	delay time.Duration
+}
+
+// begin is called before the output is displayed.
+func (p *pacer) begin() {
+	if now := time.Now(); p.next.IsZero() || now.Sub(p.next) > p.delay {
+		p.next = now //first frame, or too late to catch up
+	}
+}
+
+// sleep sleeps until the delay since the output started to be
+// displayed is over.
+func (p *pacer) sleep(delayMS int) {
+	p.delay = time.Millisecond * time.Duration(delayMS)
+	p.next = p.next.Add(p.delay)
+	time.Sleep(time.Until(p.next))
+}
+
+// NewWriterCanvas returns a WriterCanvas writing to w. If
+// bytesPerSecond is not zero, the output is throttled to that
+// rate so that frames trickle over slow links (e.g. a network
+// connection) instead of overwhelming their buffers in bursts.
+func NewWriterCanvas(w io.Writer, bytesPerSecond int) *WriterCanvas {
+	wc := &WriterCanvas{}
+	var throttle *throttle
+	if bytesPerSecond > 0 {
+		throttle = newThrottle(bytesPerSecond)
+	}
+	wc.w = bufio.NewWriter(writerFunc(func(p []byte) (int, error) {
+		written := 0
+		for len(p) > 0 {
+			chunk := len(p)
+			if throttle != nil {
+				chunk = throttle.wait(chunk)
+			}
+			n, err := w.Write(p[:chunk])
+			written += n
+			wc.n += int64(n)
+			if err != nil {
+				return written, err
+			}
+			p = p[chunk:]
+		}
+		return written, nil
+	}))
+	return wc
+}
+
+// throttle limits the rate at which bytes are written.
+type throttle struct {
+	rate    int       //bytes per second
+	start   time.Time //when the first byte was allowed
+	allowed int64     //bytes allowed since the start
+}
+
+func newThrottle(bytesPerSecond int) *throttle {
+	return &throttle{rate: bytesPerSecond}
+}
+
+// wait sleeps until the next chunk of at most n bytes may be
+// written and returns its size. Chunks are a tenth of the rate
+// so that the output flows evenly.
+func (t *throttle) wait(n int) int {
+	chunk := t.rate / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if n < chunk {
+		chunk = n
+	}
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	due := time.Duration(t.allowed * int64(time.Second) / int64(t.rate))
+	if d := due - time.Since(t.start); d > 0 {
+		time.Sleep(d)
+	}
+	t.allowed += int64(chunk)
+	return chunk
+}
+
+// WriterCanvas streams the image to a writer (e.g. a network
+// connection), sleeping between frames so that animations play
+// in real time. The output is buffered until the next Sleep or
+// Close.
+type WriterCanvas struct {
+	w *bufio.Writer
+	n int64 //bytes written
+	p pacer
+}
+
+// writerFunc adapts a function to io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func (wc *WriterCanvas) Paint(topColor, bottomColor uint8) error {
+	return wc.Print(makeTwoPixels(topColor, bottomColor))
+}
+
+func (wc *WriterCanvas) Print(text string) error {
+	_, err := wc.w.WriteString(text)
+	return err
+}
+
+func (wc *WriterCanvas) NewLine() error {
+	return wc.Print("\n")
+}
+
+func (wc *WriterCanvas) LineUp(count int) error {
+	return wc.Print(fmt.Sprintf("\033[%dA", count))
+}
+
+func (wc *WriterCanvas) Sleep(delayMS int) error {
+	wc.p.begin()
+	if err := wc.w.Flush(); err != nil {
+		return err
+	}
+	wc.p.sleep(delayMS)
 	return nil
 }
 
+func (wc *WriterCanvas) Close() error {
+	return wc.w.Flush()
+}
+
+// textCanvas accumulates the painted text in memory
+// and ignores cursor movement and delays.
+type textCanvas struct {
+	b bytes.Buffer
+}
+
+func (tc *textCanvas) Paint(topColor, bottomColor uint8) error {
+	tc.b.WriteString(makeTwoPixels(topColor, bottomColor))
+	return nil
+}
+
+func (tc *textCanvas) Print(text string) error {
+	tc.b.WriteString(text)
+	return nil
+}
+
+func (tc *textCanvas) NewLine() error {
+	tc.b.WriteString("\n")
+	return nil
+}
+
+func (tc *textCanvas) LineUp(count int) error  { return nil }
+func (tc *textCanvas) Sleep(delayMS int) error { return nil }
+func (tc *textCanvas) Close() error            { return nil }
+
+// NopCanvas discards the image and does not sleep between
+// frames. This is useful for benchmarking and testing the
+// rendering pipeline without terminal I/O.
+type NopCanvas struct{}
+
+func (NopCanvas) Paint(topColor, bottomColor uint8) error { return nil }
+func (NopCanvas) Print(text string) error                 { return nil }
+func (NopCanvas) NewLine() error                          { return nil }
+func (NopCanvas) LineUp(count int) error                  { return nil }
+func (NopCanvas) Sleep(delayMS int) error                 { return nil }
+func (NopCanvas) Close() error                            { return nil }
+
+// NewMultiCanvas returns a MultiCanvas that renders to all
+// the specified canvases.
+func NewMultiCanvas(canvases ...Canvas) *MultiCanvas {
+	return &MultiCanvas{canvases: canvases}
+}
+
+// MultiCanvas duplicates the rendering onto several canvases,
+// similar to io.MultiWriter. This renders the image on stdout
+// and exports it to a file in one go, for instance.
+// Every call is forwarded to all the canvases, even if one of
+// them fails, so that they stay in sync; the first error
+// encountered is returned.
+type MultiCanvas struct {
+	canvases []Canvas
+}
+
+func (mc *MultiCanvas) each(f func(c Canvas) error) error {
+	var first error
+	for _, c := range mc.canvases {
+		if err := f(c); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (mc *MultiCanvas) Paint(topColor, bottomColor uint8) error {
+	return mc.each(func(c Canvas) error { return c.Paint(topColor, bottomColor) })
+}
+
+func (mc *MultiCanvas) Print(text string) error {
+	return mc.each(func(c Canvas) error { return printText(c, text) })
+}
+
+func (mc *MultiCanvas) NewLine() error {
+	return mc.each(func(c Canvas) error { return c.NewLine() })
+}
+
+func (mc *MultiCanvas) LineUp(count int) error {
+	return mc.each(func(c Canvas) error { return c.LineUp(count) })
+}
+
+func (mc *MultiCanvas) Sleep(delayMS int) error {
+	return mc.each(func(c Canvas) error { return c.Sleep(delayMS) })
+}
+
+func (mc *MultiCanvas) Close() error {
+	return mc.each(func(c Canvas) error { return c.Close() })
+}
+
 func makeTwoPixels(topColor, bottomColor uint8) string {
 	//Use the 'lower half block' character (▄) for drawing as opposed to the 'upper half block' (▀) because if the
 	//terminal character height is odd then the terminal aligns ▀ one line below the top rendering a background shade
 	//on the top line of the character
-	return fmt.Sprintf("\x1b[48;5;%vm\x1b[38;5;%vm▄\x1b[0m", topColor, bottomColor)
+	return colorize("▄", bottomColor, topColor)
+}
+
+// RestoreSequence resets the colors, shows the cursor and
+// ends synchronized output, to restore a terminal when drawing
+// is interrupted (e.g. with Ctrl-C), whatever state the output
+// left it in.
+const RestoreSequence = "\x1b[0m\x1b[?25h\x1b[?2026l"
+
+// foreground returns text painted with the specified
+// foreground color.
+func foreground(text string, fgColor uint8) string {
+	return fmt.Sprintf("\x1b[38;5;%vm%s\x1b[0m", fgColor, text)
+}
+
+// background returns text painted with the specified
+// background color.
+func background(text string, bgColor uint8) string {
+	return fmt.Sprintf("\x1b[48;5;%vm%s\x1b[0m", bgColor, text)
+}
+
+// colorize returns text painted with the specified
+// foreground and background colors.
+func colorize(text string, fgColor, bgColor uint8) string {
+	return fmt.Sprintf("\x1b[48;5;%vm\x1b[38;5;%vm%s\x1b[0m", bgColor, fgColor, text)
+}
+
+// Colors of a pen.
+const (
+	defaultColor = -1 //the terminal's default color
+	anyColor     = -2 //any color, e.g. the foreground of spaces
+)
+
+// pen keeps track of the colors set on the terminal while a
+// frame is drawn, to only set the colors that change from one
+// character to the next instead of setting and resetting both
+// of them for every character (see Image.Reset).
+type pen struct {
+	fg, bg int //current colors
+	depth  int //colors drawn: 16 sets the system colors with the basic ANSI escape sequences (see BasicColors)
+}
+
+// newPen returns a pen drawing with the color depth (16, 256 or
+// terminal.TrueColor, see colorDepth).
+func newPen(depth int) *pen {
+	return &pen{fg: defaultColor, bg: defaultColor, depth: depth}
+}
+
+// paint returns text drawn with the foreground and background
+// colors (a palette color, a 24-bit color flagged with
+// trueColor, defaultColor or anyColor), preceded
+// by the escape sequences setting them if they changed.
+func (p *pen) paint(text string, fg, bg int) string {
+	var b strings.Builder
+	if bg != anyColor && bg != p.bg {
+		b.WriteString(p.code(bg, 40))
+		p.bg = bg
+	}
+	if fg != anyColor && fg != p.fg {
+		b.WriteString(p.code(fg, 30))
+		p.fg = fg
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// code returns the escape sequence setting the foreground
+// (base 30) or background (base 40) color.
+func (p *pen) code(c, base int) string {
+	switch {
+	case c == defaultColor:
+		return fmt.Sprintf("\x1b[%vm", base+9)
+	case c&trueColor != 0:
+		return fmt.Sprintf("\x1b[%v8;2;%v;%v;%vm", base/10, c>>16&0xff, c>>8&0xff, c&0xff)
+	case p.depth == 16 && c < 8:
+		return fmt.Sprintf("\x1b[%vm", base+c)
+	case p.depth == 16 && c < 16:
+		return fmt.Sprintf("\x1b[%vm", base+60+c-8) //bright colors
+	default:
+		return fmt.Sprintf("\x1b[%v8;5;%vm", base/10, c)
+	}
+}
+
+// reset returns the sequence resetting the colors if some are
+// set, or "".
+func (p *pen) reset() string {
+	if p.fg == defaultColor && p.bg == defaultColor {
+		return ""
+	}
+	p.fg, p.bg = defaultColor, defaultColor
+	return "\x1b[0m"
 }
@@ -0,0 +1,143 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Align is the horizontal alignment of a caption.
+type Align int
+
+const (
+	// AlignLeft aligns the lines of a caption with the left
+	// edge of the image.
+	AlignLeft Align = iota
+	// AlignCenter centers the lines of a caption under the
+	// image.
+	AlignCenter
+)
+
+// Aligns are the caption alignments by name.
+var Aligns = map[string]Align{
+	"left":   AlignLeft,
+	"center": AlignCenter,
+}
+
+// wide are the ranges of the East Asian Wide and Fullwidth
+// characters (CJK ideographs, Hangul, Kana, fullwidth forms,
+// emoji), which occupy two terminal columns.
+var wide = []struct{ lo, hi rune }{
+	{0x1100, 0x115f}, {0x231a, 0x231b}, {0x2329, 0x232a}, {0x23e9, 0x23ec},
+	{0x2614, 0x2615}, {0x2648, 0x2653}, {0x26aa, 0x26ab}, {0x26bd, 0x26be},
+	{0x2e80, 0x303e}, {0x3041, 0x33ff}, {0x3400, 0x4dbf}, {0x4e00, 0x9fff},
+	{0xa000, 0xa4cf}, {0xa960, 0xa97f}, {0xac00, 0xd7a3}, {0xf900, 0xfaff},
+	{0xfe10, 0xfe19}, {0xfe30, 0xfe6f}, {0xff00, 0xff60}, {0xffe0, 0xffe6},
+	{0x1f004, 0x1f004}, {0x1f0cf, 0x1f0cf}, {0x1f18e, 0x1f18e}, {0x1f200, 0x1f251},
+	{0x1f300, 0x1f64f}, {0x1f680, 0x1f6ff}, {0x1f900, 0x1f9ff}, {0x1fa70, 0x1faff},
+	{0x20000, 0x2fffd}, {0x30000, 0x3fffd},
+}
+
+// runeWidth returns the number of terminal columns occupied
+// by r: 0 for combining marks and control characters, 2 for
+// wide characters and 1 otherwise.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0x200b || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc):
+		return 0
+	case r < wide[0].lo:
+		return 1
+	}
+	for _, w := range wide {
+		if r >= w.lo && r <= w.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// textWidth returns the number of terminal columns occupied
+// by s.
+func textWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// WrapCaption breaks text into lines of at most width
+// terminal columns, aligned as specified (centered lines are
+// padded on the left with spaces). Lines are broken between
+// words, or within words that don't fit on a line by
+// themselves (e.g. CJK text, which has no spaces), and new
+// lines in text are preserved. Wide characters count as two
+// columns.
+func WrapCaption(text string, width int, align Align) []string {
+	if width < 2 {
+		width = 2 //room for a wide character
+	}
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line strings.Builder
+		lw := 0
+		breakLine := func() {
+			lines = append(lines, strings.TrimRight(line.String(), " "))
+			line.Reset()
+			lw = 0
+		}
+		for i, word := range strings.Fields(paragraph) {
+			ww := textWidth(word)
+			if i > 0 {
+				if lw+1+ww <= width {
+					line.WriteByte(' ')
+					lw++
+				} else {
+					breakLine()
+				}
+			}
+			for _, r := range word {
+				rw := runeWidth(r)
+				if lw+rw > width {
+					breakLine()
+				}
+				line.WriteRune(r)
+				lw += rw
+			}
+		}
+		breakLine()
+	}
+	if align == AlignCenter {
+		for i, l := range lines {
+			lines[i] = strings.Repeat(" ", (width-textWidth(l))/2) + l
+		}
+	}
+	return lines
+}
+
+// captionLines returns the lines of the caption as drawn
+// below the image.
+func (img *Image) captionLines() []string {
+	if img.Caption == "" {
+		return nil
+	}
+	return WrapCaption(img.Caption, img.Columns(), img.CaptionAlign)
+}
+
+// drawCaption prints the caption below the image, leaving the
+// cursor on the line below it.
+func (img *Image) drawCaption(canvas Canvas) error {
+	for _, l := range img.captionLines() {
+		if err := printText(canvas, l); err != nil {
+			return err
+		}
+		if err := canvas.NewLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
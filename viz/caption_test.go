@@ -0,0 +1,72 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTextWidth(t *testing.T) {
+	for s, expected := range map[string]int{
+		"car":              3,
+		"cafe\u0301":       4, //combining acute accent
+		"café":             4,
+		"日本語":              6,
+		"ｆｕｌｌ":             8,
+		"한국어 text":         11,
+		"smile \U0001F600": 8,
+	} {
+		if got := textWidth(s); got != expected {
+			t.Errorf("%q: expected a width of %v, got %v", s, expected, got)
+		}
+	}
+}
+
+func TestWrapCaption(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		width    int
+		align    Align
+		expected []string
+	}{
+		{"a red car", 20, AlignLeft, []string{"a red car"}},
+		{"a red car on the road", 10, AlignLeft, []string{"a red car", "on the", "road"}},
+		{"a red car", 13, AlignCenter, []string{"  a red car"}},
+		{"supercalifragilistic", 8, AlignLeft, []string{"supercal", "ifragili", "stic"}},
+		{"日本語のキャプション", 7, AlignLeft, []string{"日本語", "のキャ", "プショ", "ン"}},
+		{"日本 語", 4, AlignCenter, []string{"日本", " 語"}},
+		{"two\nlines", 20, AlignLeft, []string{"two", "lines"}},
+	} {
+		if got := WrapCaption(tc.text, tc.width, tc.align); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%q in %v columns: expected %q, got %q", tc.text, tc.width, tc.expected, got)
+		}
+	}
+}
+
+func TestDrawCaption(t *testing.T) {
+	img := Image{Filename: testData + "color_matrix.png", LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 16, Height: 16},
+		Caption: "a caption wider than the image"}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	lines := WrapCaption(img.Caption, img.Columns(), AlignLeft)
+	if len(lines) < 2 {
+		t.Fatalf("expected the caption to be wrapped to %v columns, got %q", img.Columns(), lines)
+	}
+	for _, l := range lines {
+		if rc.count(`print "`+l+`"`) != 1 {
+			t.Errorf("expected %q to be printed, got %v", l, rc.calls)
+		}
+	}
+	if last := rc.calls[len(rc.calls)-2:]; !reflect.DeepEqual(last, []string{"newline", "close"}) {
+		t.Errorf("expected the caption to end with a new line, got %v", last)
+	}
+}
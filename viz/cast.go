@@ -0,0 +1,99 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// NewCastCanvas returns a CastCanvas for a terminal of the
+// specified number of columns and lines.
+func NewCastCanvas(filename string, columns, lines int) (*CastCanvas, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	cc := &CastCanvas{file: f, writer: bufio.NewWriter(f)}
+	header, _ := json.Marshal(map[string]interface{}{
+		"version": 2,
+		"width":   columns,
+		"height":  lines,
+		"env":     map[string]string{"TERM": "xterm-256color"},
+	})
+	cc.write(header)
+	return cc, cc.writeError
+}
+
+// CastCanvas exports the image as an asciinema (v2) recording,
+// which can be played with `asciinema play`. The output of each
+// frame is recorded as an event timestamped according to the
+// delays, without actually sleeping.
+type CastCanvas struct {
+	file       *os.File
+	writer     *bufio.Writer
+	writeError error
+	b          bytes.Buffer
+	elapsedMS  int
+}
+
+func (cc *CastCanvas) write(line []byte) error {
+	if cc.writeError == nil {
+		if _, cc.writeError = cc.writer.Write(line); cc.writeError == nil {
+			cc.writeError = cc.writer.WriteByte('\n')
+		}
+	}
+	return cc.writeError
+}
+
+// flush records the pending output as an event.
+func (cc *CastCanvas) flush() error {
+	if cc.b.Len() == 0 {
+		return cc.writeError
+	}
+	event, _ := json.Marshal([]interface{}{float64(cc.elapsedMS) / 1000, "o", cc.b.String()})
+	cc.b.Reset()
+	return cc.write(event)
+}
+
+func (cc *CastCanvas) Paint(topColor, bottomColor uint8) error {
+	cc.b.WriteString(makeTwoPixels(topColor, bottomColor))
+	return cc.writeError
+}
+
+func (cc *CastCanvas) Print(text string) error {
+	cc.b.WriteString(text)
+	return cc.writeError
+}
+
+func (cc *CastCanvas) NewLine() error {
+	cc.b.WriteString("\r\n") //as output by a terminal
+	return cc.writeError
+}
+
+func (cc *CastCanvas) LineUp(count int) error {
+	cc.b.WriteString(fmt.Sprintf("\033[%dA", count))
+	return cc.writeError
+}
+
+func (cc *CastCanvas) Sleep(delayMS int) error {
+	err := cc.flush()
+	cc.elapsedMS += delayMS
+	return err
+}
+
+func (cc *CastCanvas) Close() error {
+	if err := cc.flush(); err != nil {
+		return err
+	}
+	if err := cc.writer.Flush(); err != nil {
+		return err
+	}
+	return cc.file.Close()
+}
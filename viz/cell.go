@@ -0,0 +1,124 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+// DefaultColor is the color of a Cell drawn with the
+// terminal's default foreground or background color.
+const DefaultColor = -1
+
+// Cell is a character of a terminal screen buffer, as
+// maintained by TUI frameworks.
+type Cell struct {
+	Glyph rune
+	// Indices of the foreground and background colors in Colors, or DefaultColor.
+	FG, BG int
+}
+
+// Compose writes the first frame of the image into buf, a
+// screen buffer indexed by line then column, with the top
+// left character of the image at line row and column col.
+// Characters falling outside buf are clipped and transparent
+// characters (see Transparent and ChromaKey) leave the cells
+// underneath untouched. This lets TUI applications render the
+// image along with the rest of their screen instead of writing
+// escape sequences to the terminal.
+func (img *Image) Compose(buf [][]Cell, row, col int) {
+	if len(img.frames) == 0 {
+		return
+	}
+	f := img.frames[0]
+	cw, ch := img.Mode.cellSize()
+	for y := 0; y < img.h; y = y + ch {
+		r := row + y/ch
+		if r < 0 || r >= len(buf) {
+			continue
+		}
+		for x := 0; x < img.w; x = x + cw {
+			c := col + x/cw
+			if c < 0 || c >= len(buf[r]) {
+				continue
+			}
+			if cell, ok := img.cell(f, x, y); ok {
+				buf[r][c] = cell
+			}
+		}
+	}
+}
+
+// Cells returns the characters of the ith frame (see
+// FrameCount) by line then column, as Draw paints them, or nil
+// if there is no such frame.
+// Transparent characters are the zero Cell, whose Glyph is 0.
+// With CompactRuns, runs of characters filled with a single
+// color are reported as the characters they stand for.
+//
+// Unlike the text drawn onto canvases, the cells don't depend on
+// how the escape sequences setting the colors are formatted or
+// optimized (see ResetMode), so they are the recommended way to
+// test rendering, e.g. against golden files.
+func (img *Image) Cells(i int) [][]Cell {
+	if i < 0 || i >= len(img.frames) {
+		return nil
+	}
+	f := img.frames[i]
+	cw, ch := img.Mode.cellSize()
+	grid := make([][]Cell, img.h/ch)
+	for r := range grid {
+		grid[r] = make([]Cell, img.w/cw)
+		for c := range grid[r] {
+			grid[r][c], _ = img.cell(f, c*cw, r*ch)
+		}
+	}
+	return grid
+}
+
+// cell returns the character whose top left pixel is at x,y
+// as paintCell draws it. ok is false if the character is
+// transparent.
+func (img *Image) cell(f frame, x, y int) (c Cell, ok bool) {
+	picture := f.picture
+	transparent := img.keyed != nil || f.transparent != nil
+	switch img.Mode {
+	case Sextants, Shades, Braille:
+		if transparent {
+			if text, skip := img.transparentCell(f, x, y, nil); skip {
+				if text != cursorForward {
+					return Cell{' ', DefaultColor, DefaultColor}, true //drawn as a space (see Transparent)
+				}
+				return Cell{}, false
+			}
+		}
+		if img.Mode == Sextants {
+			r, fg, bg := sextantCell(picture, x, y)
+			return Cell{r, int(fg), int(bg)}, true
+		}
+		if img.Mode == Braille {
+			return Cell{img.brailleRune(picture, x, y), img.dot, DefaultColor}, true
+		}
+		fg := DefaultColor
+		if img.ColorShades {
+			fg = int(picture[x][y])
+		}
+		return Cell{img.shadeRune(picture[x][y]), fg, DefaultColor}, true
+	default:
+		top, bottom := picture[x][y], picture[x][y+1]
+		if transparent {
+			clearTop, clearBottom := img.clear(f, x, y), img.clear(f, x, y+1)
+			switch {
+			case clearTop && clearBottom:
+				return Cell{}, false
+			case clearTop:
+				return Cell{'▄', int(bottom), DefaultColor}, true
+			case clearBottom:
+				return Cell{'▀', int(top), DefaultColor}, true
+			}
+		}
+		if img.Glyph == GlyphFullBlock {
+			return Cell{'█', int(average(top, bottom)), DefaultColor}, true
+		}
+		return Cell{'▄', int(bottom), int(top)}, true
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+// cursorForward moves the cursor a character to the right,
+// leaving the character underneath untouched.
+const cursorForward = "\x1b[C"
+
+// keyColors returns whether each palette color matches the
+// chroma key, or nil if ChromaKey isn't set.
+func (img *Image) keyColors() []bool {
+	if img.ChromaKey == nil {
+		return nil
+	}
+	key := color.RGBAModel.Convert(img.ChromaKey).(color.RGBA)
+	keyed := make([]bool, len(Colors))
+	for i, c := range Colors {
+		r, g, b, _ := c.RGBA()
+		dr := float64(r>>8) - float64(key.R)
+		dg := float64(g>>8) - float64(key.G)
+		db := float64(b>>8) - float64(key.B)
+		keyed[i] = math.Sqrt(dr*dr+dg*dg+db*db) <= img.ChromaTolerance
+	}
+	return keyed
+}
+
+// transparentCell returns the text drawn for the character
+// whose top left pixel is at x,y if some of its pixels are
+// transparent, because they match the chroma key or were
+// transparent in the image (see Transparent). ok is false if
+// the character should be painted as usual. The colors are set
+// with the pen if not nil (see ResetMode).
+func (img *Image) transparentCell(f frame, x, y int, p *pen) (text string, ok bool) {
+	cw, ch := img.Mode.cellSize()
+	if img.Mode == HalfBlocks {
+		top, bottom := img.clear(f, x, y), img.clear(f, x, y+1)
+		half, py := "", y
+		switch {
+		case top && bottom:
+			return cursorForward, true
+		case top:
+			half, py = "▄", y+1
+		case bottom:
+			half = "▀"
+		default:
+			return "", false
+		}
+		if p != nil {
+			return p.paint(half, f.pixel(x, py), defaultColor), true
+		}
+		return foreground(half, f.picture[x][py]), true
+	}
+
+	if img.Mode == Shades && !img.Transparent && f.transparent != nil && f.transparent[x][y] {
+		return " ", true //transparent in the image, drawn as a space (see Transparent)
+	}
+
+	//Other modes can't leave part of a character transparent,
+	//so the character is skipped only if all its pixels are.
+	for i := 0; i < cw; i++ {
+		for j := 0; j < ch; j++ {
+			if !img.clear(f, x+i, y+j) {
+				return "", false
+			}
+		}
+	}
+	return cursorForward, true
+}
+
+// clear reports whether the pixel at x,y is transparent.
+func (img *Image) clear(f frame, x, y int) bool {
+	return (img.keyed != nil && img.keyed[f.picture[x][y]]) || (f.transparent != nil && f.transparent[x][y])
+}
+
+// keepsAlpha reports whether which pixels are transparent is
+// recorded rather than compositing them onto Background.
+func (img *Image) keepsAlpha() bool {
+	return img.Transparent || img.Mode == Shades
+}
+
+// alphaThreshold returns the resolved AlphaThreshold.
+func (img *Image) alphaThreshold() float64 {
+	if img.AlphaThreshold == 0 {
+		return 0.5
+	}
+	return img.AlphaThreshold
+}
+
+// transparency returns which pixels of f scaled to w x h are
+// less opaque than the threshold (0-1), or nil if none is.
+func transparency(f image.Image, w, h int, threshold float64) [][]bool {
+	if o, ok := f.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return nil
+	}
+	scaled := resize.Resize(uint(w), uint(h), f, resize.Bilinear)
+	min := scaled.Bounds().Min
+	var transparent [][]bool
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			if _, _, _, a := scaled.At(min.X+x, min.Y+y).RGBA(); float64(a) < threshold*0xffff {
+				if transparent == nil {
+					transparent = make([][]bool, w)
+					for i := range transparent {
+						transparent[i] = make([]bool, h)
+					}
+				}
+				transparent[x][y] = true
+			}
+		}
+	}
+	return transparent
+}
@@ -8,13 +8,125 @@ package viz
 import (
 	"fmt"
 	"image/color"
+	"math"
 )
 
-// colors is a palette where the index corresponds to
+// Palette is a color palette that can be previewed on
+// a terminal.
+type Palette color.Palette
+
+// Colors is a palette where the index corresponds to
 // a 8 bit terminal color palette (i.e. 256 colors)
 var Colors color.Palette = make([]color.Color, 256)
 var _ = InitColors(256)
 
+// ColorMode is the range of terminal colors that pixels
+// can be mapped to.
+type ColorMode int
+
+const (
+	// AllColors maps pixels to any of the 256 colors.
+	AllColors ColorMode = iota
+	// NoSystemColors only uses the color cube and the grayscale
+	// ramp (colors 16-255) since terminal themes often redefine
+	// the 16 system colors.
+	NoSystemColors
+	// BasicColors only uses the 16 system colors, drawn with the
+	// escape sequences of the basic ANSI colors, for terminals
+	// that don't support 256 colors.
+	BasicColors
+)
+
+// ColorModes are the color modes by name.
+var ColorModes = map[string]ColorMode{
+	"all":      AllColors,
+	"nosystem": NoSystemColors,
+	"basic":    BasicColors,
+}
+
+// first returns the first color of the mode.
+func (m ColorMode) first() int {
+	if m == NoSystemColors {
+		return 16
+	}
+	return 0
+}
+
+// palette returns the colors of the mode, from the first one.
+func (m ColorMode) palette() Palette {
+	if m == BasicColors {
+		return Palette(Colors[:16])
+	}
+	return Palette(Colors[m.first():])
+}
+
+// allowed returns the colors of the mode (indices in Colors),
+// or nil if it allows all the colors from the first one.
+func (m ColorMode) allowed() []uint8 {
+	if m != BasicColors {
+		return nil
+	}
+	indices := make([]uint8, 16)
+	for i := range indices {
+		indices[i] = uint8(i)
+	}
+	return indices
+}
+
+// restrict maps the colors (indices in Colors, nil for all of
+// them) to the closest colors of the mode.
+func (m ColorMode) restrict(indices []uint8) []uint8 {
+	allowed := m.allowed()
+	if allowed == nil {
+		return indices
+	}
+	if indices == nil {
+		return allowed
+	}
+	palette := m.palette()
+	seen := make(map[uint8]bool)
+	var restricted []uint8
+	for _, c := range indices {
+		i := uint8(m.first() + palette.Index(Colors[c]))
+		if !seen[i] {
+			seen[i] = true
+			restricted = append(restricted, i)
+		}
+	}
+	return restricted
+}
+
+// Index returns the index of the palette color closest
+// to c.
+func (p Palette) Index(c color.Color) int {
+	return color.Palette(p).Index(c)
+}
+
+// Preview renders the palette as a grid of swatches, each
+// labeled with its index, to help debug how colors get
+// quantized. Colors that are not in the terminal palette
+// are shown using their closest terminal color. The canvas
+// is left open for the caller to draw more or close it.
+func (p Palette) Preview(canvas Canvas) error {
+	const columns = 16
+	for i, c := range p {
+		label := uint8(15)
+		if color.GrayModel.Convert(c).(color.Gray).Y > 128 {
+			label = 0 //dark label on a light swatch
+		}
+		code := uint8(Colors.Index(c))
+		if err := printText(canvas, colorize(fmt.Sprintf(" %3d ", i), label, code)); err != nil {
+			return err
+		}
+		if i%columns == columns-1 || i == len(p)-1 {
+			if err := canvas.NewLine(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // newColor returns an RGBA instance.
 func newColor(R, G, B uint8) color.Color {
 	return color.RGBA{R: R, G: G, B: B, A: 255}
@@ -78,13 +190,71 @@ func InitColors(num int) bool {
 	return num == 256
 }
 
+// SetSystemColors redefines the system colors (0-15) of
+// Colors, e.g. with the colors of the terminal's theme (see
+// terminal.Palette), so that images are quantized to the
+// colors the terminal actually displays. Only the first 16
+// colors are used.
+func SetSystemColors(colors []color.Color) {
+	for i := 0; i < len(colors) && i < 16; i++ {
+		r, g, b, _ := colors[i].RGBA()
+		Colors[i] = newColor(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+	}
+}
+
 // Draw renders all 256 colors to stdout for
 // debugging purposes
 func Draw() {
-	for i, _ := range Colors {
-		fmt.Print(fmt.Sprintf("\x1b[48;5;%vm   \x1b[0m", i))
-		if i%32 == 0 {
-			fmt.Println("")
-		}
+	canvas := &StdoutCanvas{}
+	Palette(Colors).Preview(canvas)
+	canvas.Close()
+}
+
+// Colormap maps values between 0 and 1 to colors by
+// interpolating between evenly spaced color stops.
+type Colormap []color.RGBA
+
+// At returns the color for v, which is clamped to [0, 1] (NaN
+// counting as 0), or transparent black if the colormap has no
+// stops.
+func (cm Colormap) At(v float64) color.RGBA {
+	if len(cm) == 0 {
+		return color.RGBA{}
+	}
+	if math.IsNaN(v) {
+		v = 0
+	}
+	v = math.Max(0, math.Min(1, v)) * float64(len(cm)-1)
+	i := int(v)
+	if i >= len(cm)-1 {
+		return cm[len(cm)-1]
+	}
+	t := v - float64(i)
+	lerp := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + t*(float64(b)-float64(a))))
+	}
+	c1, c2 := cm[i], cm[i+1]
+	return color.RGBA{R: lerp(c1.R, c2.R), G: lerp(c1.G, c2.G), B: lerp(c1.B, c2.B), A: 255}
+}
+
+// Built-in colormaps.
+var (
+	Viridis = Colormap{
+		{68, 1, 84, 255}, {71, 44, 122, 255}, {59, 81, 139, 255}, {44, 113, 142, 255}, {33, 145, 140, 255},
+		{40, 174, 128, 255}, {94, 201, 98, 255}, {173, 220, 48, 255}, {253, 231, 37, 255},
+	}
+	Magma = Colormap{
+		{0, 0, 4, 255}, {28, 16, 68, 255}, {79, 18, 123, 255}, {129, 37, 129, 255}, {181, 54, 122, 255},
+		{229, 80, 100, 255}, {251, 135, 97, 255}, {254, 194, 135, 255}, {252, 253, 191, 255},
 	}
+	Jet = Colormap{
+		{0, 0, 128, 255}, {0, 0, 255, 255}, {0, 255, 255, 255}, {255, 255, 0, 255}, {255, 0, 0, 255}, {128, 0, 0, 255},
+	}
+)
+
+// Colormaps are the built-in colormaps by name.
+var Colormaps = map[string]Colormap{
+	"viridis": Viridis,
+	"magma":   Magma,
+	"jet":     Jet,
 }
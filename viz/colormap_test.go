@@ -0,0 +1,39 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestColormapAt(t *testing.T) {
+	black, white := color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+	red := color.RGBA{255, 0, 0, 255}
+	for _, tc := range []struct {
+		cm       Colormap
+		v        float64
+		expected color.RGBA
+	}{
+		{Colormap{black, white}, 0, black},
+		{Colormap{black, white}, 1, white},
+		{Colormap{black, white}, 0.5, color.RGBA{128, 128, 128, 255}},
+		{Colormap{black, red, white}, 0.5, red}, //on a stop
+		{Colormap{black, red, white}, 0.75, color.RGBA{255, 128, 128, 255}},
+		{Colormap{black, white}, -1, black}, //clamped
+		{Colormap{black, white}, 2, white},
+		{Colormap{black, white}, math.Inf(-1), black},
+		{Colormap{black, white}, math.Inf(1), white},
+		{Colormap{black, white}, math.NaN(), black},
+		{Colormap{red}, 0.5, red},
+		{Colormap{}, 0.5, color.RGBA{}},
+	} {
+		if got := tc.cm.At(tc.v); got != tc.expected {
+			t.Errorf("%v at %v: expected %v, got %v", tc.cm, tc.v, tc.expected, got)
+		}
+	}
+}
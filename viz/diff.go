@@ -0,0 +1,85 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"os"
+
+	"github.com/nfnt/resize"
+)
+
+// Diff returns the per-pixel difference between two images,
+// as the largest absolute difference between their red, green,
+// blue and alpha values, to spot the changes between e.g. two
+// screenshots. Drawn with a Colormap, it renders as a heatmap
+// of the changes.
+//
+// Images of different dimensions are compared at the common
+// size made of the smaller width and the smaller height, to
+// which the larger ones are scaled (and stretched if their
+// aspect ratios differ).
+func Diff(a, b image.Image) *image.Gray {
+	ab, bb := a.Bounds(), b.Bounds()
+	w, h := ab.Dx(), ab.Dy()
+	if bb.Dx() < w {
+		w = bb.Dx()
+	}
+	if bb.Dy() < h {
+		h = bb.Dy()
+	}
+	a, b = commonSize(a, w, h), commonSize(b, w, h)
+	ab, bb = a.Bounds(), b.Bounds()
+
+	d := image.NewGray(image.Rect(0, 0, w, h))
+	diff := func(u, v uint32) uint32 {
+		if u > v {
+			return u - v
+		}
+		return v - u
+	}
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			m := diff(r1, r2)
+			for _, v := range []uint32{diff(g1, g2), diff(b1, b2), diff(a1, a2)} {
+				if v > m {
+					m = v
+				}
+			}
+			d.SetGray(x, y, color.Gray{uint8(m >> 8)})
+		}
+	}
+	return d
+}
+
+// commonSize scales m to w x h unless it already is.
+func commonSize(m image.Image, w, h int) image.Image {
+	if m.Bounds().Dx() == w && m.Bounds().Dy() == h {
+		return m
+	}
+	return resize.Resize(uint(w), uint(h), m, resize.Lanczos3)
+}
+
+// DiffFiles decodes two image files (the first frame of
+// animations) and returns their difference (see Diff).
+func DiffFiles(a, b string) (*image.Gray, error) {
+	var images [2]image.Image
+	for i, filename := range []string{a, b} {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		images[i], _, err = image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Diff(images[0], images[1]), nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(a, a.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+	b := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(b, b.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+	b.Set(2, 3, color.RGBA{255, 155, 255, 255})
+
+	d := Diff(a, b)
+	if d.Bounds() != a.Bounds() {
+		t.Fatalf("expected the bounds of the images, got %v", d.Bounds())
+	}
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			expected := uint8(0)
+			if x == 2 && y == 3 {
+				expected = 100
+			}
+			if got := d.GrayAt(x, y).Y; got != expected {
+				t.Errorf("pixel %v,%v: expected %v, got %v", x, y, expected, got)
+			}
+		}
+	}
+
+	//Mismatched dimensions are compared at the smaller width and height.
+	big := image.NewRGBA(image.Rect(10, 10, 26, 14))
+	draw.Draw(big, big.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+	d = Diff(a, big)
+	if d.Bounds() != image.Rect(0, 0, 8, 4) {
+		t.Fatalf("expected 8x4 pixels, got %v", d.Bounds())
+	}
+	for i, v := range d.Pix {
+		if v > 1 {
+			t.Fatalf("pixel %v: expected no difference, got %v", i, v)
+		}
+	}
+}
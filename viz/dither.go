@@ -0,0 +1,139 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image/color"
+	"math"
+)
+
+// DitherMode is the way colors that are not in the terminal
+// palette are approximated with patterns of palette colors.
+type DitherMode int
+
+const (
+	// DitherNone maps each pixel to the closest palette color,
+	// which can show bands where colors change gradually.
+	DitherNone DitherMode = iota
+	// DitherFloydSteinberg diffuses the error of each pixel
+	// onto its neighbors, for the most accurate gradients.
+	DitherFloydSteinberg
+	// DitherBayer2x2, DitherBayer4x4 and DitherBayer8x8 offset
+	// the pixels by a tiled threshold map (ordered dithering),
+	// which is stateless and gives a regular, retro pattern.
+	// Larger maps render more intermediate shades.
+	DitherBayer2x2
+	DitherBayer4x4
+	DitherBayer8x8
+)
+
+// DitherModes are the dither modes by name.
+var DitherModes = map[string]DitherMode{
+	"none":           DitherNone,
+	"floydsteinberg": DitherFloydSteinberg,
+	"bayer2":         DitherBayer2x2,
+	"bayer4":         DitherBayer4x4,
+	"bayer8":         DitherBayer8x8,
+}
+
+// bayerSpread is the range of the offsets of ordered dithering,
+// about the distance between the levels of the color cube.
+const bayerSpread = 51
+
+// ditherer adjusts the pixels of a w x h picture before they
+// are mapped to palette colors, visiting them column by column.
+type ditherer struct {
+	threshold [][]float64  //tiled offsets in [-0.5, 0.5), for ordered dithering
+	spread    float64      //range of the offsets of ordered dithering
+	errs      [][3]float64 //error diffused onto each pixel, indexed by x*h+y
+	w, h      int
+}
+
+// newDitherer returns a ditherer for mode onto the palette, or
+// nil for DitherNone.
+func newDitherer(mode DitherMode, w, h int, palette Palette) *ditherer {
+	switch mode {
+	case DitherFloydSteinberg:
+		return &ditherer{errs: make([][3]float64, w*h), w: w, h: h}
+	case DitherBayer2x2:
+		return &ditherer{threshold: bayer(2), spread: ditherSpread(palette)}
+	case DitherBayer4x4:
+		return &ditherer{threshold: bayer(4), spread: ditherSpread(palette)}
+	case DitherBayer8x8:
+		return &ditherer{threshold: bayer(8), spread: ditherSpread(palette)}
+	}
+	return nil
+}
+
+// bayer returns the n x n Bayer threshold map (n being a power
+// of 2) normalized to [-0.5, 0.5). Each map is built from the
+// one of half its size as [[4M, 4M+2], [4M+3, 4M+1]].
+func bayer(n int) [][]float64 {
+	m := [][]int{{0}}
+	for size := 1; size < n; size *= 2 {
+		next := make([][]int, 2*size)
+		for x := range next {
+			next[x] = make([]int, 2*size)
+			for y := range next[x] {
+				next[x][y] = 4*m[x%size][y%size] + [2][2]int{{0, 3}, {2, 1}}[x/size][y/size]
+			}
+		}
+		m = next
+	}
+	t := make([][]float64, n)
+	for x := range t {
+		t[x] = make([]float64, n)
+		for y := range t[x] {
+			t[x][y] = (float64(m[x][y])+0.5)/float64(n*n) - 0.5
+		}
+	}
+	return t
+}
+
+// adjust returns the color to map to the palette for the pixel
+// at x,y.
+func (d *ditherer) adjust(x, y int, c color.Color) color.Color {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	v := [3]float64{float64(rgba.R), float64(rgba.G), float64(rgba.B)}
+	if d.threshold != nil {
+		n := len(d.threshold)
+		offset := d.threshold[x%n][y%n] * d.spread
+		for i := range v {
+			v[i] += offset
+		}
+	} else {
+		for i := range v {
+			v[i] += d.errs[x*d.h+y][i]
+		}
+	}
+	clamp := func(v float64) uint8 { return uint8(math.Max(0, math.Min(255, math.Round(v)))) }
+	return color.RGBA{clamp(v[0]), clamp(v[1]), clamp(v[2]), rgba.A}
+}
+
+// diffuse spreads the difference between the adjusted color
+// of the pixel at x,y and the palette color it was mapped to
+// onto the pixels that haven't been visited yet: the next one
+// in the column and the three nearest in the next column.
+func (d *ditherer) diffuse(x, y int, want, got color.Color) {
+	if d.errs == nil {
+		return
+	}
+	wr, wg, wb, _ := want.RGBA()
+	gr, gg, gb, _ := got.RGBA()
+	e := [3]float64{float64(wr>>8) - float64(gr>>8), float64(wg>>8) - float64(gg>>8), float64(wb>>8) - float64(gb>>8)}
+	for _, n := range []struct {
+		dx, dy int
+		weight float64
+	}{{0, 1, 7.0 / 16}, {1, -1, 3.0 / 16}, {1, 0, 5.0 / 16}, {1, 1, 1.0 / 16}} {
+		nx, ny := x+n.dx, y+n.dy
+		if nx >= d.w || ny < 0 || ny >= d.h {
+			continue
+		}
+		for i := range e {
+			d.errs[nx*d.h+ny][i] += e[i] * n.weight
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image/color"
+	"math"
+)
+
+// ChannelAdjust adjusts the red, green and blue channels of
+// each pixel independently. The zero value leaves colors
+// unchanged.
+type ChannelAdjust struct {
+	R, G, B Channel
+}
+
+// Channel adjusts a color channel to value*Scale + Offset,
+// where the value is between 0 and 255. A nil Scale leaves
+// the value unscaled, so that a Scale of 0 clears the channel.
+type Channel struct {
+	Scale  *float64
+	Offset float64
+}
+
+func (ch Channel) apply(v uint8) uint8 {
+	scale := 1.0
+	if ch.Scale != nil {
+		scale = *ch.Scale
+	}
+	return uint8(math.Max(0, math.Min(255, math.Round(float64(v)*scale+ch.Offset))))
+}
+
+func (ca ChannelAdjust) apply(c color.Color) color.Color {
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	nc.R, nc.G, nc.B = ca.R.apply(nc.R), ca.G.apply(nc.G), ca.B.apply(nc.B)
+	return nc
+}
+
+// overlay blends c linearly toward Overlay by OverlayAlpha,
+// keeping its opacity.
+func (img *Image) overlay(c color.Color) color.Color {
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	o := color.NRGBAModel.Convert(img.Overlay).(color.NRGBA)
+	blend := func(v, ov uint8) uint8 {
+		return uint8(math.Round(float64(v)*(1-img.OverlayAlpha) + float64(ov)*img.OverlayAlpha))
+	}
+	nc.R, nc.G, nc.B = blend(nc.R, o.R), blend(nc.G, o.G), blend(nc.B, o.B)
+	return nc
+}
+
+// filter returns the adjustments to apply to each scaled
+// pixel before mapping it to a terminal color, or nil if
+// there are none.
+func (img *Image) filter() func(c color.Color) color.Color {
+	var filters []func(c color.Color) color.Color
+	if img.ChannelAdjust != (ChannelAdjust{}) {
+		filters = append(filters, img.ChannelAdjust.apply)
+	}
+	if img.Colormap != nil {
+		filters = append(filters, func(c color.Color) color.Color {
+			y := color.GrayModel.Convert(c).(color.Gray).Y
+			return img.Colormap.At(float64(y) / 255)
+		})
+	}
+	if img.Overlay != nil && img.OverlayAlpha > 0 {
+		filters = append(filters, img.overlay)
+	}
+	if img.Mode == Braille {
+		filters = append(filters, img.threshold)
+	} else if img.GrayLevels > 0 {
+		filters = append(filters, grayscale)
+	}
+
+	if len(filters) == 0 {
+		return nil
+	}
+	return func(c color.Color) color.Color {
+		for _, f := range filters {
+			c = f(c)
+		}
+		return c
+	}
+}
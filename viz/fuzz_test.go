@@ -0,0 +1,53 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzInit checks that decoding and scaling untrusted images
+// fails gracefully instead of panicking or exhausting memory,
+// with both the default and tighter limits.
+func FuzzInit(f *testing.F) {
+	var seeds [][]byte
+	for _, name := range []string{"color_matrix.png", "disposalNone.gif", "disposalNoneTransparency.gif", "cmyk.jpeg"} {
+		b, err := os.ReadFile(testData + name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, b)
+	}
+	seeds = append(seeds, encodeWebPAnimation(4, 4, 3, testWebPFrames), []byte("P3\n2 2\n255\n0 0 0 255 255 255 0 0 0 255 255 255\n"))
+	for _, b := range seeds {
+		f.Add(b, false)
+		f.Add(b, true)
+	}
+
+	filename := filepath.Join(f.TempDir(), "fuzz")
+	f.Fuzz(func(t *testing.T, data []byte, limited bool) {
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		img := Image{
+			Filename:        filename,
+			LoopCount:       2,
+			DelayMultiplier: 1,
+			UserWidth:       16,
+		}
+		if limited {
+			img.MaxPixels, img.MaxFrames = 1<<16, 16
+		}
+		if err := img.Init(); err != nil {
+			return
+		}
+		if err := img.Draw(NopCanvas{}); err != nil {
+			t.Error("expecting no error drawing an initialized image, got", err)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+// clearPixel marks the pixels of the GIF canvas that no frame
+// has drawn yet (see EncodeGIF).
+const clearPixel = -1
+
+// EncodeGIF writes the frames of the image to w as a GIF with
+// one pixel per rendered pixel (as with EncodePNG), keeping
+// their delays and the loop count. This makes a smaller copy
+// of an animation at the rendered resolution.
+//
+// The GIF is optimized as the terminal output is: each frame
+// after the first only covers the rectangle of the pixels that
+// changed, where the unchanged pixels are transparent, and has
+// a local palette of the colors it uses. Transparent pixels
+// (see Transparent and ChromaKey) keep showing the previous
+// frame, as they do in the terminal.
+func (img *Image) EncodeGIF(w io.Writer) error {
+	if len(img.frames) == 0 {
+		return errors.New("image is not initialized")
+	}
+	shown := make([][]int, img.w)
+	for x := range shown {
+		shown[x] = make([]int, img.h)
+		for y := range shown[x] {
+			shown[x][y] = clearPixel
+		}
+	}
+	g := &gif.GIF{
+		LoopCount: gifLoopCount(img.loops()),
+		Config:    image.Config{ColorModel: color.Palette(Colors), Width: img.w, Height: img.h},
+	}
+	for _, f := range img.frames {
+		//Work out the pixels shown once the frame is drawn, and the rectangle of those that change.
+		next := make([][]int, img.w)
+		r := image.Rectangle{}
+		for x := range next {
+			next[x] = make([]int, img.h)
+			for y := range next[x] {
+				next[x][y] = shown[x][y]
+				if !img.clear(f, x, y) {
+					next[x][y] = int(f.picture[x][y])
+				}
+				if next[x][y] != shown[x][y] {
+					r = r.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		if r.Empty() {
+			r = image.Rect(0, 0, 1, 1)
+		}
+		g.Image = append(g.Image, gifFrame(shown, next, r))
+		g.Delay = append(g.Delay, (f.delay+5)/10) //centiseconds
+		g.Disposal = append(g.Disposal, gif.DisposalNone)
+		shown = next
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// gifFrame returns the rectangle r of a GIF frame that changes
+// the shown pixels to the next ones, with a palette of the
+// colors it uses plus a transparent color for the unchanged
+// pixels if there is room for it.
+func gifFrame(shown, next [][]int, r image.Rectangle) *image.Paletted {
+	var palette color.Palette
+	indices := make(map[int]uint8)
+	add := func(c int) {
+		if _, ok := indices[c]; !ok {
+			indices[c] = uint8(len(palette))
+			if c == clearPixel {
+				palette = append(palette, color.Transparent)
+			} else {
+				palette = append(palette, Colors[c])
+			}
+		}
+	}
+	keep := false
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			if next[x][y] == shown[x][y] {
+				keep = true
+			} else {
+				add(next[x][y])
+			}
+		}
+	}
+	if keep && len(palette) < 256 {
+		add(clearPixel)
+	}
+
+	p := image.NewPaletted(r, palette)
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			c := next[x][y]
+			if c == shown[x][y] {
+				if i, ok := indices[clearPixel]; ok {
+					p.SetColorIndex(x, y, i)
+					continue
+				}
+				if c == clearPixel {
+					continue //no room for transparency, which is only lost on pixels never drawn
+				}
+			}
+			p.SetColorIndex(x, y, indices[c])
+		}
+	}
+	return p
+}
+
+// gifLoopCount returns the loop count of the NETSCAPE2.0
+// extension of a GIF played loopCount times (see loopCount).
+func gifLoopCount(loopCount int) int {
+	switch {
+	case loopCount == LoopForever:
+		return 0
+	case loopCount <= 1:
+		return -1 //no extension: played once
+	default:
+		return loopCount - 1
+	}
+}
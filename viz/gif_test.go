@@ -0,0 +1,66 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+func TestEncodeGIF(t *testing.T) {
+	img := newTestImage("disposalBackground.gif", 3, t)
+	var b bytes.Buffer
+	if err := img.EncodeGIF(&b); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	g, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatal("expecting a valid GIF, got", err)
+	}
+	if len(g.Image) != len(img.frames) {
+		t.Fatalf("expected %v frames, got %v", len(img.frames), len(g.Image))
+	}
+	if g.LoopCount != 2 {
+		t.Errorf("expected the animation to be restarted twice, got loop count %v", g.LoopCount)
+	}
+	if g.Config.Width != img.w || g.Config.Height != img.h {
+		t.Errorf("expected %vx%v pixels, got %vx%v", img.w, img.h, g.Config.Width, g.Config.Height)
+	}
+
+	//Compositing the frames gives back the rendered frames.
+	canvas := image.NewRGBA(image.Rect(0, 0, img.w, img.h))
+	for i, m := range g.Image {
+		if i > 0 && m.Bounds() == canvas.Bounds() {
+			t.Errorf("frame %v: expected only the changed pixels, got %v", i, m.Bounds())
+		}
+		if len(m.Palette) > 256 {
+			t.Errorf("frame %v: expected at most 256 colors, got %v", i, len(m.Palette))
+		}
+		draw.Draw(canvas, m.Bounds(), m, m.Bounds().Min, draw.Over)
+		if d := (img.frames[i].delay + 5) / 10; g.Delay[i] != d {
+			t.Errorf("frame %v: expected delay %v, got %v", i, d, g.Delay[i])
+		}
+		expected, _ := img.FrameImage(i)
+		for x := 0; x < img.w; x++ {
+			for y := 0; y < img.h; y++ {
+				if Colors.Index(canvas.At(x, y)) != Colors.Index(expected.At(x, y)) {
+					t.Fatalf("frame %v, pixel %v,%v: expected %v, got %v", i, x, y, expected.At(x, y), canvas.At(x, y))
+				}
+			}
+		}
+	}
+}
+
+func TestGIFLoopCount(t *testing.T) {
+	for loopCount, expected := range map[int]int{LoopForever: 0, 1: -1, 3: 2} {
+		if got := gifLoopCount(loopCount); got != expected {
+			t.Errorf("loop count %v: expected %v, got %v", loopCount, expected, got)
+		}
+	}
+}
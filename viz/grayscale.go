@@ -0,0 +1,62 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image/color"
+	"sort"
+)
+
+// grayColors returns the terminal colors (indices in Colors,
+// from the first one) closest to n evenly spaced gray levels
+// from black to white (at least black and white).
+func grayColors(n, first int) []uint8 {
+	if n < 2 {
+		n = 2
+	}
+	palette := Colors[first:]
+	seen := make(map[uint8]bool)
+	var indices []uint8
+	for k := 0; k < n; k++ {
+		y := uint8((k*255 + (n-1)/2) / (n - 1))
+		i := uint8(first + palette.Index(color.Gray{y}))
+		if !seen[i] {
+			seen[i] = true
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// grayscale converts c to its luminance.
+func grayscale(c color.Color) color.Color {
+	return color.GrayModel.Convert(c)
+}
+
+// ditherSpread returns the range of the offsets of ordered
+// dithering onto the palette: the widest gap between its
+// levels if it only has grays (e.g. with GrayLevels), so that
+// every shade between two levels is approximated, or about the
+// distance between the levels of the color cube otherwise.
+func ditherSpread(palette Palette) float64 {
+	levels := make([]int, 0, len(palette))
+	for _, c := range palette {
+		r, g, b, _ := c.RGBA()
+		if r != g || g != b {
+			return bayerSpread
+		}
+		levels = append(levels, int(r>>8))
+	}
+	if len(levels) < 2 {
+		return bayerSpread
+	}
+	sort.Ints(levels)
+	gap := 0
+	for i := 1; i < len(levels); i++ {
+		gap = maxInt(gap, levels[i]-levels[i-1])
+	}
+	return float64(gap)
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	"golang.org/x/image/riff"
+)
+
+// Info describes an image file.
+type Info struct {
+	Width, Height int
+	Format        string //as registered with the image package (e.g. gif)
+	Frames        int
+}
+
+// String formats the info as "WxH format frames".
+func (i Info) String() string {
+	return fmt.Sprintf("%vx%v %v %v", i.Width, i.Height, i.Format, i.Frames)
+}
+
+// Identify reads the properties of an image file from its
+// header, without decoding the pixels. The frames of GIFs and
+// animated WebPs are counted by skipping through their blocks.
+func Identify(filename string) (Info, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Info{}, err
+	}
+	defer file.Close()
+
+	svg, err := isSVG(file)
+	if err != nil {
+		return Info{}, err
+	}
+	if svg {
+		return identifySVG(file)
+	}
+	cfg, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Width: cfg.Width, Height: cfg.Height, Format: format, Frames: 1}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+	switch format {
+	case "gif":
+		var l gifLayout
+		l, err = scanGIF(bufio.NewReader(file))
+		info.Frames = l.frames
+	case "webp":
+		var animated bool
+		if animated, err = isAnimatedWebP(file); animated {
+			info.Frames, err = countWebPFrames(file)
+		}
+	}
+	return info, err
+}
+
+var errInvalidGIF = errors.New("invalid GIF")
+
+// isGIF reports whether the file starts with a GIF header and
+// rewinds it.
+func isGIF(r io.ReadSeeker) (bool, error) {
+	var magic [6]byte
+	n, err := io.ReadFull(r, magic[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	gif := n == len(magic) && (string(magic[:]) == "GIF87a" || string(magic[:]) == "GIF89a")
+	_, err = r.Seek(0, io.SeekStart)
+	return gif, err
+}
+
+// gifLayout is the arrangement of the frames of a GIF.
+type gifLayout struct {
+	screen image.Rectangle //logical screen
+	bounds image.Rectangle //union of the frame rectangles
+	frames int
+}
+
+// scanGIF reads the layout of a GIF by skipping through its
+// blocks, without decoding the frames.
+func scanGIF(r *bufio.Reader) (gifLayout, error) {
+	//Header (6 bytes) and logical screen descriptor (7 bytes)
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return gifLayout{}, err
+	}
+	if err := skipColorTable(r, header[10]); err != nil {
+		return gifLayout{}, err
+	}
+
+	l := gifLayout{screen: image.Rect(0, 0, int(binary.LittleEndian.Uint16(header[6:])), int(binary.LittleEndian.Uint16(header[8:])))}
+	for {
+		introducer, err := r.ReadByte()
+		if err != nil {
+			return gifLayout{}, err
+		}
+		switch introducer {
+		case 0x21: //extension
+			if _, err := r.ReadByte(); err != nil { //label
+				return gifLayout{}, err
+			}
+		case 0x2c: //image descriptor
+			l.frames++
+			var descriptor [9]byte
+			if _, err := io.ReadFull(r, descriptor[:]); err != nil {
+				return gifLayout{}, err
+			}
+			left, top := int(binary.LittleEndian.Uint16(descriptor[0:])), int(binary.LittleEndian.Uint16(descriptor[2:]))
+			width, height := int(binary.LittleEndian.Uint16(descriptor[4:])), int(binary.LittleEndian.Uint16(descriptor[6:]))
+			l.bounds = l.bounds.Union(image.Rect(left, top, left+width, top+height))
+			if err := skipColorTable(r, descriptor[8]); err != nil {
+				return gifLayout{}, err
+			}
+			if _, err := r.ReadByte(); err != nil { //LZW minimum code size
+				return gifLayout{}, err
+			}
+		case 0x3b: //trailer
+			return l, nil
+		default:
+			return gifLayout{}, errInvalidGIF
+		}
+		if err := skipSubBlocks(r); err != nil {
+			return gifLayout{}, err
+		}
+	}
+}
+
+// skipColorTable skips the color table announced by the
+// packed fields of a descriptor.
+func skipColorTable(r *bufio.Reader, fields byte) error {
+	if fields&0x80 == 0 {
+		return nil
+	}
+	_, err := r.Discard(3 << (fields&0x07 + 1))
+	return err
+}
+
+// skipSubBlocks skips data sub-blocks up to the terminator.
+func skipSubBlocks(r *bufio.Reader) error {
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if _, err := r.Discard(int(size)); err != nil {
+			return err
+		}
+	}
+}
+
+// countWebPFrames counts the ANMF chunks of an animated WebP.
+func countWebPFrames(r io.Reader) (int, error) {
+	_, chunks, err := riff.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	frames := 0
+	for {
+		id, _, _, err := chunks.Next()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if id == (riff.FourCC{'A', 'N', 'M', 'F'}) {
+			frames++
+		}
+	}
+}
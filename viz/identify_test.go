@@ -0,0 +1,42 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentify(t *testing.T) {
+	webp := filepath.Join(t.TempDir(), "test.webp")
+	if err := os.WriteFile(webp, encodeWebPAnimation(4, 6, 0, testWebPFrames), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(testData + "disposalNone.gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for filename, expected := range map[string]Info{
+		testData + "color_matrix.png": {1350, 258, "png", 1},
+		testData + "disposalNone.gif": {g.Config.Width, g.Config.Height, "gif", len(g.Image)},
+		webp:                          {4, 6, "webp", len(testWebPFrames)},
+	} {
+		got, err := Identify(filename)
+		if err != nil {
+			t.Errorf("%v: expecting no error, got %v", filename, err)
+		} else if got != expected {
+			t.Errorf("%v: expected %v, got %v", filename, expected, got)
+		}
+	}
+}
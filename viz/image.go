@@ -6,171 +6,1570 @@
 package viz
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"image"
+	"image/color"
 	"image/draw"
 	"image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
+	"io/fs"
+	"log"
 	"math"
 	"os"
+	"strings"
+	"time"
 
+	_ "github.com/codeliveroil/img/netpbm"
 	"github.com/codeliveroil/img/terminal"
 	"github.com/nfnt/resize"
 )
 
+// DefaultMaxBytes is the default maximum size of the images
+// read into memory (see Image.MaxBytes).
+const DefaultMaxBytes = 256 << 20
+
+// DefaultMaxPixels and DefaultMaxFrames are the default limits
+// for decoding images (see Image.MaxPixels).
+const (
+	DefaultMaxPixels = 1 << 26
+	DefaultMaxFrames = 1 << 12
+)
+
+// BrowserMinDelay is the delay in milliseconds for which
+// browsers display the frames of GIFs with delays of 10ms or
+// less, which is a sensible MinDelay.
+const BrowserMinDelay = 100
+
+// Special loop counts.
+const (
+	// LoopForever loops the animation until the program is interrupted.
+	LoopForever = -1
+	// LoopAuto uses the loop count recorded in the GIF's NETSCAPE2.0 extension (or the ANIM chunk of an animated WebP).
+	LoopAuto = -2
+)
+
+// SlowTerminal is the action taken when the terminal
+// can't keep pace with an animation.
+type SlowTerminal int
+
+const (
+	// SlowTerminalWarn logs a warning to the Logger.
+	SlowTerminalWarn SlowTerminal = iota
+	// SlowTerminalSkip skips frames that would be displayed
+	// too late, to preserve the intended timing.
+	SlowTerminalSkip
+	// SlowTerminalIgnore plays the animation as is.
+	SlowTerminalIgnore
+)
+
 // Image is a representation of a (multi) picture
-// image.
+// image. Create images with NewImage.
 type Image struct {
 	// Path to image file.
 	Filename string
+	// Filesystem to open Filename from (e.g. an embed.FS holding images embedded in the program) instead of
+	// the operating system's. Files that can't seek are read into memory.
+	FS fs.FS
+	// Encoded image (e.g. read from the clipboard) to decode instead of opening Filename, which then only
+	// names the image in messages.
+	Data []byte
+	// Stream to read the encoded image from (e.g. standard input or the body of an HTTP response) instead
+	// of opening Filename, which then only names the image in messages. As decoding seeks through the image
+	// (e.g. to scan the frames of GIFs before decoding them), the stream is read into memory once, up to
+	// MaxBytes, and kept for the next Inits.
+	Reader io.Reader
 	// Specify a file name to export the image to a shell script.
 	// For instance, this script can be used to display an image for motd.
 	ExportFilename string
-	// Specify a loop count to animate GIFs more than once or set to 0 to render the first picture only.
+	// Specify a loop count to animate GIFs (and animated WebPs) more than once or set to 0 to render the first picture only.
+	// LoopForever and LoopAuto are also accepted.
 	LoopCount int
+	// Play the animation in reverse.
+	Reverse bool
 	//Specify a decimal point multiplier to increase or decrease the speed of the GIF.
 	DelayMultiplier float64
+	// Clamp the delay of each frame (in milliseconds, before applying DelayMultiplier) to at least MinDelay and
+	// at most MaxDelay, e.g. to slow down GIFs with 0ms delays, which play as a blur (browsers show them for
+	// BrowserMinDelay), or to shorten excessively long pauses. Each is ignored if zero.
+	MinDelay int
+	MaxDelay int
 	// Use specified width instead of automatically computing it. Height will be calculated according to the aspect ratio.
 	// This is useful in SSH sessions where screen resizes are not registered automatically.
 	UserWidth int
+	// Use specified dimensions instead of automatically computing them. Takes precedence over UserWidth.
+	Size Size
+	// The way pixels are drawn with characters. Defaults to HalfBlocks.
+	Mode RenderMode
+	// The character drawn by HalfBlocks. Defaults to GlyphHalfBlock.
+	Glyph Glyph
+	// Color the characters of the Shades mode with the pixel colors instead of the terminal's foreground color.
+	ColorShades bool
+	// Draw the Shades mode with ASCII characters ( .:-=+*#%@) instead of shade characters, for terminals
+	// without Unicode support.
+	ASCII bool
+	// Luminance (0-1) above which the pixels of the Braille mode are drawn as dots, or below which on light
+	// backgrounds, where the dots are dark. The image is dithered according to Dither beforehand, so that gray
+	// areas become patterns of dots rather than all dots or none (e.g. DitherFloydSteinberg for photographs).
+	// Defaults to 0.5 if zero. MaxColors and GrayLevels are ignored.
+	DotThreshold float64
+	// Color of the dots of the Braille mode. Defaults to the terminal's foreground color if nil.
+	DotColor color.Color
+	// Scale the image to the specified number of terminal lines, computing the width according to the aspect
+	// ratio but not exceeding the terminal width (e.g. to keep images small in a chat or a log).
+	// As each line holds two pixels, this is Size.Height = 2*Lines clamped to the terminal width.
+	// Ignored if UserWidth or Size is specified.
+	Lines int
+	// Draw the image mirrored side by side, one above the other or into four quadrants, for artistic
+	// displays. The mirrored image fits the same size limits as the image would. Defaults to MirrorNone.
+	Mirror Symmetry
+	// Round the width of the image in terminal columns up to a multiple of PadMultiple (e.g. 2 for an even
+	// width) by padding it on the right with Background, so that images line up in grids and montages.
+	// Ignored if less than 2.
+	PadMultiple int
+	// Render the image at its original size even if it's larger than the terminal (see Scroll).
+	NoShrink bool
+	// Use the full terminal height instead of leaving a line for the shell prompt that shows up after the image.
+	// This is useful when rendering non-interactively (e.g. piping the output to another program).
+	FullHeight bool
+	// Percentage (0-100] of the terminal width and height to fill, leaving room for surrounding content.
+	// Defaults to 100. Ignored if UserWidth or Size is specified.
+	FillPercent float64
+	// Number of terminal lines already taken above the image (e.g. by the banners of an MOTD), so that the
+	// image fits in the remaining height instead of pushing the prompt or the banners off screen (see
+	// terminal.CursorRow to detect it). Ignored if UserWidth or Size is specified.
+	UsedLines int
+	// Maximum number of frames per second at which animations are played, to limit the CPU spent redrawing
+	// animations with tiny delays (e.g. when looping forever). Frames are displayed for at least 1/MaxFPS
+	// seconds, after their delays are multiplied by DelayMultiplier. Ignored if zero.
+	MaxFPS float64
+	// Action taken by Draw if rendering the first frame takes longer than the average delay between frames,
+	// meaning the animation can't keep pace (e.g. over a slow SSH connection). Defaults to SlowTerminalWarn.
+	SlowTerminal SlowTerminal
+	// Advanced: dispose of every frame of GIFs with the specified method (gif.DisposalNone,
+	// gif.DisposalBackground or gif.DisposalPrevious) instead of the one they declare, to play animations
+	// authored with the wrong disposal methods (e.g. leaving ghosts of previous frames) correctly.
+	// Ignored if zero.
+	ForceDisposal byte
+	// Limits for decoding untrusted images, to avoid exhausting memory: Init fails for images with more
+	// pixels (width x height) or GIFs with more frames. The dimensions are checked before decoding.
+	// Default to DefaultMaxPixels and DefaultMaxFrames if zero.
+	MaxPixels int
+	MaxFrames int
+	// Maximum size in bytes of the images read into memory from Reader or from files of FS that can't seek.
+	// Defaults to DefaultMaxBytes if zero.
+	MaxBytes int64
+	// Terminal the image is rendered to (see NewStdoutCanvas), whose size is used to compute the dimensions
+	// of the image. Defaults to the terminal of the standard streams.
+	Output *os.File
+	// Logger receives diagnostic messages (e.g. the chosen size) if not nil.
+	Logger *log.Logger
+	// Range of terminal colors that pixels are mapped to. Defaults to AllColors; NoSystemColors only uses the
+	// color cube and the grayscale ramp (colors 16-255) since terminal themes often redefine the 16 system
+	// colors, at the cost of slightly less accurate colors.
+	ColorMode ColorMode
+	// Deprecated: equivalent to setting ColorMode to NoSystemColors.
+	ExcludeSystemColors bool
+	// Use 256 colors even if the terminal the image is drawn to doesn't support them according to the TERM
+	// environment variable (see terminal.ColorDepth). Otherwise, Init falls back to BasicColors on terminals
+	// with 16 colors, and to uncolored ASCII characters with Shades on terminals without colors (e.g. dumb),
+	// rather than emitting escape sequences that would show up as garbage. Only images drawn to a terminal
+	// fall back (see Output), not those redirected to a file.
+	ForceColors bool
+	// Draw the HalfBlocks mode with the exact colors of the pixels as 24-bit escape sequences instead of the
+	// closest of the 256 terminal colors. Init enables it on terminals that advertise 24-bit colors with
+	// COLORTERM (see terminal.ColorDepth) unless ForceColors is set or the colors are reduced (BasicColors,
+	// MaxColors, GrayLevels) or dithered. Exports other than the drawn text (e.g. PNG, GIF) keep the 256 colors.
+	TrueColor bool
+	// Colorize the image by mapping the luminance of each pixel through a colormap (e.g. Viridis) instead
+	// of rendering its own color. This is useful to preview heatmaps and depth images.
+	Colormap Colormap
+	// Adjust the red, green and blue channels independently (e.g. to correct the white balance).
+	ChannelAdjust ChannelAdjust
+	// Blend every pixel toward the Overlay color by OverlayAlpha (0-1) before mapping it to a terminal color,
+	// e.g. to dim or tint the image to render it as a faint background behind text. Unlike ChannelAdjust,
+	// this pulls the colors toward a specific hue. Ignored if Overlay is nil.
+	Overlay      color.Color
+	OverlayAlpha float64
+	// Filter with which the image is scaled. Defaults to ResampleAuto, which picks a faster filter for
+	// animations with many frames.
+	Resampling Resampling
+	// Reduce the image to at most MaxColors colors, chosen from its own colors with median-cut over the first
+	// scaled frame and mapped to the closest terminal colors, for cleaner output on images with a limited
+	// natural palette (e.g. flat illustrations). The frames of animations share the colors so that they
+	// don't flicker. With Transparent, the colors are chosen from the opaque pixels only, so that none is
+	// spent on the background the transparent pixels are composited onto and cutouts (e.g. sprites) keep both
+	// their colors and their shape. Ignored if zero.
+	MaxColors int
+	// Render the image in shades of gray only, quantized to GrayLevels evenly spaced levels from black to
+	// white (e.g. 4 or 16 for an e-ink look) drawn with the closest terminal grays, or shade characters with
+	// Shades. Combine with ordered dithering (e.g. DitherBayer4x4) to approximate the shades in between.
+	// MaxColors is ignored. Ignored if zero.
+	GrayLevels int
+	// Sample the pixels of the image directly at the rendered size (nearest-neighbor) instead of resizing it,
+	// for an instant low-fidelity preview of huge images that can be rendered again at full quality after.
+	// Resampling is ignored.
+	Preview bool
+	// Keep only every Decimate-th pixel of every Decimate-th row of the source image before scaling it, for
+	// near-instant previews of enormous images on weak hardware (e.g. embedded devices). Unlike Preview, the
+	// decimated image is still resized as usual, so larger values are coarser but cheaper: the pixels in
+	// between are skipped rather than averaged, so thin lines and text may vanish, fine patterns alias into
+	// moiré, and the image turns blocky once it has fewer pixels than the rendered size. Decoding takes as
+	// long. Ignored if 0 or 1.
+	Decimate int
+	// Approximate the colors that aren't in the terminal palette with patterns of palette colors, to reduce
+	// banding in gradients (e.g. skies). Defaults to DitherNone.
+	Dither DitherMode
+	// Color that transparent pixels are composited onto. If nil, black or white is used
+	// depending on whether the terminal background is dark or light (see terminal.Dark).
+	Background color.Color
+	// Draw the pixels whose color is within ChromaTolerance of ChromaKey as transparent, leaving the terminal
+	// content underneath visible (e.g. to overlay a subject shot against a green screen). The colors are
+	// compared after scaling, by their Euclidean distance in 8-bit RGB. Ignored if ChromaKey is nil.
+	// Note that transparent cells of an animation keep showing the previous frame.
+	ChromaKey       color.Color
+	ChromaTolerance float64
+	// Crop the borders of uniform color (e.g. the white margins of scans and screenshots) before scaling,
+	// so that the subject fills the terminal. The rows and columns on the edges whose pixels are all within
+	// TrimTolerance of the color of the top left pixel (compared by their Euclidean distance in 8-bit RGBA) are
+	// removed. The borders are found on the first frame of animations and cropped on all of them. Images of a
+	// single color are left as is, and SVGs aren't trimmed.
+	AutoTrim      bool
+	TrimTolerance float64
+	// Leave the transparent pixels (less opaque than AlphaThreshold) of the image undrawn instead of compositing
+	// them onto Background, so the terminal content underneath shows through. With HalfBlocks, a character with
+	// one transparent pixel draws the other one with a half block (▀ or ▄) in the foreground color.
+	// With Shades, transparent pixels are drawn as spaces even if Transparent is false, so that the silhouette
+	// of the subject (e.g. a logo) stands out instead of the shade of Background.
+	Transparent bool
+	// Opacity (0-1) below which pixels are transparent (see Transparent). Defaults to 0.5 if zero.
+	AlphaThreshold float64
+	// Draw runs of identical characters filled with a single color (e.g. the flat regions of logos and
+	// screenshots) as spaces on a colored background with one escape sequence per run instead of one per
+	// character, which makes the output several times smaller and faster to display. The color of most of
+	// an opaque image, if any, is filled in at once with erase sequences and its characters skipped over by
+	// moving the cursor, so that flat areas spanning several lines cost next to nothing. Only applies to
+	// HalfBlocks with GlyphHalfBlock.
+	CompactRuns bool
+	// When the colors of the characters are reset, trading robustness for the size of the output.
+	// Defaults to ResetCells; the other modes make the output several times smaller, and even more so
+	// with CompactRuns.
+	Reset ResetMode
+	// How the cursor is moved back to draw each frame of animations over the previous one. Defaults to
+	// RedrawLineUp; the other modes work around terminals and multiplexers (e.g. tmux, screen, serial
+	// consoles) where moving the cursor up glitches.
+	Redraw RedrawMode
+	// Text printed below the image once it's drawn (after the animation, if any), wrapped to the width of
+	// the image and aligned as specified. Wide (e.g. CJK) characters count as two columns.
+	Caption      string
+	CaptionAlign Align
+	// Overlay the number of the frame being played and the time elapsed since the first one was drawn on the
+	// top left of animations (e.g. "12/44 1.30s"), as plain text over the top row, to check the order and
+	// timing of the frames.
+	FrameCounter bool
+	// Draw the lines of each frame from the bottom up, moving the cursor up after each one, for displays
+	// anchored to the bottom of the terminal (e.g. status bars) where the bottom of the image should show
+	// first. The cursor still ends up below the image. Ignored with FrameCounter and Reveal.
+	BottomUp bool
+	// Ring the terminal bell (BEL) once Draw has played the image LoopCount times and drawn the caption, and
+	// call OnComplete (if not nil) once it has closed the canvas, so that wrapper scripts and callers can chain
+	// actions after playback. Neither happens if Draw fails or is interrupted.
+	Bell       bool
+	OnComplete func()
+	// Call OnProgress (if not nil) as Init prepares each frame, with the number of frames prepared so far out of
+	// the total, e.g. to report the progress of decoding a large animation.
+	OnProgress func(done, total int)
+	// Draw a spinner with the progress of Init on stderr, on a line that is cleared once Init returns (i.e. before
+	// Draw). Ignored if stderr is not a terminal.
+	Spinner bool
+	// Draw the first frame of animations onto FirstFrameCanvas (if not nil) as soon as Init has scaled it,
+	// before scaling the remaining frames, so that large animations show up at once over slow connections.
+	// Draw then plays the animation over that frame, so FirstFrameCanvas should be the canvas passed to Draw.
+	FirstFrameCanvas Canvas
+	// Reveal static images incrementally, line by line or column by column, as if they were typed in.
+	// RevealDelay is the time between lines or columns. Animations are drawn as usual.
+	Reveal      RevealDirection
+	RevealDelay time.Duration
+	// Delay after revealing each line or column instead of RevealDelay (if not nil), given the index of the
+	// line or column (from 0) and their number, e.g. EaseDelays for a reveal that speeds up or slows down.
+	RevealDelays func(step, steps int) time.Duration
 
-	frames []frame
-	h      int
-	w      int
+	frames  []frame
+	h       int
+	w       int
+	pad     int                          //pixels of padding included in w
+	bg      color.Color                  //resolved background color
+	trim    image.Rectangle              //resolved AutoTrim bounds, relative to the top left of frames, empty if not trimming
+	dot     int                          //resolved DotColor, defaultColor if nil
+	keyed   []bool                       //palette colors matching ChromaKey, nil if not keying
+	interp  resize.InterpolationFunction //resolved Resampling
+	reduced []uint8                      //terminal colors allowed by MaxColors or GrayLevels, nil if not reducing
+	stats   Stats
+	spin    *spinner //drawn by Init if Spinner is set, nil otherwise
+	drawn   bool     //whether the first frame was drawn onto FirstFrameCanvas
+	done    int      //frames prepared by Init so far, for OnProgress
+	total   int      //frames being prepared by Init
+	format  string   //name of the decoder of the image, e.g. "png"
+	read    []byte   //content of Reader, nil until read
+}
+
+// Size specifies the dimensions of the rendered image in
+// pixels, where a terminal line is two pixels tall (as with
+// HalfBlocks; other render modes fill the same number of
+// characters).
+// If only one dimension is specified, the other is computed
+// according to the aspect ratio. If both are specified, the
+// image is stretched to exactly Width x Height, or fit within
+// them if Fit is set.
+type Size struct {
+	Width  int
+	Height int
+	Fit    bool
 }
 
 type frame struct {
-	picture [][]uint8
-	delay   int
+	picture     [][]uint8
+	transparent [][]bool  //transparent pixels, nil if none (see Image.Transparent)
+	rgb         [][]int32 //24-bit colors of the pixels, nil unless drawn with TrueColor
+	delay       int
+	hash        uint64
+}
+
+// newFrame returns a frame for the picture and
+// computes its hash.
+func newFrame(picture [][]uint8, delay int) frame {
+	h := fnv.New64a()
+	for _, col := range picture {
+		h.Write(col)
+	}
+	return frame{picture: picture, delay: delay, hash: h.Sum64()}
+}
+
+// equals reports whether both frames have the same picture.
+func (f frame) equals(other frame) bool {
+	if f.hash != other.hash || len(f.picture) != len(other.picture) || (f.transparent == nil) != (other.transparent == nil) ||
+		(f.rgb == nil) != (other.rgb == nil) {
+		return false
+	}
+	for x := range f.picture {
+		if !bytes.Equal(f.picture[x], other.picture[x]) {
+			return false
+		}
+		for y := 0; f.rgb != nil && y < len(f.rgb[x]); y++ {
+			if f.rgb[x][y] != other.rgb[x][y] {
+				return false
+			}
+		}
+		if f.transparent == nil {
+			continue
+		}
+		for y := range f.transparent[x] {
+			if f.transparent[x][y] != other.transparent[x][y] {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // Init initializes the visualization framework
 // for drawing the image.
 func (img *Image) Init() (err error) {
+	if err := img.validate(); err != nil {
+		return err
+	}
+	img.initTerminal()
+	img.stats = Stats{}
+	defer img.recordInit(time.Now())
+	defer img.startSpinner()()
+
 	//Open image
-	file, err := os.Open(img.Filename)
+	file, err := img.open()
 	if err != nil {
 		return err
 	}
-	firstFrame, imgFmt, err := image.Decode(file)
+	svg, err := isSVG(file)
 	if err != nil {
+		file.Close()
 		return err
 	}
-	file.Close()
-
-	//Identify scale
-	iw := firstFrame.Bounds().Max.X
-	ih := firstFrame.Bounds().Max.Y
-
-	scale := 1.0
-	if img.UserWidth > 0 {
-		scale = float64(img.UserWidth) / float64(iw)
+	if svg {
+		defer file.Close()
+		return img.initSVG(file)
+	}
+	if err := img.checkConfig(file); err != nil {
+		file.Close()
+		return err
+	}
+	var anim *webpAnimation
+	var firstFrame image.Image
+	var imgFmt string
+	animated, err := isAnimatedWebP(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if animated {
+		anim, err = decodeWebPAnimation(file, img.maxFrames())
+		imgFmt = "webp"
 	} else {
-		tw, th, err := terminal.Size()
-		if err != nil {
+		var src io.Reader = file
+		var gifFile bool
+		if gifFile, err = isGIF(file); err != nil {
+			file.Close()
 			return err
 		}
-		if imgFmt == "gif" && img.LoopCount > 0 {
-			tw = 40
-		}
-		th = (th * 2) - 1       //-1 to account for the terminal prompt ($/#) that'll show up after the image is displayed
-		if tw < iw || th < ih { //scale down the image to fit the terminal
-			scaleW := float64(tw) / float64(iw)
-			scaleH := float64(th) / float64(ih)
-			scale = math.Min(scaleW, scaleH)
+		if gifFile {
+			if src, _, err = img.gifSource(file); err != nil {
+				src = file //let the decoder report malformed GIFs
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					file.Close()
+					return err
+				}
+			}
 		}
+		//CMYK JPEGs, including Adobe's inverted ones, decode to *image.CMYK,
+		//which is converted to RGB correctly when scaling.
+		firstFrame, imgFmt, err = image.Decode(src)
 	}
-
-	img.w = int(math.Floor(scale * float64(iw)))
-	img.h = int(math.Floor(scale * float64(ih)))
-	if img.h%2 != 0 { //make the height even since we will be painting y and y+1 in every iteration
-		img.h -= 1
+	file.Close()
+	if err != nil {
+		return err
 	}
+	img.logf("decoded %v as %v", img.Filename, imgFmt)
+	img.format = imgFmt
 
-	//Scale image frames
-	appendImg := func(f image.Image, delayMS int) {
-		scaled := resize.Resize(uint(img.w), uint(img.h), f, resize.Lanczos3)
-		pic := make([][]uint8, img.w)
-		for x := 0; x < img.w; x++ {
-			pic[x] = make([]uint8, img.h)
-			for y := 0; y < img.h; y++ {
-				clr := scaled.At(x, y)
-				x256Clr := Colors.Index(clr)
-				pic[x][y] = uint8(x256Clr)
-			}
-		}
-
-		img.frames = append(img.frames, frame{
-			picture: pic,
-			delay:   int(math.Ceil(float64(delayMS) * img.DelayMultiplier)), //GIFs will take long to render, so reduce the delay to achieve intended delay.
+	img.initBackground()
+	if anim != nil {
+		anim.composite(img.fill(), func(canvas image.Image, _ int) bool {
+			firstFrame = canvas
+			return false
 		})
 	}
 
-	if imgFmt == "gif" && img.LoopCount > 0 {
-		file, err := os.Open(img.Filename)
+	//Identify scale
+	iw, ih := img.initTrim(firstFrame)
+	if err := img.initSize(iw, ih, (imgFmt == "gif" || anim != nil) && img.LoopCount != 0); err != nil {
+		return err
+	}
+	img.frames = nil
+
+	if imgFmt == "gif" && img.LoopCount != 0 {
+		file, err := img.open()
 		if err != nil {
 			return err
 		}
-		g, err := gif.DecodeAll(file)
+		src, layout, err := img.gifSource(file)
 		if err != nil {
+			file.Close()
 			return err
 		}
-		iw = g.Config.Width
-		ih = g.Config.Height
+		if layout.frames > img.maxFrames() { //checked before decoding the frames
+			file.Close()
+			return fmt.Errorf("image has %v frames, more than the maximum of %v", layout.frames, img.maxFrames())
+		}
+		if !layout.bounds.In(layout.screen) {
+			img.logf("GIF frames span %v, exceeding the %vx%v screen; clipping them",
+				layout.bounds, layout.screen.Dx(), layout.screen.Dy())
+		}
+		g, err := gif.DecodeAll(src)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if img.LoopCount == LoopAuto {
+			img.LoopCount = loopCount(g)
+			img.logf("GIF loop count is %v", img.LoopCount)
+		}
+		iw = layout.screen.Dx()
+		ih = layout.screen.Dy()
+		img.initResampling(len(g.Image), iw, ih)
 
-		var prev *image.RGBA
-		canvas := image.NewRGBA(image.Rect(0, 0, iw, ih))
+		canvas := img.newCanvas(iw, ih)
 		for i, frame := range g.Image {
-			draw.Draw(canvas, canvas.Bounds(), frame, image.ZP, draw.Over)
-			appendImg(canvas, g.Delay[i]*10)
-			switch g.Disposal[i] {
+			disposal := g.Disposal[i]
+			if img.ForceDisposal != 0 {
+				disposal = img.ForceDisposal
+			}
+			var prev *image.RGBA
+			if disposal == gif.DisposalPrevious {
+				prev = image.NewRGBA(canvas.Rect)
+				copy(prev.Pix, canvas.Pix)
+			}
+			//the transparent index decodes to a transparent color, so the canvas shows through it
+			r := frame.Bounds().Intersect(canvas.Bounds())
+			draw.Draw(canvas, r, frame, r.Min, draw.Over)
+			img.appendImg(canvas, g.Delay[i]*10)
+			if i == 0 && len(g.Image) > 1 {
+				if err := img.drawFirstFrame(); err != nil {
+					file.Close()
+					return err
+				}
+			}
+			switch disposal {
 			case gif.DisposalBackground:
-				canvas = image.NewRGBA(image.Rect(0, 0, iw, ih))
-				fallthrough
-			case gif.DisposalNone:
-				prev = &(*canvas)
+				canvas = img.newCanvas(iw, ih)
 			case gif.DisposalPrevious:
-				if prev != nil {
-					canvas = prev
-				}
+				canvas = prev //as it was before the frame
 			}
 		}
 		file.Close()
+	} else if anim != nil && img.LoopCount != 0 {
+		if img.LoopCount == LoopAuto {
+			img.LoopCount = anim.loopCount
+			if img.LoopCount == 0 {
+				img.LoopCount = LoopForever
+			}
+			img.logf("WebP loop count is %v", img.LoopCount)
+		}
+		img.initResampling(len(anim.frames), anim.width, anim.height)
+		anim.composite(img.fill(), func(canvas image.Image, delayMS int) bool {
+			img.appendImg(canvas, delayMS)
+			if len(anim.frames) > 1 && !img.drawn {
+				err = img.drawFirstFrame()
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
 	} else {
 		img.LoopCount = 1 //override incorrect user input for single picture images
-		appendImg(firstFrame, 0)
+		if o, ok := firstFrame.(interface{ Opaque() bool }); ok && !o.Opaque() && !img.keepsAlpha() {
+			canvas := img.newCanvas(firstFrame.Bounds().Dx(), firstFrame.Bounds().Dy())
+			draw.Draw(canvas, canvas.Bounds(), firstFrame, firstFrame.Bounds().Min, draw.Over)
+			firstFrame = canvas
+		}
+		img.initResampling(1, iw, ih)
+		img.appendImg(firstFrame, 0)
 	}
+	img.logf("prepared %v frame(s)", len(img.frames))
+
+	return nil
+}
 
+// InitFrames initializes the image from frames built in
+// memory (e.g. assembled from a directory of pictures) instead
+// of Filename. Each frame is displayed for the corresponding
+// delay, which is rounded up to the millisecond and multiplied
+// by DelayMultiplier like the delays recorded in GIFs (so
+// leave it at 1 for the delays to be honored as is).
+// The frames are scaled according to the dimensions of the
+// first one and composited onto Background if transparent.
+// As with GIFs, a LoopCount of 0 keeps the first frame only;
+// LoopAuto plays the frames once.
+func (img *Image) InitFrames(frames []image.Image, delays []time.Duration) error {
+	if len(frames) == 0 {
+		return errors.New("no frames")
+	}
+	if len(delays) != len(frames) {
+		return fmt.Errorf("expecting %v delays for %v frames, got %v", len(frames), len(frames), len(delays))
+	}
+	if err := img.validate(); err != nil {
+		return err
+	}
+	if img.MaxFrames > 0 && len(frames) > img.MaxFrames {
+		return fmt.Errorf("image has %v frames, more than the maximum of %v", len(frames), img.MaxFrames)
+	}
+	img.initTerminal()
+	img.stats = Stats{}
+	defer img.recordInit(time.Now())
+	defer img.startSpinner()()
+	img.format = ""
+	switch img.LoopCount {
+	case 0:
+		frames = frames[:1]
+	case LoopAuto:
+		img.LoopCount = 1
+	}
+
+	img.initBackground()
+	b := frames[0].Bounds()
+	w, h := img.initTrim(frames[0])
+	if err := img.initSize(w, h, len(frames) > 1); err != nil {
+		return err
+	}
+	img.frames = nil
+	img.initResampling(len(frames), b.Dx(), b.Dy())
+	for i, f := range frames {
+		canvas := img.newCanvas(b.Dx(), b.Dy())
+		draw.Draw(canvas, canvas.Bounds(), f, f.Bounds().Min, draw.Over)
+		img.appendImg(canvas, int((delays[i]+time.Millisecond-1)/time.Millisecond))
+		if i == 0 && len(frames) > 1 {
+			if err := img.drawFirstFrame(); err != nil {
+				return err
+			}
+		}
+	}
+	if img.LoopCount == 0 {
+		img.LoopCount = 1
+	}
+	img.logf("prepared %v frame(s)", len(img.frames))
 	return nil
 }
 
+// initTerminal falls back to the colors and characters that
+// the terminal supports unless ForceColors is set.
+func (img *Image) initTerminal() {
+	if img.ForceColors {
+		return
+	}
+	if img.Output != nil && !terminal.IsTerminal(img.Output) || img.Output == nil && !terminal.Interactive() {
+		return
+	}
+	switch terminal.ColorDepth() {
+	case 0:
+		if img.Mode != Shades || !img.ASCII || img.ColorShades || img.Transparent || img.TrueColor {
+			img.logf("the terminal doesn't support colors; falling back to ASCII characters")
+			img.Mode, img.Glyph, img.ColorShades, img.Transparent, img.ASCII = Shades, GlyphHalfBlock, false, false, true
+			img.DotThreshold, img.DotColor, img.TrueColor = 0, nil, false
+		}
+	case terminal.TrueColor:
+		if !img.TrueColor && img.Mode == HalfBlocks && img.colorMode() != BasicColors && img.MaxColors == 0 &&
+			img.GrayLevels == 0 && img.Dither == DitherNone {
+			img.logf("the terminal supports 24-bit colors")
+			img.TrueColor = true
+		}
+	case 16:
+		if img.colorMode() != BasicColors || img.TrueColor {
+			img.logf("the terminal only supports 16 colors; falling back to the basic colors")
+			img.ColorMode, img.ExcludeSystemColors, img.TrueColor = BasicColors, false, false
+		}
+	}
+}
+
+// initBackground resolves the background color, along with
+// the colors matching the chroma key and the color of dots.
+func (img *Image) initBackground() {
+	img.bg = img.Background
+	if img.bg == nil {
+		img.bg = color.White
+		if terminal.Dark() {
+			img.bg = color.Black
+		}
+	}
+	img.keyed = img.keyColors()
+	img.dot = defaultColor
+	if img.DotColor != nil {
+		img.dot = img.firstColor() + img.colorMode().palette().Index(img.DotColor)
+	}
+}
+
+// fill returns the color of the canvas that frames are
+// composited onto.
+func (img *Image) fill() color.Color {
+	if img.keepsAlpha() {
+		return color.Transparent
+	}
+	return img.bg
+}
+
+// newCanvas returns a w x h canvas filled with img.fill().
+func (img *Image) newCanvas(w, h int) *image.RGBA {
+	c := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(c, c.Bounds(), image.NewUniform(img.fill()), image.ZP, draw.Src)
+	return c
+}
+
+// initSize computes the size of the rendered image for an
+// iw x ih picture (see dimensions).
+func (img *Image) initSize(iw, ih int, animated bool) error {
+	if iw <= 0 || ih <= 0 {
+		return errors.New("image has no pixels")
+	}
+	iw, ih = img.Mirror.size(iw, ih)
+	w, h, err := img.dimensions(iw, ih, animated)
+	if err != nil {
+		return err
+	}
+	cw, ch := img.Mode.cellSize()
+	img.w, img.h = w*cw, h/2*ch
+	img.logf("scaling %vx%v image to %vx%v", iw, ih, img.w, img.h)
+	img.pad = 0
+	if m := img.PadMultiple; m > 1 && w%m != 0 {
+		img.pad = (m - w%m) * cw
+		img.w += img.pad
+		img.logf("padding the image to %v columns", img.w/cw)
+	}
+	return nil
+}
+
+// recordInit records the statistics of Init, which started
+// at start.
+func (img *Image) recordInit(start time.Time) {
+	img.stats.Decode = time.Since(start) - img.stats.Scale
+	img.stats.Frames = len(img.frames)
+}
+
+// appendImg scales f and appends it as a frame displayed for
+// delayMS (before applying DelayMultiplier).
+func (img *Image) appendImg(f image.Image, delayMS int) {
+	defer func(start time.Time) { img.stats.Scale += time.Since(start) }(time.Now())
+	if img.MinDelay > 0 && delayMS < img.MinDelay {
+		delayMS = img.MinDelay
+	}
+	if img.MaxDelay > 0 && delayMS > img.MaxDelay {
+		delayMS = img.MaxDelay
+	}
+	f = img.Mirror.mirror(decimate(img.trimmed(f), img.Decimate))
+	var transparent [][]bool
+	w := img.w - img.pad
+	if img.Preview {
+		f = sampled{f, w, img.h} //already at the target size
+	}
+	if img.keepsAlpha() {
+		//Pixels that are partially transparent are drawn composited onto the background, before scaling
+		//so that the colors of transparent pixels don't bleed into the edges. draw.Over converts colors
+		//with straight alpha (e.g. of PNGs decoded as *image.NRGBA) to premultiplied ones.
+		transparent = transparency(f, w, img.h, img.alphaThreshold())
+		canvas := image.NewRGBA(image.Rect(0, 0, f.Bounds().Dx(), f.Bounds().Dy()))
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(img.bg), image.ZP, draw.Src)
+		draw.Draw(canvas, canvas.Bounds(), f, f.Bounds().Min, draw.Over)
+		f = canvas
+	}
+	scaled := f
+	if !img.Preview {
+		scaled = img.scale(f, w, img.h)
+	}
+	if img.Mode == Braille && len(img.frames) == 0 {
+		img.reduced = img.brailleColors()
+	} else if img.GrayLevels > 0 && len(img.frames) == 0 {
+		img.reduced = grayColors(img.GrayLevels, img.firstColor())
+		img.logf("reduced the image to %v gray levels", len(img.reduced))
+	} else if img.MaxColors > 0 && len(img.frames) == 0 {
+		img.reduced = reducedColors(scaled, transparent, img.MaxColors, img.firstColor(), img.filter())
+		img.logf("reduced the image to %v terminal colors", len(img.reduced))
+	}
+	if len(img.frames) == 0 {
+		img.reduced = img.colorMode().restrict(img.reduced)
+	}
+	picture := quantize(scaled, img.firstColor(), img.filter(), img.Dither, img.reduced)
+	if img.pad > 0 {
+		bg := uint8(img.firstColor() + img.colorMode().palette().Index(img.bg))
+		for x := 0; x < img.pad; x++ {
+			col := make([]uint8, img.h)
+			for y := range col {
+				col[y] = bg
+			}
+			picture = append(picture, col)
+			if transparent != nil {
+				transparent = append(transparent, make([]bool, img.h))
+			}
+		}
+	}
+	fr := newFrame(
+		picture,
+		int(math.Ceil(float64(delayMS)*img.DelayMultiplier)), //canvases account for the rendering time (see pacer)
+	)
+	fr.transparent = transparent
+	if img.TrueColor {
+		fr.rgb = trueColors(scaled, img.filter(), img.pad, img.bg)
+	}
+	img.progress()
+	if n := len(img.frames); n > 0 && img.frames[n-1].equals(fr) {
+		img.frames[n-1].delay += fr.delay //merge identical consecutive frames to avoid redundant redraws
+		return
+	}
+	img.frames = append(img.frames, fr)
+}
+
+// startSpinner starts drawing the spinner if Spinner is set
+// and returns the function stopping it.
+func (img *Image) startSpinner() (stop func()) {
+	img.done, img.total, img.drawn = 0, 0, false
+	var w io.Writer
+	if img.Spinner {
+		w = spinnerOutput()
+	}
+	if w == nil {
+		return func() {}
+	}
+	img.spin = startSpinner(w)
+	return func() {
+		img.spin.close()
+		img.spin = nil
+	}
+}
+
+// drawFirstFrame draws the first frame onto FirstFrameCanvas,
+// if not nil, and flushes it.
+func (img *Image) drawFirstFrame() error {
+	if img.FirstFrameCanvas == nil {
+		return nil
+	}
+	if err := img.Redraw.begin(img.FirstFrameCanvas); err != nil {
+		return err
+	}
+	if err := img.drawFrame(img.FirstFrameCanvas, img.frames[0]); err != nil {
+		return err
+	}
+	img.drawn = true
+	return img.FirstFrameCanvas.Sleep(0)
+}
+
+// progress records that a frame was prepared and reports it.
+func (img *Image) progress() {
+	img.done++
+	if img.spin != nil {
+		img.spin.update(img.done, img.total)
+	}
+	if img.OnProgress != nil {
+		img.OnProgress(img.done, img.total)
+	}
+}
+
+// imageFile is an open image file, which is rewound after
+// peeking at its header.
+type imageFile interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// open opens Filename, from FS if set, or Data.
+func (img *Image) open() (imageFile, error) {
+	if img.Data != nil {
+		return nopCloser{bytes.NewReader(img.Data)}, nil
+	}
+	if img.Reader != nil {
+		if img.read == nil {
+			b, err := img.readAll(img.Reader)
+			if err != nil {
+				return nil, err
+			}
+			img.read = b
+		}
+		return nopCloser{bytes.NewReader(img.read)}, nil
+	}
+	if img.FS == nil {
+		return os.Open(img.Filename)
+	}
+	f, err := img.FS.Open(img.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := f.(imageFile); ok {
+		return file, nil
+	}
+	defer f.Close()
+	b, err := img.readAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(b)}, nil
+}
+
+// readAll reads an image from a stream that can't seek into
+// memory, failing if it's larger than MaxBytes.
+func (img *Image) readAll(r io.Reader) ([]byte, error) {
+	limit := img.MaxBytes
+	if limit <= 0 {
+		limit = DefaultMaxBytes
+	}
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("image is larger than the maximum of %v bytes", limit)
+	}
+	img.logf("read %v bytes into memory", len(b))
+	return b, nil
+}
+
+// maxPixels returns MaxPixels, or DefaultMaxPixels if it's zero.
+func (img *Image) maxPixels() int {
+	if img.MaxPixels <= 0 {
+		return DefaultMaxPixels
+	}
+	return img.MaxPixels
+}
+
+// maxFrames returns MaxFrames, or DefaultMaxFrames if it's zero.
+func (img *Image) maxFrames() int {
+	if img.MaxFrames <= 0 {
+		return DefaultMaxFrames
+	}
+	return img.MaxFrames
+}
+
+// nopCloser adds a Close method that does nothing to a
+// ReadSeeker.
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// checkConfig reads the header of the image to check
+// that its dimensions don't exceed the limits before it
+// gets decoded, and rewinds the file.
+func (img *Image) checkConfig(file io.ReadSeeker) error {
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return err
+	}
+	limit := img.maxPixels()
+	if cfg.Width*cfg.Height > limit || cfg.Width > limit || cfg.Height > limit {
+		return fmt.Errorf("image is %vx%v, more than the maximum of %v pixels", cfg.Width, cfg.Height, limit)
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	return err
+}
+
+// dimensions computes the size of the rendered image for
+// an iw x ih picture. Animations are restricted to a 40
+// character width unless the size is specified.
+func (img *Image) dimensions(iw, ih int, animated bool) (w, h int, err error) {
+	size := img.Size
+	if size.Width == 0 && size.Height == 0 {
+		size.Width = img.UserWidth
+	}
+	if size.Width < 0 || size.Height < 0 {
+		return 0, 0, errors.New("image size must not be negative")
+	}
+
+	if size.Width > 0 && size.Height > 0 && !size.Fit {
+		w, h = size.Width, size.Height
+	} else {
+		scale := 1.0
+		if size.Width > 0 || size.Height > 0 {
+			scale = math.Inf(1)
+			if size.Width > 0 {
+				scale = float64(size.Width) / float64(iw)
+			}
+			if size.Height > 0 {
+				scale = math.Min(scale, float64(size.Height)/float64(ih))
+			}
+		} else {
+			tw, th, err := img.terminalSize()
+			if err != nil {
+				return 0, 0, err
+			}
+			img.logf("terminal size is %vx%v", tw, th)
+			if img.UsedLines > 0 {
+				th -= img.UsedLines
+				if th < 1 {
+					th = 1
+				}
+				img.logf("%v lines are used, leaving %v", img.UsedLines, th)
+			}
+			if animated && !img.NoShrink {
+				tw = 40
+			}
+			if img.FillPercent > 0 {
+				tw = int(float64(tw) * img.FillPercent / 100)
+				th = int(float64(th) * img.FillPercent / 100)
+			}
+			th *= 2
+			if !img.FullHeight {
+				th-- //account for the terminal prompt ($/#) that'll show up after the image is displayed
+			}
+			if img.Lines > 0 { //scale to the number of lines, within the terminal width
+				scale = math.Min(float64(tw)/float64(iw), float64(img.Lines*2)/float64(ih))
+			} else if !img.NoShrink && (tw < iw || th < ih) { //scale down the image to fit the terminal
+				scaleW := float64(tw) / float64(iw)
+				scaleH := float64(th) / float64(ih)
+				scale = math.Min(scaleW, scaleH)
+			}
+		}
+		w = int(math.Floor(scale * float64(iw)))
+		h = int(math.Floor(scale * float64(ih)))
+	}
+
+	if h%2 != 0 { //make the height even since we will be painting y and y+1 in every iteration
+		h -= 1
+	}
+	return w, h, nil
+}
+
+// gifSource returns a reader for a GIF file along with its
+// layout. Some encoders write frames extending past the logical
+// screen, which the gif package rejects, so the screen is then
+// enlarged to fit them; the frames are clipped to the original
+// screen when composited.
+func (img *Image) gifSource(file io.ReadSeeker) (io.Reader, gifLayout, error) {
+	layout, err := scanGIF(bufio.NewReader(file))
+	if err != nil {
+		return nil, gifLayout{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, gifLayout{}, err
+	}
+	if layout.bounds.In(layout.screen) {
+		return file, layout, nil
+	}
+
+	max := layout.bounds.Union(layout.screen).Max
+	if max.X > math.MaxUint16 || max.Y > math.MaxUint16 {
+		return nil, gifLayout{}, errInvalidGIF
+	}
+	if max.X*max.Y > img.maxPixels() {
+		return nil, gifLayout{}, fmt.Errorf("image frames span %vx%v, more than the maximum of %v pixels", max.X, max.Y, img.maxPixels())
+	}
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, gifLayout{}, err
+	}
+	binary.LittleEndian.PutUint16(b[6:], uint16(max.X))
+	binary.LittleEndian.PutUint16(b[8:], uint16(max.Y))
+	return bytes.NewReader(b), layout, nil
+}
+
+// colorMode returns the resolved ColorMode.
+func (img *Image) colorMode() ColorMode {
+	if img.ExcludeSystemColors {
+		return NoSystemColors
+	}
+	return img.ColorMode
+}
+
+// firstColor returns the first terminal color that
+// pixels can be mapped to.
+func (img *Image) firstColor() int {
+	return img.colorMode().first()
+}
+
+// validate checks the render settings, which Init and
+// InitFrames rely on, rejecting the combinations that would
+// otherwise be silently ignored.
+func (img *Image) validate() error {
+	switch {
+	case img.Mode < HalfBlocks || img.Mode > Braille:
+		return errors.New("unknown render mode")
+	case img.Glyph < GlyphHalfBlock || img.Glyph > GlyphFullBlock:
+		return errors.New("unknown glyph")
+	case img.ColorMode < AllColors || img.ColorMode > BasicColors:
+		return errors.New("unknown color mode")
+	case img.Reset < ResetCells || img.Reset > ResetFrames:
+		return errors.New("unknown reset mode")
+	case img.Redraw < RedrawLineUp || img.Redraw > RedrawClear:
+		return errors.New("unknown redraw mode")
+	case img.Mirror < MirrorNone || img.Mirror > MirrorQuad:
+		return errors.New("unknown symmetry")
+	case img.Glyph != GlyphHalfBlock && img.Mode != HalfBlocks:
+		return errors.New("glyphs only apply to the halfblocks render mode")
+	case img.ColorShades && img.Mode != Shades:
+		return errors.New("colored shades only apply to the shades render mode")
+	case img.ASCII && img.Mode != Shades:
+		return errors.New("ASCII characters only apply to the shades render mode")
+	case (img.DotThreshold != 0 || img.DotColor != nil) && img.Mode != Braille:
+		return errors.New("dots only apply to the braille render mode")
+	case img.DotThreshold < 0 || img.DotThreshold >= 1:
+		return errors.New("dot threshold must be between 0 and 1")
+	case img.TrueColor && img.Mode != HalfBlocks:
+		return errors.New("24-bit colors only apply to the halfblocks render mode")
+	case img.TrueColor && (img.colorMode() == BasicColors || img.MaxColors > 0 || img.GrayLevels > 0 || img.Dither != DitherNone):
+		return errors.New("24-bit colors can't be reduced or dithered")
+	case img.Decimate < 0:
+		return errors.New("decimation must not be negative")
+	case img.LoopCount < 0 && img.LoopCount != LoopForever && img.LoopCount != LoopAuto:
+		return errors.New("loop count must not be negative, except for LoopForever and LoopAuto")
+	case img.UsedLines < 0:
+		return errors.New("used lines must not be negative")
+	case img.OverlayAlpha < 0 || img.OverlayAlpha > 1:
+		return errors.New("overlay alpha must be between 0 and 1")
+	case img.FillPercent < 0 || img.FillPercent > 100:
+		return errors.New("fill percentage must be between 0 and 100")
+	}
+	return nil
+}
+
+// Scale resizes src to w x h pixels and maps each pixel to
+// the closest terminal color (see Colors) allowed by mode,
+// without any terminal assumption. This is the core of Init,
+// which computes the dimensions from the terminal size and
+// applies the filters (e.g. Colormap) beforehand.
+// The result is indexed by column, then row, and can be drawn
+// with two vertically adjacent pixels per character (see
+// Canvas.Paint).
+func Scale(src image.Image, w, h int, mode ColorMode) [][]uint8 {
+	return quantize(resize.Resize(uint(w), uint(h), src, resize.Lanczos3), mode.first(), nil, DitherNone, mode.allowed())
+}
+
+// scalePicture resizes f to w x h pixels with the
+// interpolation function and quantizes it (see quantize).
+func scalePicture(f image.Image, w, h, first int, filter func(c color.Color) color.Color, mode DitherMode,
+	interp resize.InterpolationFunction) [][]uint8 {
+	return quantize(resize.Resize(uint(w), uint(h), f, interp), first, filter, mode, nil)
+}
+
+// quantize applies the filter to each pixel of the scaled
+// image (if not nil) and maps it to the closest terminal
+// color, starting from the first color or among the allowed
+// ones if not nil, dithering the colors according to the mode.
+// The picture is indexed by x, then y.
+func quantize(scaled image.Image, first int, filter func(c color.Color) color.Color, mode DitherMode,
+	allowed []uint8) [][]uint8 {
+	palette := Palette(Colors[first:])
+	index := func(i int) uint8 { return uint8(first + i) }
+	if allowed != nil {
+		palette = make(Palette, len(allowed))
+		for i, c := range allowed {
+			palette[i] = Colors[c]
+		}
+		index = func(i int) uint8 { return allowed[i] }
+	}
+	b := scaled.Bounds()
+	w, h := b.Dx(), b.Dy()
+	d := newDitherer(mode, w, h, palette)
+	pic := make([][]uint8, w)
+	for x := 0; x < w; x++ {
+		pic[x] = make([]uint8, h)
+		for y := 0; y < h; y++ {
+			clr := scaled.At(b.Min.X+x, b.Min.Y+y)
+			if filter != nil {
+				clr = filter(clr)
+			}
+			if d != nil {
+				clr = d.adjust(x, y, clr)
+			}
+			i := palette.Index(clr)
+			if d != nil {
+				d.diffuse(x, y, clr, palette[i])
+			}
+			pic[x][y] = index(i)
+		}
+	}
+	return pic
+}
+
+// loopCount returns the number of times a GIF should be played.
+// The NETSCAPE2.0 extension records the number of times the
+// animation is restarted, where 0 means forever; GIFs without
+// the extension are played once (see gif.GIF.LoopCount).
+func loopCount(g *gif.GIF) int {
+	switch {
+	case g.LoopCount == 0:
+		return LoopForever
+	case g.LoopCount < 0:
+		return 1
+	default:
+		return g.LoopCount + 1
+	}
+}
+
+// Width returns the width of the rendered image in
+// pixels once initialized. With HalfBlocks, each pixel
+// is a terminal column.
+func (img *Image) Width() int {
+	return img.w
+}
+
+// Height returns the height of the rendered image in
+// pixels once initialized. With HalfBlocks, the image
+// occupies Height()/2 terminal lines.
+func (img *Image) Height() int {
+	return img.h
+}
+
+// Columns returns the number of terminal columns the
+// image occupies once initialized.
+func (img *Image) Columns() int {
+	cw, _ := img.Mode.cellSize()
+	return img.w / cw
+}
+
+// Rows returns the number of terminal lines the image
+// occupies once initialized.
+func (img *Image) Rows() int {
+	return img.lines()
+}
+
+// Format returns the name of the format the image was
+// decoded as by Init (e.g. "jpeg", as registered with
+// image.RegisterFormat, or "webp" for animated WebPs), or ""
+// if it was initialized with InitFrames.
+func (img *Image) Format() string {
+	return img.format
+}
+
+// terminalSize returns the dimensions of the terminal the
+// image is rendered to.
+func (img *Image) terminalSize() (width, height int, err error) {
+	if img.Output != nil {
+		return terminal.SizeOf(img.Output)
+	}
+	return terminal.Size()
+}
+
+// logf prints a diagnostic message to the logger, if any.
+func (img *Image) logf(format string, v ...interface{}) {
+	if img.Logger != nil {
+		img.Logger.Printf(format, v...)
+	}
+}
+
 // Draw renders the image into one of the
-// selected modes (stdout or file)
+// selected modes (stdout or file).
+// Animations play LoopCount times and freeze on the
+// last frame, leaving the cursor on the line below
+// the image.
 func (img *Image) Draw(canvas Canvas) error {
+	cc := &countingCanvas{Canvas: canvas}
+	defer func(start time.Time) {
+		img.stats.Draw, img.stats.BytesRendered = time.Since(start), cc.n
+	}(time.Now())
+	img.stats.FramesDrawn, img.stats.TargetFPS, img.stats.AchievedFPS = 0, 0, 0
+	if img.drawn { //over the first frame drawn by Init
+		img.drawn = false
+		if err := img.Redraw.leave(cc, img.lines()); err != nil {
+			return err
+		}
+		if err := img.Redraw.redraw(cc); err != nil {
+			return err
+		}
+	}
+	if err := img.play(cc); err != nil {
+		return err
+	}
+	if img.Bell {
+		if err := printText(cc, "\a"); err != nil {
+			return err
+		}
+	}
+	if err := canvas.Close(); err != nil {
+		return err
+	}
+	if img.OnComplete != nil {
+		img.OnComplete()
+	}
+	return nil
+}
+
+// play renders the frames and the caption onto the canvas as
+// Draw does, without closing it.
+func (img *Image) play(canvas Canvas) error {
+	if err := img.playFrames(canvas); err != nil {
+		return err
+	}
+	return img.drawCaption(canvas)
+}
+
+// playFrames renders the frames onto the canvas.
+func (img *Image) playFrames(canvas Canvas) error {
+	if img.Reveal != RevealNone && len(img.frames) == 1 {
+		img.stats.FramesDrawn++
+		return img.reveal(canvas, img.frames[0])
+	}
 	firstFrameDone := false
 	delay := 0
-	for i := 0; i < img.LoopCount; i++ {
-		for _, frame := range img.frames {
+	frames := img.sequence()
+
+	//Keep track of time to check whether the terminal keeps pace with the animation
+	var start, latest time.Time //when the first and latest frames started to be displayed
+	var due time.Duration       //when the next frame is due, since the start
+	paced, skip := false, false
+	drawn := 0
+	defer func() {
+		img.stats.FramesDrawn += drawn
+		if drawn > 1 && due > 0 {
+			img.stats.TargetFPS = float64(drawn-1) / due.Seconds()
+			img.stats.AchievedFPS = float64(drawn-1) / latest.Sub(start).Seconds()
+		}
+	}()
+
+	loops := img.loops()
+	for i := 0; loops == LoopForever || i < loops; i++ {
+		for f, frame := range frames {
 			if firstFrameDone {
-				if err := canvas.LineUp(img.h / 2); err != nil {
+				delay = img.capDelay(delay)
+				due += time.Duration(delay) * time.Millisecond
+				last := i == loops-1 && f == len(frames)-1
+				if skip && !last && time.Since(start)-due > time.Duration(img.capDelay(frame.delay))*time.Millisecond {
+					delay = frame.delay //the frame would be displayed too late
+					continue
+				}
+				if err := img.Redraw.leave(canvas, img.lines()); err != nil {
 					return err
 				}
+				if !paced { //the first frame has been rendered
+					paced = true
+					skip = img.checkPace(time.Since(start))
+				}
 				if err := canvas.Sleep(delay); err != nil {
 					return err
 				}
-			}
-			for y := 0; y < img.h; y = y + 2 {
-				for x := 0; x < img.w; x++ {
-					canvas.Paint(frame.picture[x][y], frame.picture[x][y+1])
+				if err := img.Redraw.redraw(canvas); err != nil {
+					return err
+				}
+				latest = time.Now()
+			} else {
+				start = time.Now()
+				if len(frames) > 1 || loops != 1 { //redrawn
+					if err := img.Redraw.begin(canvas); err != nil {
+						return err
+					}
 				}
-				err := canvas.NewLine()
-				if err != nil {
+			}
+			if img.FrameCounter && len(frames) > 1 {
+				label := fmt.Sprintf("%d/%d %.2fs", f+1, len(frames), time.Since(start).Seconds())
+				if err := img.drawLabeled(canvas, frame, label); err != nil {
 					return err
 				}
+			} else if err := img.drawFrame(canvas, frame); err != nil {
+				return err
 			}
+			drawn++
 			firstFrameDone = true
 			delay = frame.delay
 		}
 	}
-	return canvas.Close()
+	return nil
+}
+
+// loops returns the number of times the frames are played:
+// LoopCount, except for static images (of a single frame, e.g.
+// SVG documents), which are drawn once.
+func (img *Image) loops() int {
+	if len(img.frames) == 1 {
+		return 1
+	}
+	return img.LoopCount
+}
+
+// capDelay returns the delay (in milliseconds) for which a
+// frame is displayed, no shorter than allowed by MaxFPS.
+func (img *Image) capDelay(delay int) int {
+	if img.MaxFPS <= 0 {
+		return delay
+	}
+	if min := int(math.Ceil(1000 / img.MaxFPS)); delay < min {
+		return min
+	}
+	return delay
+}
+
+// checkPace compares the time it took to render the first
+// frame with the average delay between frames and acts
+// according to SlowTerminal. It returns whether frames
+// should be skipped.
+func (img *Image) checkPace(render time.Duration) bool {
+	total := 0
+	for _, f := range img.frames {
+		total += f.delay
+	}
+	average := time.Duration(total/len(img.frames)) * time.Millisecond
+	if render <= average {
+		return false
+	}
+	switch img.SlowTerminal {
+	case SlowTerminalWarn:
+		img.logf("warning: rendering a frame takes %v, longer than the average delay of %v between frames, "+
+			"so the animation will be slower than intended; try a smaller size", render, average)
+	case SlowTerminalSkip:
+		img.logf("rendering a frame takes %v, longer than the average delay of %v between frames; skipping frames",
+			render, average)
+		return true
+	}
+	return false
+}
+
+// DrawFunc renders the frames of the image and passes each of
+// them to f as text (including escape sequences), along with the
+// duration for which the frame should be displayed.
+// Frames are passed LoopCount times in the order they would be
+// drawn, or until f returns an error if the image loops forever.
+// Unlike Draw, the caller is responsible for the cursor movement
+// and timing between frames.
+func (img *Image) DrawFunc(f func(frameText string, delay time.Duration) error) error {
+	loops := img.loops()
+	for i := 0; loops == LoopForever || i < loops; i++ {
+		for _, frame := range img.sequence() {
+			tc := &textCanvas{}
+			if err := img.drawFrame(tc, frame); err != nil {
+				return err
+			}
+			if err := f(tc.b.String(), time.Duration(img.capDelay(frame.delay))*time.Millisecond); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteTo streams the rendered image to w as text (including
+// escape sequences), so that it can be sent to any sink (e.g.
+// an HTTP response or a network connection). Animations are
+// written frame by frame, LoopCount times, with the cursor
+// movement and delays between frames as with Draw, so they
+// play in real time on a terminal reading the stream; set
+// LoopCount to 0 before Init to write the first frame only.
+// It implements io.WriterTo.
+func (img *Image) WriteTo(w io.Writer) (n int64, err error) {
+	wc := NewWriterCanvas(w, 0)
+	err = img.Draw(wc)
+	return wc.n, err
+}
+
+// DrawRegion renders the part of the first frame that lies
+// within r, leaving the cursor on the line below it. r is
+// in pixels and is clipped to the image; its bounds are
+// rounded down to whole characters (e.g. even vertical
+// bounds with HalfBlocks).
+// Unlike Draw, the canvas is not closed.
+func (img *Image) DrawRegion(canvas Canvas, r image.Rectangle) error {
+	if len(img.frames) == 0 {
+		return nil
+	}
+	cw, ch := img.Mode.cellSize()
+	r.Min.X -= r.Min.X % cw
+	r.Max.X -= r.Max.X % cw
+	r.Min.Y -= r.Min.Y % ch
+	r.Max.Y -= r.Max.Y % ch
+	return img.drawRegion(canvas, img.frames[0], r.Intersect(image.Rect(0, 0, img.w, img.h)))
+}
+
+// sequence returns the frames in the order they are
+// played in a loop. Each frame keeps its own delay, i.e.
+// the time for which it is displayed.
+func (img *Image) sequence() []frame {
+	if !img.Reverse {
+		return img.frames
+	}
+	n := len(img.frames)
+	frames := make([]frame, n)
+	for i, f := range img.frames {
+		frames[n-1-i] = f
+	}
+	return frames
+}
+
+// drawFrame paints a single frame onto the canvas,
+// leaving the cursor on the line below it.
+func (img *Image) drawFrame(canvas Canvas, frame frame) error {
+	if img.BottomUp {
+		return img.drawBottomUp(canvas, frame)
+	}
+	return img.drawRegion(canvas, frame, image.Rect(0, 0, img.w, img.h))
+}
+
+// drawBottomUp paints a frame line by line from the bottom up
+// (see BottomUp), leaving the cursor on the line below it.
+func (img *Image) drawBottomUp(canvas Canvas, frame frame) error {
+	_, ch := img.Mode.cellSize()
+	lines := img.lines()
+	for l := 1; l < lines; l++ { //move to the bottom line, scrolling the terminal to make room if needed
+		if err := canvas.NewLine(); err != nil {
+			return err
+		}
+	}
+	for l := lines - 1; l >= 0; l-- {
+		if err := img.drawRegion(canvas, frame, image.Rect(0, l*ch, img.w, (l+1)*ch)); err != nil {
+			return err
+		}
+		if l > 0 {
+			if err := canvas.LineUp(2); err != nil { //from below the line to the one above it
+				return err
+			}
+		}
+	}
+	for l := 1; l < lines; l++ {
+		if err := canvas.NewLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drawLabeled paints a frame with a label printed over the
+// start of its top row, cut to the width of the image.
+func (img *Image) drawLabeled(canvas Canvas, frame frame, label string) error {
+	cw, ch := img.Mode.cellSize()
+	if cols := img.w / cw; len(label) > cols {
+		label = label[:cols]
+	}
+	if err := printText(canvas, label); err != nil {
+		return err
+	}
+	if err := img.drawRegion(canvas, frame, image.Rect(len(label)*cw, 0, img.w, ch)); err != nil {
+		return err
+	}
+	return img.drawRegion(canvas, frame, image.Rect(0, ch, img.w, img.h))
+}
+
+// lines returns the number of terminal lines the
+// image occupies.
+func (img *Image) lines() int {
+	_, ch := img.Mode.cellSize()
+	return img.h / ch
+}
+
+// drawRegion paints the part of a frame within r, which
+// must lie within the image and be aligned to characters.
+func (img *Image) drawRegion(canvas Canvas, frame frame, r image.Rectangle) error {
+	cw, ch := img.Mode.cellSize()
+	resetMode := img.Reset
+	if img.colorDepth() != 256 && resetMode == ResetCells {
+		resetMode = ResetLines //only the pen draws the basic and 24-bit colors
+	}
+	var p *pen
+	if resetMode != ResetCells {
+		p = newPen(img.colorDepth())
+	}
+	fill, filled := img.fillColor(frame, r)
+	if filled {
+		if err := img.paintFill(canvas, r, fill); err != nil {
+			return err
+		}
+	}
+	skip := 0 //characters of the fill color to skip over before painting
+	move := func() error {
+		if skip == 0 {
+			return nil
+		}
+		n := skip
+		skip = 0
+		return printText(canvas, fmt.Sprintf("\x1b[%vC", n))
+	}
+	for y := r.Min.Y; y < r.Max.Y; y = y + ch {
+		skip = 0
+		for x := r.Min.X; x < r.Max.X; x = x + cw {
+			if img.CompactRuns {
+				if c, n := img.uniformRun(frame, x, y, r.Max.X); n > 0 {
+					x += (n - 1) * cw
+					if filled && c == fill {
+						skip += n
+						continue
+					}
+					if err := move(); err != nil {
+						return err
+					}
+					var text string
+					if p != nil {
+						text = p.paint(strings.Repeat(" ", n), anyColor, c)
+					} else {
+						text = background(strings.Repeat(" ", n), uint8(c))
+					}
+					if err := printText(canvas, text); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if err := move(); err != nil {
+				return err
+			}
+			if err := img.paintCell(canvas, frame, x, y, p); err != nil {
+				return err
+			}
+		}
+		if p != nil && (resetMode == ResetLines || y+ch >= r.Max.Y) {
+			if reset := p.reset(); reset != "" {
+				if err := printText(canvas, reset); err != nil {
+					return err
+				}
+			}
+		}
+		err := canvas.NewLine()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,1777 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
+
+	"github.com/codeliveroil/img/terminal"
+	"github.com/nfnt/resize"
+)
+
+const testData = "../resources/testdata/"
+
+func newTestImage(filename string, loopCount int, t testing.TB) Image {
+	img := Image{
+		Filename:        testData + filename,
+		LoopCount:       loopCount,
+		DelayMultiplier: 1,
+		UserWidth:       80,
+	}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	return img
+}
+
+var testPalette = color.Palette{color.Black, color.White, color.RGBA{R: 255, A: 255}, color.Transparent}
+
+// filledFrame returns an 8x8 GIF frame filled with the
+// specified color of the test palette.
+func filledFrame(index uint8) *image.Paletted {
+	frame := image.NewPaletted(image.Rect(0, 0, 8, 8), testPalette)
+	for p := range frame.Pix {
+		frame.Pix[p] = index
+	}
+	return frame
+}
+
+// writeGIF encodes a GIF whose frames are filled with
+// the specified palette indices and returns its path.
+func writeGIF(t *testing.T, indices []uint8, delays []int, disposals []byte) string {
+	var frames []*image.Paletted
+	for _, i := range indices {
+		frames = append(frames, filledFrame(i))
+	}
+	return encodeGIF(t, &gif.GIF{Image: frames, Delay: delays, Disposal: disposals})
+}
+
+// encodeGIF encodes a GIF and returns its path.
+func encodeGIF(t *testing.T, g *gif.GIF) string {
+	filename := filepath.Join(t.TempDir(), "test.gif")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	return filename
+}
+
+func initGIF(filename string, t *testing.T) Image {
+	img := Image{
+		Filename:        filename,
+		LoopCount:       1,
+		DelayMultiplier: 1,
+		UserWidth:       8,
+	}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	return img
+}
+
+// recordingCanvas records the calls made to it, except
+// for Paint which is only counted.
+type recordingCanvas struct {
+	calls  []string
+	paints int
+}
+
+func (rc *recordingCanvas) record(format string, a ...interface{}) error {
+	rc.calls = append(rc.calls, fmt.Sprintf(format, a...))
+	return nil
+}
+
+func (rc *recordingCanvas) Paint(topColor, bottomColor uint8) error {
+	rc.paints++
+	return nil
+}
+func (rc *recordingCanvas) Print(text string) error { return rc.record("print %q", text) }
+func (rc *recordingCanvas) NewLine() error          { return rc.record("newline") }
+func (rc *recordingCanvas) LineUp(count int) error  { return rc.record("lineup %v", count) }
+func (rc *recordingCanvas) Sleep(delayMS int) error { return rc.record("sleep %v", delayMS) }
+func (rc *recordingCanvas) Close() error            { return rc.record("close") }
+
+// paintRecordingCanvas also records the paints, to check what
+// is drawn before and after them.
+type paintRecordingCanvas struct {
+	recordingCanvas
+}
+
+func (rc *paintRecordingCanvas) Paint(topColor, bottomColor uint8) error {
+	rc.paints++
+	return rc.record("paint")
+}
+
+func (rc *recordingCanvas) count(call string) int {
+	n := 0
+	for _, c := range rc.calls {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDrawFreezesOnLastFrame(t *testing.T) {
+	img := newTestImage("disposalNone.gif", 2, t)
+	rc := &paintRecordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+
+	redraws := len(img.frames)*img.LoopCount - 1
+	if got := rc.count(fmt.Sprintf("lineup %v", img.h/2)); got != redraws {
+		t.Errorf("expected %v line ups, got %v", redraws, got)
+	}
+	n := len(rc.calls)
+	if n < 2 {
+		t.Fatalf("expected the last frame to be drawn before closing, got %v", rc.calls)
+	}
+	if rc.calls[n-2] != "newline" || rc.calls[n-1] != "close" {
+		t.Errorf("expected the cursor below the last frame before closing, got %v", rc.calls[n-2:])
+	}
+
+	//Everything after the delay before the last frame draws it, line by line.
+	last := n - 1
+	for last >= 0 && !strings.HasPrefix(rc.calls[last], "sleep") {
+		last--
+	}
+	lines, paints := 0, 0
+	for _, call := range rc.calls[last+1 : n-1] {
+		switch {
+		case call == "newline":
+			lines++
+		case call == "paint":
+			paints++
+		case !strings.HasPrefix(call, "print"):
+			t.Errorf("expected the last frame to remain displayed, got %q after it", call)
+		}
+	}
+	if lines != img.h/2 || paints == 0 {
+		t.Errorf("expected the last frame to be drawn on %v lines, got %v lines and %v paints", img.h/2, lines, paints)
+	}
+}
+
+func TestMaxFPS(t *testing.T) {
+	img := initGIF(writeGIF(t, []uint8{0, 1, 0}, []int{1, 10, 1}, nil), t)
+	img.MaxFPS = 20
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	//The first frame is displayed for 50ms instead of 10ms, the second for its own 100ms.
+	if rc.count("sleep 50") != 1 || rc.count("sleep 100") != 1 {
+		t.Errorf("expected the delays to be capped at 50ms, got %v", rc.calls)
+	}
+}
+
+func TestFrameTiming(t *testing.T) {
+	if testing.Short() {
+		t.Skip("plays a 1s animation")
+	}
+	img := initGIF(writeGIF(t, []uint8{0, 1}, []int{100, 100}, nil), t)
+	//Writing a frame takes 300ms, as on a slow terminal, which must not delay the next frame.
+	var writes []time.Time
+	w := writerFunc(func(p []byte) (int, error) {
+		writes = append(writes, time.Now())
+		time.Sleep(300 * time.Millisecond)
+		return len(p), nil
+	})
+	if _, err := img.WriteTo(w); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if len(writes) != 2 {
+		t.Fatalf("expected a write per frame, got %v", len(writes))
+	}
+	if d := writes[1].Sub(writes[0]); d < 950*time.Millisecond || d > 1100*time.Millisecond {
+		t.Errorf("expected the first frame to be displayed for 1s, got %v", d)
+	}
+}
+
+func TestDuplicateFramesMerged(t *testing.T) {
+	img := initGIF(writeGIF(t, []uint8{0, 0, 1, 1, 1, 0}, []int{1, 2, 3, 4, 5, 6}, nil), t)
+
+	expected := []int{30, 120, 60}
+	if len(img.frames) != len(expected) {
+		t.Fatalf("expected %v frames, got %v", len(expected), len(img.frames))
+	}
+	for i, f := range img.frames {
+		if f.delay != expected[i] {
+			t.Errorf("expected delay %v for frame %v, got %v", expected[i], i, f.delay)
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	filename := writeGIF(t, []uint8{0, 1, 2}, []int{1, 2, 3}, nil)
+	play := func(reverse bool) (texts []string, delays []time.Duration) {
+		img := initGIF(filename, t)
+		img.Reverse = reverse
+		img.DrawFunc(func(frameText string, delay time.Duration) error {
+			texts = append(texts, frameText)
+			delays = append(delays, delay)
+			return nil
+		})
+		return texts, delays
+	}
+
+	texts, delays := play(false)
+	rtexts, rdelays := play(true)
+	if len(rtexts) != 3 {
+		t.Fatalf("expected 3 frames, got %v", len(rtexts))
+	}
+	for i := range texts {
+		if rtexts[i] != texts[2-i] || rdelays[i] != delays[2-i] {
+			t.Errorf("expected frame %v to be played at position %v", 2-i, i)
+		}
+	}
+}
+
+func TestTransparentFrameShowsPrevious(t *testing.T) {
+	//The second frame is transparent except for its left half, which is white.
+	second := filledFrame(3)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 4; x++ {
+			second.SetColorIndex(x, y, 1)
+		}
+	}
+	filename := encodeGIF(t, &gif.GIF{
+		Image:    []*image.Paletted{filledFrame(2), second},
+		Delay:    []int{1, 1},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+	})
+	img := initGIF(filename, t)
+
+	if len(img.frames) != 2 {
+		t.Fatalf("expected 2 frames, got %v", len(img.frames))
+	}
+	pic := img.frames[1].picture
+	white, red := uint8(Colors.Index(color.White)), uint8(Colors.Index(testPalette[2]))
+	if pic[0][0] != white || pic[7][0] != red {
+		t.Errorf("expected the first frame to show through transparent pixels, got %v and %v", pic[0][0], pic[7][0])
+	}
+}
+
+func TestGIFFrameOutOfBounds(t *testing.T) {
+	//The second frame extends 4 pixels past the right and bottom edges of the 8x8 screen.
+	second := image.NewPaletted(image.Rect(4, 4, 12, 12), testPalette)
+	for p := range second.Pix {
+		second.Pix[p] = 1
+	}
+	filename := encodeGIF(t, &gif.GIF{
+		Image:    []*image.Paletted{filledFrame(2), second},
+		Delay:    []int{1, 1},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+		Config:   image.Config{ColorModel: testPalette, Width: 12, Height: 12},
+	})
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	binary.LittleEndian.PutUint16(b[6:], 8) //logical screen width
+	binary.LittleEndian.PutUint16(b[8:], 8) //logical screen height
+	if err := os.WriteFile(filename, b, 0644); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	img := initGIF(filename, t)
+
+	if img.w != 8 || img.h != 8 || len(img.frames) != 2 {
+		t.Fatalf("expected 2 frames of 8x8 pixels, got %v of %vx%v", len(img.frames), img.w, img.h)
+	}
+	pic := img.frames[1].picture
+	white, red := uint8(Colors.Index(color.White)), uint8(Colors.Index(testPalette[2]))
+	if pic[3][3] != red || pic[4][4] != white || pic[7][7] != white {
+		t.Errorf("expected the second frame to be clipped to the screen, got %v, %v and %v", pic[3][3], pic[4][4], pic[7][7])
+	}
+}
+
+func TestFS(t *testing.T) {
+	b, err := os.ReadFile(testData + "disposalBackground.gif")
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	fromFile := newTestImage("disposalBackground.gif", 1, t)
+	fromFS := Image{
+		Filename:        "images/anim.gif",
+		FS:              fstest.MapFS{"images/anim.gif": {Data: b}},
+		LoopCount:       1,
+		DelayMultiplier: 1,
+		UserWidth:       80,
+	}
+	if err := fromFS.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if len(fromFS.frames) != len(fromFile.frames) || fromFS.frames[1].hash != fromFile.frames[1].hash {
+		t.Errorf("expected the same frames as from the file")
+	}
+
+	fromData := Image{Filename: "clipboard", Data: b, LoopCount: 1, DelayMultiplier: 1, UserWidth: 80}
+	if err := fromData.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if len(fromData.frames) != len(fromFile.frames) || fromData.frames[1].hash != fromFile.frames[1].hash {
+		t.Errorf("expected the same frames as from the file")
+	}
+}
+
+func TestReader(t *testing.T) {
+	b, err := os.ReadFile(testData + "disposalBackground.gif")
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	fromFile := newTestImage("disposalBackground.gif", 1, t)
+	stream := struct{ io.Reader }{bytes.NewReader(b)} //can't seek
+	img := Image{Filename: "stdin", Reader: stream, LoopCount: 1, DelayMultiplier: 1, UserWidth: 80}
+	for i := 0; i < 2; i++ { //the stream is only read once
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if len(img.frames) != len(fromFile.frames) || img.frames[1].hash != fromFile.frames[1].hash {
+			t.Errorf("expected the same frames as from the file")
+		}
+	}
+
+	img = Image{Filename: "stdin", Reader: bytes.NewReader(b), MaxBytes: int64(len(b) - 1), LoopCount: 1, DelayMultiplier: 1}
+	if err := img.Init(); err == nil {
+		t.Error("expecting an error for an image larger than MaxBytes")
+	}
+}
+
+func TestReaderAnimates(t *testing.T) {
+	//A GIF generated by another process and piped in animates as the same file would.
+	g := &gif.GIF{
+		Image:     []*image.Paletted{filledFrame(0), filledFrame(1), filledFrame(2)},
+		Delay:     []int{5, 10, 20},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalPrevious},
+		LoopCount: 2,
+	}
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(gif.EncodeAll(pw, g)) }()
+
+	played := func(img Image) (loopCount int, frames []string) {
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		img.LoopCount, loopCount = 1, img.LoopCount
+		err := img.DrawFunc(func(text string, delay time.Duration) error {
+			frames = append(frames, fmt.Sprintf("%q %v", text, delay))
+			return nil
+		})
+		if err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		return loopCount, frames
+	}
+	fileLoops, fromFile := played(Image{Filename: encodeGIF(t, g), LoopCount: LoopAuto, DelayMultiplier: 1, UserWidth: 8})
+	stdinLoops, fromStdin := played(Image{Filename: "stdin", Reader: pr, LoopCount: LoopAuto, DelayMultiplier: 1, UserWidth: 8})
+	if fileLoops != 3 || stdinLoops != fileLoops {
+		t.Errorf("expected the loop count of the file (%v), got %v", fileLoops, stdinLoops)
+	}
+	if len(fromFile) != 3 || !reflect.DeepEqual(fromStdin, fromFile) {
+		t.Errorf("expected the frames of the file\n%v\ngot\n%v", fromFile, fromStdin)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for filename, expected := range map[string]string{
+		"cmyk.jpeg":              "jpeg",
+		"color_matrix.png":       "png",
+		"disposalBackground.gif": "gif",
+	} {
+		img := newTestImage(filename, 1, t)
+		if got := img.Format(); got != expected {
+			t.Errorf("%v: expected format %q, got %q", filename, expected, got)
+		}
+		if err := img.InitFrames([]image.Image{filledFrame(0)}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if got := img.Format(); got != "" {
+			t.Errorf("%v: expected no format after InitFrames, got %q", filename, got)
+		}
+	}
+}
+
+func TestDisposalPrevious(t *testing.T) {
+	//The second frame paints the left half white and is disposed of, so the transparent third frame shows the first one.
+	second := filledFrame(3)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 4; x++ {
+			second.SetColorIndex(x, y, 1)
+		}
+	}
+	filename := encodeGIF(t, &gif.GIF{
+		Image:    []*image.Paletted{filledFrame(2), second, filledFrame(3)},
+		Delay:    []int{1, 1, 2},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+	})
+	white, red := uint8(Colors.Index(color.White)), uint8(Colors.Index(testPalette[2]))
+
+	img := initGIF(filename, t)
+	if len(img.frames) != 3 || img.frames[1].picture[0][0] != white || img.frames[2].picture[0][0] != red {
+		t.Errorf("expected the second frame to be disposed of to the first one")
+	}
+
+	//Keeping the second frame instead merges the third one into it.
+	img = Image{Filename: filename, LoopCount: 1, DelayMultiplier: 1, UserWidth: 8, ForceDisposal: gif.DisposalNone}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if len(img.frames) != 2 || img.frames[1].delay != 30 {
+		t.Errorf("expected the disposal methods to be overridden, got %v frames", len(img.frames))
+	}
+}
+
+func TestFrameCounter(t *testing.T) {
+	img := Image{Filename: writeGIF(t, []uint8{0, 1}, []int{1, 1}, nil), LoopCount: 1, DelayMultiplier: 1, UserWidth: 16,
+		FrameCounter: true}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	var labels []string
+	for _, c := range rc.calls {
+		if strings.HasPrefix(c, `print "`) && strings.HasSuffix(c, `s"`) {
+			labels = append(labels, c)
+		}
+	}
+	if len(labels) != 2 || !strings.HasPrefix(labels[0], `print "1/2 0.0`) || !strings.HasPrefix(labels[1], `print "2/2 `) {
+		t.Errorf("expected a label for each frame, got %v", labels)
+	}
+	//The labels cover the first 9 characters of the top row.
+	if expected := 2 * (16*img.lines() - 9); rc.paints != expected {
+		t.Errorf("expected %v characters to be painted, got %v", expected, rc.paints)
+	}
+}
+
+func TestDelayRange(t *testing.T) {
+	filename := writeGIF(t, []uint8{0, 1, 2}, []int{0, 5, 300}, nil)
+	for _, tc := range []struct {
+		min, max int
+		expected []int
+	}{
+		{0, 0, []int{0, 50, 3000}},
+		{BrowserMinDelay, 0, []int{100, 100, 3000}},
+		{20, 1000, []int{20, 50, 1000}},
+	} {
+		img := Image{Filename: filename, LoopCount: 1, DelayMultiplier: 2, UserWidth: 8, MinDelay: tc.min, MaxDelay: tc.max}
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if len(img.frames) != len(tc.expected) {
+			t.Fatalf("expected %v frames, got %v", len(tc.expected), len(img.frames))
+		}
+		for i, f := range img.frames {
+			if expected := 2 * tc.expected[i]; f.delay != expected {
+				t.Errorf("delays within [%v, %v]: expected %v for frame %v, got %v", tc.min, tc.max, expected, i, f.delay)
+			}
+		}
+	}
+}
+
+func TestInitFrames(t *testing.T) {
+	frames := []image.Image{filledFrame(0), filledFrame(1), filledFrame(2)}
+	delays := []time.Duration{1500 * time.Microsecond, 20 * time.Millisecond, time.Second}
+	img := Image{LoopCount: 1, DelayMultiplier: 2, UserWidth: 8}
+	if err := img.InitFrames(frames, delays); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	expected := []int{4, 40, 2000}
+	if len(img.frames) != len(expected) {
+		t.Fatalf("expected %v frames, got %v", len(expected), len(img.frames))
+	}
+	for i, f := range img.frames {
+		if f.delay != expected[i] {
+			t.Errorf("expected delay %v for frame %v, got %v", expected[i], i, f.delay)
+		}
+	}
+
+	if err := img.InitFrames(frames, delays[:1]); err == nil {
+		t.Error("expecting an error for missing delays")
+	}
+}
+
+func TestLoopAuto(t *testing.T) {
+	for _, tc := range []struct {
+		gifLoopCount int
+		expected     int
+	}{
+		{-1, 1}, //no NETSCAPE2.0 extension
+		{0, LoopForever},
+		{2, 3},
+	} {
+		filename := encodeGIF(t, &gif.GIF{
+			Image:     []*image.Paletted{filledFrame(0), filledFrame(1)},
+			Delay:     []int{1, 1},
+			LoopCount: tc.gifLoopCount,
+		})
+		img := Image{Filename: filename, LoopCount: LoopAuto, DelayMultiplier: 1, UserWidth: 8}
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if img.LoopCount != tc.expected {
+			t.Errorf("GIF loop count %v: expected %v, got %v", tc.gifLoopCount, tc.expected, img.LoopCount)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	filename := writeGIF(t, []uint8{0, 1, 2}, []int{1, 1, 1}, nil)
+	for _, tc := range []struct {
+		maxPixels, maxFrames int
+		ok                   bool
+	}{
+		{64, 3, true},
+		{63, 0, false},
+		{0, 2, false},
+	} {
+		img := Image{Filename: filename, LoopCount: 1, UserWidth: 8, MaxPixels: tc.maxPixels, MaxFrames: tc.maxFrames}
+		if err := img.Init(); (err == nil) != tc.ok {
+			t.Errorf("%v pixels, %v frames: expected success to be %v, got %v", tc.maxPixels, tc.maxFrames, tc.ok, err)
+		}
+	}
+}
+
+func TestNetpbmLimits(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "gray.pgm")
+	if err := os.WriteFile(filename, append([]byte("P5 8 8 255\n"), make([]byte, 64)...), 0644); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	for _, tc := range []struct {
+		maxPixels int
+		ok        bool
+	}{
+		{0, true},
+		{64, true},
+		{63, false},
+	} {
+		img := Image{Filename: filename, LoopCount: 1, UserWidth: 8, MaxPixels: tc.maxPixels}
+		if err := img.Init(); (err == nil) != tc.ok {
+			t.Errorf("%v pixels: expected success to be %v, got %v", tc.maxPixels, tc.ok, err)
+		}
+	}
+}
+
+// slowCanvas takes time to display each frame.
+type slowCanvas struct {
+	recordingCanvas
+}
+
+func (sc *slowCanvas) LineUp(count int) error {
+	time.Sleep(30 * time.Millisecond)
+	return sc.recordingCanvas.LineUp(count)
+}
+
+func TestSlowTerminalSkip(t *testing.T) {
+	img := initGIF(writeGIF(t, []uint8{0, 1, 0, 1, 0, 2}, []int{1, 1, 1, 1, 1, 1}, nil), t)
+	img.SlowTerminal = SlowTerminalSkip
+	sc := &slowCanvas{}
+	if err := img.Draw(sc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+
+	pixels := img.w * img.h / 2
+	if drawn := sc.paints / pixels; drawn >= len(img.frames) {
+		t.Errorf("expected frames to be skipped, got %v of %v", drawn, len(img.frames))
+	}
+	if sc.paints%pixels != 0 || sc.calls[len(sc.calls)-2] != "newline" {
+		t.Error("expected the last frame to be drawn completely")
+	}
+}
+
+func TestCMYKJPEG(t *testing.T) {
+	//cmyk.png is the reference decoding of the Adobe (inverted) CMYK cmyk.jpeg.
+	jpg := newTestImage("cmyk.jpeg", 1, t)
+	ref := newTestImage("cmyk.png", 1, t)
+	far := 0
+	for x := range ref.frames[0].picture {
+		for y, c := range ref.frames[0].picture[x] {
+			if distance(c, jpg.frames[0].picture[x][y]) > 48*48*3 {
+				far++
+			}
+		}
+	}
+	if total := jpg.w * jpg.h; far > total/100 {
+		t.Errorf("expected the colors to match the reference, %v of %v pixels are off", far, total)
+	}
+}
+
+func TestScaleSubImage(t *testing.T) {
+	//Left half is red, right half is blue.
+	src := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(src, image.Rect(0, 0, 10, 10), image.NewUniform(color.RGBA{R: 255, A: 255}), image.ZP, draw.Src)
+	draw.Draw(src, image.Rect(10, 0, 20, 10), image.NewUniform(color.RGBA{B: 255, A: 255}), image.ZP, draw.Src)
+	sub := src.SubImage(image.Rect(10, 0, 20, 10))
+
+	blue := uint8(Colors.Index(color.RGBA{B: 255, A: 255}))
+	pic := scalePicture(sub, 5, 4, 0, nil, DitherNone, resize.Lanczos3)
+	for x := range pic {
+		for y, c := range pic[x] {
+			if c != blue {
+				t.Fatalf("expected color %v at %v,%v, got %v", blue, x, y, c)
+			}
+		}
+	}
+}
+
+func TestDither(t *testing.T) {
+	//A color between the levels of the color cube, whose average should be approximated by dithering.
+	want := color.RGBA{115, 60, 20, 255}
+	src := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(src, src.Bounds(), image.NewUniform(want), image.ZP, draw.Src)
+	meanError := func(pic [][]uint8) float64 {
+		var sum [3]float64
+		for x := range pic {
+			for _, c := range pic[x] {
+				r, g, b, _ := Colors[c].RGBA()
+				sum[0], sum[1], sum[2] = sum[0]+float64(r>>8), sum[1]+float64(g>>8), sum[2]+float64(b>>8)
+			}
+		}
+		n := float64(len(pic) * len(pic[0]))
+		return math.Abs(sum[0]/n-float64(want.R)) + math.Abs(sum[1]/n-float64(want.G)) + math.Abs(sum[2]/n-float64(want.B))
+	}
+	none := meanError(scalePicture(src, 16, 16, 0, nil, DitherNone, resize.Lanczos3))
+	for name, mode := range DitherModes {
+		if mode == DitherNone {
+			continue
+		}
+		pic := scalePicture(src, 16, 16, 0, nil, mode, resize.Lanczos3)
+		if e := meanError(pic); e >= none {
+			t.Errorf("%v: expected the average color to be closer than %.1f, got %.1f", name, none, e)
+		}
+	}
+}
+
+func TestGrayLevels(t *testing.T) {
+	grays := grayColors(4, 16)
+	if len(grays) != 4 {
+		t.Fatalf("expected 4 gray levels, got %v", grays)
+	}
+	for _, c := range grays {
+		if r, g, b, _ := Colors[c].RGBA(); r != g || g != b {
+			t.Errorf("expected gray levels, got %v", Colors[c])
+		}
+	}
+
+	//A reddish color whose luminance (about 128) lies between two levels, approximated by dithering.
+	src := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.RGBA{200, 100, 100, 255}), image.ZP, draw.Src)
+	want := float64(color.GrayModel.Convert(src.At(0, 0)).(color.Gray).Y)
+	pic := quantize(src, 16, grayscale, DitherBayer4x4, grays)
+	used := make(map[uint8]bool)
+	var sum float64
+	for x := range pic {
+		for _, c := range pic[x] {
+			used[c] = true
+			r, _, _, _ := Colors[c].RGBA()
+			sum += float64(r >> 8)
+		}
+	}
+	if len(used) != 2 {
+		t.Errorf("expected the two closest levels to be used, got %v", used)
+	}
+	if mean := sum / 256; math.Abs(mean-want) > 8 {
+		t.Errorf("expected the average gray to be close to %v, got %.1f", want, mean)
+	}
+}
+
+func TestResampling(t *testing.T) {
+	for _, tc := range []struct {
+		r            Resampling
+		frames, w, h int
+		expected     resize.InterpolationFunction
+	}{
+		{ResampleAuto, 1, 4000, 4000, resize.Lanczos3},
+		{ResampleAuto, 44, 32, 32, resize.Lanczos3},
+		{ResampleAuto, 200, 16, 16, resize.Bilinear},
+		{ResampleAuto, 10, 1000, 1000, resize.Bilinear},
+		{ResampleNearest, 1, 100, 100, resize.NearestNeighbor},
+		{ResampleLanczos, 200, 1000, 1000, resize.Lanczos3},
+	} {
+		if got := tc.r.interpolation(tc.frames, tc.w, tc.h); got != tc.expected {
+			t.Errorf("%v with %v frames of %vx%v: expected %v, got %v", tc.r, tc.frames, tc.w, tc.h, tc.expected, got)
+		}
+	}
+
+	//Frames are scaled with the chosen filter.
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(1, 0, color.White)
+	img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 4, Height: 2}, Resampling: ResampleNearest}
+	if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if got := img.frames[0].picture; got[0][0] != 0 || got[3][0] != 15 {
+		t.Errorf("expected black and white pixels without interpolation, got %v", got)
+	}
+}
+
+func TestBoxResize(t *testing.T) {
+	//Sharp edges, which Lanczos would ring around.
+	src := image.NewRGBA(image.Rect(0, 0, 9, 1))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.Black), image.ZP, draw.Src)
+	for x := 4; x < 7; x++ {
+		src.Set(x, 0, color.White)
+	}
+	got := boxResize(src, 3, 1)
+	for x, expected := range []uint8{0, 170, 85} {
+		if c := got.RGBAAt(x, 0); c.R != expected || c.G != expected || c.B != expected {
+			t.Errorf("pixel %v: expected gray %v, got %v", x, expected, c)
+		}
+	}
+
+	img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 3, Height: 2}, Resampling: ResampleBox}
+	if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if pic := img.frames[0].picture; Colors[pic[0][0]] != Colors[0] {
+		t.Errorf("expected the black third to stay black, got %v", Colors[pic[0][0]])
+	}
+}
+
+func TestPreview(t *testing.T) {
+	//Left half is red, right half is blue, with a white line in the middle that sampling skips.
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	draw.Draw(src, image.Rect(0, 0, 500, 500), image.NewUniform(color.RGBA{R: 255, A: 255}), image.ZP, draw.Src)
+	draw.Draw(src, image.Rect(500, 0, 1000, 500), image.NewUniform(color.RGBA{B: 255, A: 255}), image.ZP, draw.Src)
+	draw.Draw(src, image.Rect(0, 249, 1000, 251), image.NewUniform(color.White), image.ZP, draw.Src)
+	img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 10, Height: 4}, Preview: true}
+	if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	red, blue := uint8(Colors.Index(color.RGBA{R: 255, A: 255})), uint8(Colors.Index(color.RGBA{B: 255, A: 255}))
+	for x, col := range img.frames[0].picture {
+		for y, c := range col {
+			if expected := map[bool]uint8{true: red, false: blue}[x < 5]; c != expected {
+				t.Errorf("expected color %v at %v,%v, got %v", expected, x, y, c)
+			}
+		}
+	}
+}
+
+func TestDecimate(t *testing.T) {
+	//Red with white stripes that decimation by 10 skips.
+	src := image.NewRGBA(image.Rect(0, 0, 1001, 500))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.ZP, draw.Src)
+	for x := 5; x < 1001; x += 10 {
+		draw.Draw(src, image.Rect(x, 0, x+5, 500), image.NewUniform(color.White), image.ZP, draw.Src)
+	}
+	if b := decimate(src, 10).Bounds(); b != image.Rect(0, 0, 101, 50) {
+		t.Errorf("expected 101x50 pixels, got %v", b)
+	}
+	if decimate(src, 1) != image.Image(src) {
+		t.Error("expected no decimation by 1")
+	}
+
+	red := uint8(Colors.Index(color.RGBA{R: 255, A: 255}))
+	for _, tc := range []struct {
+		decimate int
+		allRed   bool
+	}{
+		{0, false}, //the stripes are averaged in
+		{10, true},
+	} {
+		img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 10, Height: 4}, Resampling: ResampleBox,
+			Decimate: tc.decimate}
+		if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		allRed := true
+		for _, col := range img.frames[0].picture {
+			for _, c := range col {
+				allRed = allRed && c == red
+			}
+		}
+		if allRed != tc.allRed {
+			t.Errorf("decimate %v: expected all red to be %v, got %v", tc.decimate, tc.allRed, img.frames[0].picture)
+		}
+	}
+}
+
+func TestBayer(t *testing.T) {
+	m := bayer(4)
+	seen := make(map[float64]bool)
+	for x := range m {
+		for _, v := range m[x] {
+			if v < -0.5 || v >= 0.5 || seen[v] {
+				t.Fatalf("expected distinct thresholds in [-0.5, 0.5), got %v", m)
+			}
+			seen[v] = true
+		}
+	}
+	if len(seen) != 16 {
+		t.Errorf("expected 16 thresholds, got %v", len(seen))
+	}
+}
+
+func TestTransparent(t *testing.T) {
+	//Top half is transparent, bottom half is red.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(src, image.Rect(0, 2, 4, 4), image.NewUniform(color.RGBA{R: 255, A: 255}), image.ZP, draw.Src)
+	filename := filepath.Join(t.TempDir(), "test.png")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, src); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	img := Image{Filename: filename, LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 4, Height: 4}, Transparent: true}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	red := uint8(Colors.Index(color.RGBA{R: 255, A: 255}))
+	if n := rc.count(fmt.Sprintf("print %q", cursorForward)); n != 4 {
+		t.Errorf("expected the 4 transparent characters to be skipped, got %v", n)
+	}
+	if rc.paints != 4 {
+		t.Errorf("expected the 4 opaque characters to be painted, got %v", rc.paints)
+	}
+	if len(img.frames[0].picture) == 0 || img.frames[0].picture[0][3] != red {
+		t.Error("expected the opaque pixels to keep their color")
+	}
+}
+
+func TestCompositeTranslucent(t *testing.T) {
+	//Anti-aliased edges: the left half is 50% opaque red, the right half is opaque red.
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(src, image.Rect(0, 0, 2, 4), image.NewUniform(color.NRGBA{R: 255, A: 128}), image.ZP, draw.Src)
+	draw.Draw(src, image.Rect(2, 0, 4, 4), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.ZP, draw.Src)
+	filename := filepath.Join(t.TempDir(), "test.png")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, src); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, tc := range []struct {
+		name        string
+		background  color.Color
+		transparent bool
+		edge        color.Color
+	}{
+		{"blue", color.RGBA{B: 255, A: 255}, false, color.RGBA{128, 0, 127, 255}},
+		{"blue and transparent", color.RGBA{B: 255, A: 255}, true, color.RGBA{128, 0, 127, 255}},
+		{"white", color.White, false, color.RGBA{255, 127, 127, 255}},
+		{"translucent white", color.NRGBA{255, 255, 255, 128}, false, color.RGBA{192, 64, 64, 255}},
+	} {
+		img := Image{Filename: filename, LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 4, Height: 4},
+			Background: tc.background, Transparent: tc.transparent}
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		pic := img.frames[0].picture
+		if edge := uint8(Colors.Index(tc.edge)); pic[0][0] != edge {
+			t.Errorf("%v: expected the edge to be %v (%v), got %v (%v)", tc.name, edge, Colors[edge], pic[0][0], Colors[pic[0][0]])
+		}
+		if red := uint8(Colors.Index(color.RGBA{R: 255, A: 255})); pic[3][0] != red {
+			t.Errorf("%v: expected the opaque pixels to stay red, got %v", tc.name, Colors[pic[3][0]])
+		}
+	}
+}
+
+func TestShadesTransparent(t *testing.T) {
+	//Top line is 38% opaque, bottom line is red (with 2 pixels per line in height).
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(src, image.Rect(0, 0, 4, 2), image.NewUniform(color.NRGBA{R: 255, A: 0x60}), image.ZP, draw.Src)
+	draw.Draw(src, image.Rect(0, 2, 4, 4), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.ZP, draw.Src)
+	filename := filepath.Join(t.TempDir(), "test.png")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, src); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, tc := range []struct {
+		threshold float64
+		spaces    int
+	}{
+		{0, 4},
+		{0.3, 0},
+	} {
+		img := Image{Filename: filename, LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 4, Height: 4}, Mode: Shades,
+			AlphaThreshold: tc.threshold, Background: color.White}
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		rc := &recordingCanvas{}
+		if err := img.Draw(rc); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if n := rc.count(`print " "`); n != tc.spaces {
+			t.Errorf("threshold %v: expected %v transparent pixels drawn as spaces, got %v", tc.threshold, tc.spaces, n)
+		}
+		if n := rc.count(`print "▓"`); n != 4 {
+			t.Errorf("threshold %v: expected the 4 opaque pixels to be shaded, got %v", tc.threshold, n)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	img := initGIF(writeGIF(t, []uint8{0, 1}, []int{1, 1}, nil), t)
+	var b bytes.Buffer
+	n, err := img.WriteTo(&b)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("expected %v bytes written, got %v", b.Len(), n)
+	}
+	if ups := strings.Count(b.String(), fmt.Sprintf("\033[%dA", img.Rows())); ups != 1 {
+		t.Errorf("expected the cursor to move up once between the frames, got %v", ups)
+	}
+}
+
+func TestReveal(t *testing.T) {
+	for _, reveal := range []RevealDirection{RevealRows, RevealColumns} {
+		img := Image{Filename: testData + "color_matrix.png", LoopCount: 1, Size: Size{Width: 8, Height: 8},
+			Reveal: reveal, RevealDelay: 5 * time.Millisecond}
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		rc := &recordingCanvas{}
+		if err := img.Draw(rc); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+
+		steps := img.Rows()
+		if reveal == RevealColumns {
+			steps = img.Columns()
+		}
+		if got := rc.count("sleep 5"); got != steps-1 {
+			t.Errorf("reveal %v: expected %v sleeps, got %v", reveal, steps-1, got)
+		}
+		if rc.paints != img.Columns()*img.Rows() {
+			t.Errorf("reveal %v: expected %v cells, got %v", reveal, img.Columns()*img.Rows(), rc.paints)
+		}
+		n := len(rc.calls)
+		if rc.calls[n-2] != "newline" || rc.calls[n-1] != "close" {
+			t.Errorf("reveal %v: expected the cursor below the image before closing, got %v", reveal, rc.calls[n-2:])
+		}
+	}
+
+	img := Image{Filename: testData + "color_matrix.png", LoopCount: 1, Size: Size{Width: 8, Height: 8},
+		Reveal: RevealColumns, TrueColor: true}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if out := strings.Join(rc.calls, "\n"); rc.paints > 0 || !strings.Contains(out, ";2;") || strings.Contains(out, ";5;") {
+		t.Errorf("expected the columns to be revealed in 24-bit colors, got %v painted and %v", rc.paints, out)
+	}
+}
+
+func TestRevealDelays(t *testing.T) {
+	img := Image{Filename: testData + "color_matrix.png", LoopCount: 1, Size: Size{Width: 8, Height: 8},
+		Reveal: RevealRows, RevealDelay: time.Second}
+	var steps []string
+	img.RevealDelays = func(step, n int) time.Duration {
+		steps = append(steps, fmt.Sprintf("%v/%v", step, n))
+		return time.Duration(step+1) * time.Millisecond
+	}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if !reflect.DeepEqual(steps, []string{"0/4", "1/4", "2/4"}) {
+		t.Errorf("expected the delays after each line but the last, got %v", steps)
+	}
+	if rc.count("sleep 1") != 1 || rc.count("sleep 2") != 1 || rc.count("sleep 3") != 1 {
+		t.Errorf("expected the delays of RevealDelays, got %v", rc.calls)
+	}
+
+	for name, tc := range map[string]struct {
+		e     Easing
+		check func(first, last time.Duration) bool
+	}{
+		"linear": {EaseLinear, func(first, last time.Duration) bool { return first == 10*time.Millisecond && last == first }},
+		"in":     {EaseIn, func(first, last time.Duration) bool { return first > last }}, //starts slowly
+		"out":    {EaseOut, func(first, last time.Duration) bool { return first < last }},
+		"inout":  {EaseInOut, func(first, last time.Duration) bool { return first > 10*time.Millisecond && last > 10*time.Millisecond }},
+	} {
+		delays := EaseDelays(tc.e, 10*time.Millisecond)
+		var total time.Duration
+		for step := 0; step < 4; step++ {
+			total += delays(step, 5)
+		}
+		if d := total - 40*time.Millisecond; d < -time.Microsecond || d > time.Microsecond {
+			t.Errorf("%v: expected 40ms in total, got %v", name, total)
+		}
+		if first, last := delays(0, 5), delays(3, 5); !tc.check(first, last) {
+			t.Errorf("%v: unexpected first and last delays %v and %v", name, first, last)
+		}
+	}
+}
+
+func TestPadMultiple(t *testing.T) {
+	img := Image{LoopCount: 1, Size: Size{Width: 7, Height: 4}, PadMultiple: 4, Background: color.White}
+	if err := img.InitFrames([]image.Image{filledFrame(2)}, []time.Duration{0}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if img.Columns() != 8 {
+		t.Fatalf("expected 8 columns, got %v", img.Columns())
+	}
+	pic := img.frames[0].picture
+	white, red := uint8(Colors.Index(color.White)), uint8(Colors.Index(testPalette[2]))
+	if pic[6][0] != red || pic[7][0] != white || pic[7][3] != white {
+		t.Errorf("expected the image to be padded with the background on the right, got %v and %v", pic[6][0], pic[7][0])
+	}
+}
+
+func TestPage(t *testing.T) {
+	img := Image{Filename: testData + "color_matrix.png", LoopCount: 1, Size: Size{Width: 8, Height: 8}}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Page(rc, iotest.OneByteReader(strings.NewReader(" bq")), 3); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	for _, indicator := range []string{" lines 1-3 of 4 (75%) ", " lines 2-4 of 4 (100%) "} {
+		if rc.count(fmt.Sprintf("print %q", "\x1b[7m"+indicator+"\x1b[0m\x1b[K")) == 0 {
+			t.Errorf("expected the indicator %q, got %v", indicator, rc.calls)
+		}
+	}
+	if got := rc.count("lineup 3"); got != 2 {
+		t.Errorf("expected 2 redraws, got %v", got)
+	}
+
+	rc = &recordingCanvas{}
+	if err := img.Page(rc, iotest.OneByteReader(strings.NewReader(" q")), 0); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if got := rc.count("lineup 0"); got != 0 {
+		t.Errorf("expected no line up by 0 without lines, got %v", got)
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	img := initGIF(writeGIF(t, []uint8{0, 1}, []int{1, 2}, nil), t)
+	var b bytes.Buffer
+	if err := img.EncodeJSON(&b); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	var ji jsonImage
+	if err := json.Unmarshal(b.Bytes(), &ji); err != nil {
+		t.Fatal("expecting valid JSON, got", err)
+	}
+	if ji.Version != JSONVersion || ji.Width != img.w || ji.Height != img.h || len(ji.Frames) != 2 {
+		t.Fatalf("unexpected header %+v", ji)
+	}
+	for i, f := range ji.Frames {
+		if f.Delay != img.frames[i].delay || len(f.Rows) != img.h || len(f.Rows[0]) != img.w {
+			t.Errorf("frame %v: unexpected delay or dimensions", i)
+		}
+		if f.Rows[1][2] != int(img.frames[i].picture[2][1]) {
+			t.Errorf("frame %v: expected rows of columns", i)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	maroon := color.RGBA{R: 128, A: 255} //system color 1
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(src, src.Bounds(), image.NewUniform(maroon), image.ZP, draw.Src)
+	for mode, expected := range map[ColorMode]uint8{
+		AllColors:      1,
+		NoSystemColors: uint8(16 + Colors[16:].Index(maroon)),
+	} {
+		pic := Scale(src, 2, 3, mode)
+		if len(pic) != 2 || len(pic[0]) != 3 {
+			t.Fatalf("expected 2x3 pixels, got %vx%v", len(pic), len(pic[0]))
+		}
+		if pic[1][2] != expected {
+			t.Errorf("mode %v: expected color %v, got %v", mode, expected, pic[1][2])
+		}
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	for _, tc := range []struct {
+		img  Image
+		w, h int
+	}{
+		{Image{UserWidth: 50}, 50, 24},
+		{Image{Size: Size{Width: 50}}, 50, 24},
+		{Image{Size: Size{Height: 50}}, 100, 50},
+		{Image{Size: Size{Width: 30, Height: 30}}, 30, 30},
+		{Image{Size: Size{Width: 30, Height: 30, Fit: true}}, 30, 14},
+		{Image{Size: Size{Width: 300, Height: 30, Fit: true}}, 60, 30},
+	} {
+		w, h, err := tc.img.dimensions(200, 100, false)
+		if err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if w != tc.w || h != tc.h {
+			t.Errorf("%+v: expected %vx%v, got %vx%v", tc.img.Size, tc.w, tc.h, w, h)
+		}
+	}
+}
+
+// fakeTerminalSize makes terminal.Size report w x h until the
+// test ends.
+func fakeTerminalSize(t *testing.T, w, h int) {
+	size := terminal.Size
+	t.Cleanup(func() { terminal.Size = size })
+	terminal.Size = func() (int, int, error) {
+		return w, h, nil
+	}
+}
+
+func TestDimensionsLines(t *testing.T) {
+	fakeTerminalSize(t, 100, 50)
+	for _, tc := range []struct {
+		lines int
+		w, h  int
+	}{
+		{10, 40, 20},
+		{40, 100, 50},
+	} {
+		img := Image{Lines: tc.lines}
+		w, h, err := img.dimensions(200, 100, false)
+		if err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if w != tc.w || h != tc.h {
+			t.Errorf("%v lines: expected %vx%v, got %vx%v", tc.lines, tc.w, tc.h, w, h)
+		}
+	}
+}
+
+func TestDimensionsUsedLines(t *testing.T) {
+	fakeTerminalSize(t, 100, 50)
+	for _, tc := range []struct {
+		used int
+		w, h int
+	}{
+		{0, 100, 50},
+		{30, 80, 40}, //20 lines left
+		{60, 4, 2},   //a line at least
+	} {
+		img := Image{UsedLines: tc.used, FullHeight: true}
+		w, h, err := img.dimensions(200, 100, false)
+		if err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if w != tc.w || h != tc.h {
+			t.Errorf("%v used lines: expected %vx%v, got %vx%v", tc.used, tc.w, tc.h, w, h)
+		}
+	}
+}
+
+func TestDimensionsOutput(t *testing.T) {
+	fakeTerminalSize(t, 100, 50)
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	defer out.Close()
+	sizeOf := terminal.SizeOf
+	t.Cleanup(func() { terminal.SizeOf = sizeOf })
+	terminal.SizeOf = func(f *os.File) (int, int, error) {
+		if f != out {
+			t.Errorf("expected the size of %v, got %v", out.Name(), f.Name())
+		}
+		return 20, 50, nil
+	}
+	img := Image{Output: out, FullHeight: true}
+	w, h, err := img.dimensions(200, 100, false)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if w != 20 || h != 10 {
+		t.Errorf("expected 20x10, got %vx%v", w, h)
+	}
+}
+
+// logo returns a typical logo: a red disc on a white
+// background.
+func logo(w, h int) image.Image {
+	m := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(m, m.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+	r := float64(h) / 3
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if dx, dy := float64(x-w/2), float64(y-h/2); dx*dx+dy*dy < r*r {
+				m.Set(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+	}
+	return m
+}
+
+func TestCompactRuns(t *testing.T) {
+	var texts [2]string
+	var white uint8
+	for i, compact := range []bool{false, true} {
+		img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 40, Height: 20}, CompactRuns: compact}
+		if err := img.InitFrames([]image.Image{logo(200, 100)}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		white = img.frames[0].picture[0][0]
+		if err := img.DrawFunc(func(text string, _ time.Duration) error {
+			texts[i] = text
+			return nil
+		}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+	}
+	plain, compact := texts[0], texts[1]
+	if len(compact) > len(plain)/4 {
+		t.Errorf("expected the output to be much smaller than %v bytes, got %v", len(plain), len(compact))
+	}
+	if !strings.HasPrefix(compact, fmt.Sprintf("\x1b[48;5;%vm\x1b[40X\n", white)) || !strings.Contains(compact, "\x1b[9A\n") {
+		t.Errorf("expected the white background to be filled at once and the top line skipped, got %q", compact)
+	}
+	if !strings.Contains(compact, "▄") || !regexp.MustCompile(`\x1b\[[0-9]+C`).MatchString(compact) {
+		t.Error("expected the white around the edges of the disc to be skipped over")
+	}
+	//A space on a colored background looks like a half block of that color.
+	looks := func(c styledCell) styledCell {
+		if c.r == '▄' && c.fg == c.bg {
+			return styledCell{' ', -1, c.bg}
+		}
+		return c
+	}
+	expected, _ := emulate(t, plain)
+	got, _ := emulate(t, compact)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v lines, got %v", len(expected), len(got))
+	}
+	for l := range got {
+		if len(got[l]) != len(expected[l]) {
+			t.Fatalf("line %v: expected %v characters, got %v", l, len(expected[l]), len(got[l]))
+		}
+		for c := range got[l] {
+			if looks(got[l][c]) != looks(expected[l][c]) {
+				t.Errorf("line %v, column %v: expected %v, got %v", l, c, expected[l][c], got[l][c])
+			}
+		}
+	}
+}
+
+func BenchmarkDrawLogo(b *testing.B) {
+	for _, compact := range []bool{false, true} {
+		b.Run(fmt.Sprintf("compact=%v", compact), func(b *testing.B) {
+			img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 160, Height: 80}, CompactRuns: compact}
+			if err := img.InitFrames([]image.Image{logo(640, 320)}, []time.Duration{0}); err != nil {
+				b.Fatal("expecting no error, got", err)
+			}
+			b.ResetTimer()
+			var n int64
+			for i := 0; i < b.N; i++ {
+				var err error
+				if n, err = img.WriteTo(io.Discard); err != nil {
+					b.Fatal("expecting no error, got", err)
+				}
+			}
+			b.ReportMetric(float64(n), "bytes/draw")
+		})
+	}
+}
+
+func BenchmarkPreview(b *testing.B) {
+	src := logo(4000, 3000)
+	for _, preview := range []bool{false, true} {
+		b.Run(fmt.Sprintf("preview=%v", preview), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 160}, Preview: preview}
+				if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+					b.Fatal("expecting no error, got", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkInitStatic(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		newTestImage("color_matrix.png", 1, b)
+	}
+}
+
+func BenchmarkInitGIF(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		newTestImage("disposalUnspecified.gif", 1, b)
+	}
+}
+
+func BenchmarkDraw(b *testing.B) {
+	img := newTestImage("disposalUnspecified.gif", 1, b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := img.Draw(NopCanvas{}); err != nil {
+			b.Fatal("expecting no error, got", err)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	//Black on the left, white on the right.
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(1, 0, color.White)
+	src.Set(1, 1, color.White)
+	black, white := uint8(0), uint8(Colors.Index(color.White))
+	for _, tc := range []struct {
+		s        Symmetry
+		expected [][]uint8 //by row
+	}{
+		{MirrorHorizontal, [][]uint8{{black, white, white, black}, {black, white, white, black}}},
+		{MirrorVertical, [][]uint8{{black, white}, {black, white}, {black, white}, {black, white}}},
+		{MirrorQuad, [][]uint8{{black, white, white, black}, {black, white, white, black}, {black, white, white, black}, {black, white, white, black}}},
+	} {
+		w, h := tc.s.size(2, 2)
+		img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: w, Height: h}, Resampling: ResampleNearest, Mirror: tc.s}
+		if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		pic := img.frames[0].picture
+		if len(pic) != w || len(pic[0]) != h {
+			t.Fatalf("%v: expected %vx%v pixels, got %vx%v", tc.s, w, h, len(pic), len(pic[0]))
+		}
+		for y, row := range tc.expected {
+			for x, c := range row {
+				if Colors[pic[x][y]] != Colors[c] {
+					t.Errorf("%v: pixel %v,%v: expected %v, got %v", tc.s, x, y, Colors[c], Colors[pic[x][y]])
+				}
+			}
+		}
+	}
+}
+
+func TestTerminalFallback(t *testing.T) {
+	interactive, depth := terminal.Interactive, terminal.ColorDepth
+	defer func() { terminal.Interactive, terminal.ColorDepth = interactive, depth }()
+	terminal.Interactive = func() bool { return true }
+
+	draw := func(img *Image) string {
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		rc := &recordingCanvas{}
+		if err := img.Draw(rc); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if rc.paints > 0 && img.colorDepth() != 256 { //only 256 colors are painted
+			t.Errorf("expected the characters to be printed, got %v painted", rc.paints)
+		}
+		return strings.Join(rc.calls, "\n")
+	}
+
+	terminal.ColorDepth = func() int { return 16 }
+	img := Image{Filename: testData + "color_matrix.png", LoopCount: 1, DelayMultiplier: 1, UserWidth: 16}
+	out := draw(&img)
+	if img.ColorMode != BasicColors {
+		t.Errorf("expected to fall back to the basic colors, got color mode %v", img.ColorMode)
+	}
+	for _, col := range img.frames[0].picture {
+		for _, c := range col {
+			if c >= 16 {
+				t.Fatalf("expected the system colors only, got color %v", c)
+			}
+		}
+	}
+	if strings.Contains(out, ";5;") || !strings.Contains(out, `\x1b[3`) {
+		t.Errorf("expected the basic color escape sequences only, got %v", out)
+	}
+
+	img = Image{Filename: testData + "color_matrix.png", LoopCount: 1, DelayMultiplier: 1, UserWidth: 16, TrueColor: true}
+	if out := draw(&img); img.TrueColor || strings.Contains(out, ";2;") {
+		t.Errorf("expected 24-bit colors to fall back to the basic colors, got %v", out)
+	}
+
+	terminal.ColorDepth = func() int { return 0 }
+	for _, trueColor := range []bool{false, true} {
+		img = Image{Filename: testData + "color_matrix.png", LoopCount: 1, DelayMultiplier: 1, UserWidth: 16, TrueColor: trueColor}
+		if out := draw(&img); img.Mode != Shades || !img.ASCII || img.TrueColor || strings.Contains(out, `\x1b`) {
+			t.Errorf("expected uncolored ASCII characters, got mode %v and %v", img.Mode, out)
+		}
+	}
+
+	img = Image{Filename: testData + "color_matrix.png", LoopCount: 1, DelayMultiplier: 1, UserWidth: 16, ForceColors: true}
+	if draw(&img); img.Mode != HalfBlocks {
+		t.Errorf("expected no fallback with ForceColors, got mode %v", img.Mode)
+	}
+
+	terminal.ColorDepth = func() int { return terminal.TrueColor }
+	img = Image{Filename: testData + "color_matrix.png", LoopCount: 1, DelayMultiplier: 1, UserWidth: 16}
+	if out := draw(&img); !img.TrueColor || !strings.Contains(out, ";2;") || strings.Contains(out, ";5;") {
+		t.Errorf("expected 24-bit colors, got %v", out)
+	}
+	img = Image{Filename: testData + "color_matrix.png", LoopCount: 1, DelayMultiplier: 1, UserWidth: 16, MaxColors: 4}
+	if draw(&img); img.TrueColor {
+		t.Error("expected reduced colors to stay 256 colors")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	img := Image{Filename: writeGIF(t, []uint8{0, 1}, []int{1, 1}, nil), LoopCount: 2, DelayMultiplier: 1, UserWidth: 8,
+		Bell: true}
+	completed := 0
+	img.OnComplete = func() { completed++ }
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if n := len(rc.calls); n < 2 || rc.calls[n-2] != `print "\a"` || rc.calls[n-1] != "close" {
+		t.Errorf("expected the bell to ring before closing the canvas, got %v", rc.calls)
+	}
+	if completed != 1 {
+		t.Errorf("expected OnComplete to be called once, got %v", completed)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var out bytes.Buffer
+	var output io.Writer = &out
+	defer func(f func() io.Writer, d time.Duration) { spinnerOutput, spinnerInterval = f, d }(spinnerOutput, spinnerInterval)
+	spinnerOutput = func() io.Writer { return output }
+	spinnerInterval = time.Millisecond
+
+	img := Image{Filename: writeGIF(t, []uint8{0, 1, 0}, []int{1, 1, 1}, nil), LoopCount: 1, DelayMultiplier: 1,
+		UserWidth: 8, Spinner: true}
+	var progress []string
+	img.OnProgress = func(done, total int) {
+		progress = append(progress, fmt.Sprintf("%v/%v", done, total))
+		time.Sleep(5 * time.Millisecond) //let the spinner turn
+	}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if !reflect.DeepEqual(progress, []string{"1/3", "2/3", "3/3"}) {
+		t.Errorf("expected the progress of each frame, got %v", progress)
+	}
+	s := out.String()
+	if !strings.Contains(s, "preparing frames") || !strings.HasSuffix(s, "\r\033[K") {
+		t.Errorf("expected the spinner to be drawn then cleared, got %q", s)
+	}
+
+	//Not drawn if stderr isn't a terminal.
+	out.Reset()
+	output = nil
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("expected no spinner, got %q", out.String())
+	}
+}
+
+func TestFirstFrame(t *testing.T) {
+	rc := &recordingCanvas{}
+	img := Image{Filename: writeGIF(t, []uint8{0, 1, 2}, []int{1, 1, 1}, nil), LoopCount: 1, DelayMultiplier: 1,
+		UserWidth: 8, FirstFrameCanvas: rc}
+	paintsBeforeSecond := -1
+	img.OnProgress = func(done, total int) {
+		if done == 2 {
+			paintsBeforeSecond = rc.paints
+		}
+	}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if paintsBeforeSecond <= 0 || paintsBeforeSecond != rc.paints {
+		t.Errorf("expected the first frame to be drawn before preparing the second, got %v of %v paints",
+			paintsBeforeSecond, rc.paints)
+	}
+	preview := len(rc.calls)
+	if preview == 0 || rc.calls[preview-1] != "sleep 0" {
+		t.Errorf("expected the first frame to be flushed, got %v", rc.calls)
+	}
+
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if lineUp := fmt.Sprintf("lineup %v", img.lines()); len(rc.calls) <= preview || rc.calls[preview] != lineUp ||
+		rc.count(lineUp) != len(img.frames) {
+		t.Errorf("expected the animation to be drawn over the first frame, got %v", rc.calls[preview:])
+	}
+}
+
+func TestRedraw(t *testing.T) {
+	for _, tc := range []struct {
+		mode          RedrawMode
+		begin, redraw string
+	}{
+		{RedrawLineUp, "", "lineup 4"},
+		{RedrawSaveRestore, `print "\x1b7"`, `print "\x1b8"`},
+		{RedrawClear, `print "\x1b[2J\x1b[H"`, `print "\x1b[2J\x1b[H"`},
+	} {
+		img := Image{Filename: writeGIF(t, []uint8{0, 1, 2}, []int{1, 1, 1}, nil), LoopCount: 2, DelayMultiplier: 1,
+			UserWidth: 8, Redraw: tc.mode}
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		rc := &paintRecordingCanvas{}
+		if err := img.Draw(rc); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if tc.begin != "" && rc.calls[0] != tc.begin {
+			t.Errorf("%v: expected %v before the first frame, got %v", tc.mode, tc.begin, rc.calls[0])
+		}
+		redraws := 0
+		for i := 1; i < len(rc.calls); i++ {
+			if rc.calls[i] != tc.redraw {
+				continue
+			}
+			redraws++
+			if tc.mode == RedrawLineUp {
+				if i+1 >= len(rc.calls) || rc.calls[i+1] != "sleep 10" {
+					t.Errorf("%v: expected the cursor to move up before the delay, got %v", tc.mode, rc.calls)
+				}
+			} else if rc.calls[i-1] != "sleep 10" || i+1 >= len(rc.calls) || rc.calls[i+1] != "paint" {
+				t.Errorf("%v: expected %v after the delay and before painting the next frame, got %v",
+					tc.mode, tc.redraw, rc.calls)
+			}
+		}
+		if redraws != 5 {
+			t.Errorf("%v: expected %v before each of the 5 frames redrawn, got %v in %v", tc.mode, tc.redraw, redraws, rc.calls)
+		}
+		if tc.mode != RedrawLineUp && rc.count("lineup 4") > 0 {
+			t.Errorf("%v: expected no line ups, got %v", tc.mode, rc.calls)
+		}
+	}
+
+	//Still images aren't redrawn.
+	img := Image{Filename: writeGIF(t, []uint8{0}, []int{1}, nil), LoopCount: 1, DelayMultiplier: 1, UserWidth: 8,
+		Redraw: RedrawClear}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if rc.count(`print "\x1b[2J\x1b[H"`) > 0 {
+		t.Errorf("expected a still image not to clear the screen, got %v", rc.calls)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	white := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(white, white.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+	for _, tc := range []struct {
+		overlay  color.Color
+		alpha    float64
+		expected color.Color
+	}{
+		{nil, 0.5, color.White},
+		{color.Black, 0, color.White},
+		{color.Black, 0.5, color.RGBA{128, 128, 128, 255}},
+		{color.RGBA{0, 0, 255, 255}, 1, color.RGBA{0, 0, 255, 255}},
+	} {
+		img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 4, Height: 4}, ColorMode: NoSystemColors,
+			Overlay: tc.overlay, OverlayAlpha: tc.alpha}
+		if err := img.InitFrames([]image.Image{white}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		expected := uint8(img.firstColor() + img.colorMode().palette().Index(tc.expected))
+		if got := img.frames[0].picture[0][0]; got != expected {
+			t.Errorf("%v by %v: expected color %v, got %v", tc.overlay, tc.alpha, expected, got)
+		}
+	}
+}
+
+func TestChannelAdjust(t *testing.T) {
+	zero, half := 0.0, 0.5
+	c := color.NRGBA{200, 100, 50, 255}
+	for _, tc := range []struct {
+		adjust   ChannelAdjust
+		expected color.NRGBA
+	}{
+		{ChannelAdjust{}, c},
+		{ChannelAdjust{R: Channel{Scale: &zero}}, color.NRGBA{0, 100, 50, 255}}, //0 clears the channel
+		{ChannelAdjust{G: Channel{Scale: &half}, B: Channel{Offset: 10}}, color.NRGBA{200, 50, 60, 255}},
+		{ChannelAdjust{R: Channel{Offset: 100}, B: Channel{Offset: -100}}, color.NRGBA{255, 100, 0, 255}}, //clamped
+	} {
+		if got := tc.adjust.apply(c); got != tc.expected {
+			t.Errorf("%+v: expected %v, got %v", tc.adjust, tc.expected, got)
+		}
+	}
+}
+
+func TestAutoTrim(t *testing.T) {
+	//A red 20x10 subject within a white 10 pixel border, slightly noisy.
+	src := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+	src.Set(39, 29, color.RGBA{250, 250, 250, 255})
+	draw.Draw(src, image.Rect(10, 10, 30, 20), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.ZP, draw.Src)
+	red := uint8(Colors.Index(color.RGBA{255, 0, 0, 255}))
+
+	for _, tc := range []struct {
+		tolerance float64
+		trimmed   bool
+	}{
+		{0, false}, //the noisy corner isn't uniform
+		{10, true},
+	} {
+		img := Image{LoopCount: 1, DelayMultiplier: 1, UserWidth: 8, AutoTrim: true, TrimTolerance: tc.tolerance}
+		if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		allRed := true
+		for _, col := range img.frames[0].picture {
+			for _, c := range col {
+				allRed = allRed && c == red
+			}
+		}
+		if allRed != tc.trimmed || (tc.trimmed && (img.w != 8 || img.h != 4)) {
+			t.Errorf("tolerance %v: expected trimmed %v, got %vx%v pixels, all red: %v", tc.tolerance, tc.trimmed,
+				img.w, img.h, allRed)
+		}
+	}
+
+	//Uniform images are left as is.
+	if r := trimBounds(image.NewRGBA(image.Rect(0, 0, 4, 4)), 0); r != image.Rect(0, 0, 4, 4) {
+		t.Errorf("expected a uniform image not to be trimmed, got %v", r)
+	}
+}
+
+func TestBottomUp(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1, 3))
+	src.Set(0, 0, color.Black)
+	src.Set(0, 1, color.Gray{128})
+	src.Set(0, 2, color.White)
+	img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 1, Height: 6}, Mode: Shades, Background: color.Black,
+		BottomUp: true}
+	if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	pic := img.frames[0].picture
+	line := func(y int) string { return fmt.Sprintf("print %q", img.shade(pic[0][y])) }
+	expected := []string{"newline", "newline", line(2), "newline", "lineup 2", line(1), "newline", "lineup 2", line(0),
+		"newline", "newline", "newline", "close"}
+	if !reflect.DeepEqual(rc.calls, expected) {
+		t.Errorf("expected %v, got %v", expected, rc.calls)
+	}
+}
+
+func TestPalettePreview(t *testing.T) {
+	rc := &recordingCanvas{}
+	if err := Palette(Colors[:20]).Preview(rc); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if len(rc.calls) != 22 || rc.calls[16] != "newline" || rc.calls[21] != "newline" {
+		t.Errorf("expected 2 lines of swatches, got %q", rc.calls)
+	}
+	if !strings.Contains(rc.calls[17], " 16 ") {
+		t.Errorf("expected the swatches to be labeled with their index, got %q", rc.calls[17])
+	}
+}
+
+// paintingCanvas can only paint half blocks (see Printer).
+type paintingCanvas struct{}
+
+func (paintingCanvas) Paint(topColor, bottomColor uint8) error { return nil }
+func (paintingCanvas) NewLine() error                          { return nil }
+func (paintingCanvas) LineUp(count int) error                  { return nil }
+func (paintingCanvas) Sleep(delayMS int) error                 { return nil }
+func (paintingCanvas) Close() error                            { return nil }
+
+func TestPrinter(t *testing.T) {
+	for _, mode := range []RenderMode{HalfBlocks, Sextants} {
+		img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 4, Height: 6}, Mode: mode}
+		if err := img.InitFrames([]image.Image{logo(8, 6)}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		err := img.Draw(paintingCanvas{})
+		if mode == HalfBlocks && err != nil {
+			t.Error("expecting no error painting half blocks, got", err)
+		}
+		if mode == Sextants && (err == nil || !strings.Contains(err.Error(), "can't print")) {
+			t.Error("expecting an error printing sextants, got", err)
+		}
+	}
+}
+
+func TestStdoutCanvasWriteError(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	f.Close()
+	sc := NewStdoutCanvas(f)
+	if err := sc.Paint(1, 2); err != nil {
+		t.Fatal("expecting no error while buffering, got", err)
+	}
+	if err := sc.Close(); err == nil {
+		t.Error("expected an error writing to a closed file")
+	}
+}
+
+func TestScroll(t *testing.T) {
+	for _, lines := range []int{0, 2} {
+		img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 4, Height: 8}}
+		if err := img.InitFrames([]image.Image{logo(4, 8)}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		rc := &recordingCanvas{}
+		if err := img.Scroll(rc, iotest.OneByteReader(strings.NewReader("jjq")), 4, lines); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		ups := 0
+		for _, call := range rc.calls {
+			if call == "lineup 0" {
+				t.Errorf("%v lines: expected no line up by 0", lines)
+			}
+			if strings.HasPrefix(call, "lineup") {
+				ups++
+			}
+		}
+		if expected := map[int]int{0: 0, 2: 2}[lines]; ups != expected {
+			t.Errorf("%v lines: expected %v line ups, got %v", lines, expected, ups)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// IndicesVersion is the version of the format written by
+// EncodeIndices.
+const IndicesVersion = 1
+
+// indicesMagic starts the output of EncodeIndices.
+const indicesMagic = "IMGI"
+
+// indicesHeader is the header written by EncodeIndices after
+// the magic bytes.
+type indicesHeader struct {
+	Version       uint8
+	Width, Height uint32
+	Frames        uint32
+	LoopCount     int32
+}
+
+// EncodeIndices writes the frames of the image to w as a
+// compact binary grid of palette indices (see Colors), exactly
+// as they would be drawn, for programs that consume the
+// quantized image and do their own rendering. It is the binary
+// equivalent of EncodeJSON, with the integers in little endian:
+//
+//	"IMGI"              magic bytes
+//	version    uint8    IndicesVersion
+//	width      uint32   in pixels
+//	height     uint32   in pixels (two per line with HalfBlocks)
+//	frames     uint32   number of frames
+//	loopCount  int32    -1 to loop forever
+//
+// followed by each frame in the order they are drawn (see
+// Reverse):
+//
+//	delay      uint32   milliseconds
+//	pixels     [height][width]uint8, row by row
+func (img *Image) EncodeIndices(w io.Writer) error {
+	if len(img.frames) == 0 {
+		return errors.New("image is not initialized")
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(indicesMagic)
+	frames := img.sequence()
+	header := indicesHeader{
+		Version:   IndicesVersion,
+		Width:     uint32(img.w),
+		Height:    uint32(img.h),
+		Frames:    uint32(len(frames)),
+		LoopCount: int32(img.loops()),
+	}
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	row := make([]byte, img.w)
+	for _, f := range frames {
+		if err := binary.Write(bw, binary.LittleEndian, uint32(f.delay)); err != nil {
+			return err
+		}
+		for y := 0; y < img.h; y++ {
+			for x := range row {
+				row[x] = f.picture[x][y]
+			}
+			if _, err := bw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
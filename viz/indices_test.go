@@ -0,0 +1,54 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncodeIndices(t *testing.T) {
+	img := Image{Filename: writeGIF(t, []uint8{0, 1}, []int{10, 20}, nil), LoopCount: 3, DelayMultiplier: 1, UserWidth: 8}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	var b bytes.Buffer
+	if err := img.EncodeIndices(&b); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if magic := string(b.Next(4)); magic != indicesMagic {
+		t.Fatalf("expected the %q magic bytes, got %q", indicesMagic, magic)
+	}
+	var header indicesHeader
+	if err := binary.Read(&b, binary.LittleEndian, &header); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	expected := indicesHeader{Version: IndicesVersion, Width: uint32(img.w), Height: uint32(img.h), Frames: 2, LoopCount: 3}
+	if header != expected {
+		t.Errorf("expected header %+v, got %+v", expected, header)
+	}
+	for i, f := range img.frames {
+		var delay uint32
+		if err := binary.Read(&b, binary.LittleEndian, &delay); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if int(delay) != f.delay {
+			t.Errorf("frame %v: expected a delay of %v, got %v", i, f.delay, delay)
+		}
+		pixels := b.Next(img.w * img.h)
+		for y := 0; y < img.h; y++ {
+			for x := 0; x < img.w; x++ {
+				if got := pixels[y*img.w+x]; got != f.picture[x][y] {
+					t.Fatalf("frame %v, pixel %v,%v: expected %v, got %v", i, x, y, f.picture[x][y], got)
+				}
+			}
+		}
+	}
+	if b.Len() != 0 {
+		t.Errorf("expected no trailing bytes, got %v", b.Len())
+	}
+}
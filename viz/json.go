@@ -0,0 +1,70 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// JSONVersion is the version of the schema written by
+// EncodeJSON. It is incremented whenever the schema changes in
+// a way that isn't backward compatible.
+const JSONVersion = 1
+
+// jsonImage is the schema written by EncodeJSON.
+type jsonImage struct {
+	Version   int         `json:"version"`
+	Width     int         `json:"width"`
+	Height    int         `json:"height"`
+	LoopCount int         `json:"loopCount"`
+	Frames    []jsonFrame `json:"frames"`
+}
+
+type jsonFrame struct {
+	Delay int     `json:"delay"`
+	Rows  [][]int `json:"rows"` //not uint8, which would be encoded in base64
+}
+
+// EncodeJSON writes the frames of the image to w as JSON,
+// exactly as they would be drawn, for golden tests and
+// alternative renderers:
+//
+//	{
+//	  "version": 1,     //JSONVersion
+//	  "width": 80,      //in pixels
+//	  "height": 60,     //in pixels (two per line with HalfBlocks)
+//	  "loopCount": 1,   //-1 to loop forever
+//	  "frames": [{
+//	    "delay": 100,   //milliseconds
+//	    "rows": [[16, 231, ...], ...] //height rows of width palette indices (see Colors)
+//	  }, ...]
+//	}
+//
+// Frames are listed in the order they are drawn (see Reverse).
+func (img *Image) EncodeJSON(w io.Writer) error {
+	if len(img.frames) == 0 {
+		return errors.New("image is not initialized")
+	}
+	ji := jsonImage{
+		Version:   JSONVersion,
+		Width:     img.w,
+		Height:    img.h,
+		LoopCount: img.loops(),
+	}
+	for _, f := range img.sequence() {
+		rows := make([][]int, img.h)
+		for y := range rows {
+			rows[y] = make([]int, img.w)
+			for x := range rows[y] {
+				rows[y][x] = int(f.picture[x][y])
+			}
+		}
+		ji.Frames = append(ji.Frames, jsonFrame{Delay: f.delay, Rows: rows})
+	}
+	return json.NewEncoder(w).Encode(ji)
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// medianCut returns a palette of at most n colors representing
+// the pixels, built by repeatedly splitting the box of pixels
+// with the widest channel range at the median of that channel.
+// Each color is the average of the pixels of its box.
+func medianCut(pixels []color.RGBA, n int) color.Palette {
+	if len(pixels) == 0 || n <= 0 {
+		return nil
+	}
+	boxes := [][]color.RGBA{pixels}
+	for len(boxes) < n {
+		//Split the box with the widest range, if it has distinct colors.
+		widest, widestRange, channel := -1, 0, 0
+		for i, b := range boxes {
+			if c, r := widestChannel(b); r > widestRange {
+				widest, widestRange, channel = i, r, c
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		b := boxes[widest]
+		sort.Slice(b, func(i, j int) bool { return component(b[i], channel) < component(b[j], channel) })
+		mid := len(b) / 2
+		for mid > 0 && component(b[mid-1], channel) == component(b[mid], channel) {
+			mid-- //keep identical values in the same box
+		}
+		if mid == 0 {
+			mid = len(b) / 2
+			for component(b[mid-1], channel) == component(b[mid], channel) {
+				mid++
+			}
+		}
+		boxes[widest] = b[:mid]
+		boxes = append(boxes, b[mid:])
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, b := range boxes {
+		var r, g, bl int
+		for _, p := range b {
+			r, g, bl = r+int(p.R), g+int(p.G), bl+int(p.B)
+		}
+		n := len(b)
+		palette[i] = color.RGBA{uint8((r + n/2) / n), uint8((g + n/2) / n), uint8((bl + n/2) / n), 0xff}
+	}
+	return palette
+}
+
+// widestChannel returns the channel (0 for red, 1 for green,
+// 2 for blue) whose values span the widest range among the
+// pixels, and the range.
+func widestChannel(pixels []color.RGBA) (channel, span int) {
+	for c := 0; c < 3; c++ {
+		min, max := 255, 0
+		for _, p := range pixels {
+			v := int(component(p, c))
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if max-min > span {
+			channel, span = c, max-min
+		}
+	}
+	return channel, span
+}
+
+func component(c color.RGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+// reducedColors returns the terminal colors (indices in
+// Colors, from the first one) closest to the colors of the
+// median-cut palette of n colors of the scaled image, after
+// applying the filter (if not nil). The transparent pixels
+// (if not nil, see transparency) are left out unless all are.
+func reducedColors(scaled image.Image, transparent [][]bool, n, first int, filter func(c color.Color) color.Color) []uint8 {
+	b := scaled.Bounds()
+	pixels := make([]color.RGBA, 0, b.Dx()*b.Dy())
+	for _, opaqueOnly := range []bool{transparent != nil, false} {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				if opaqueOnly && transparent[x-b.Min.X][y-b.Min.Y] {
+					continue
+				}
+				c := scaled.At(x, y)
+				if filter != nil {
+					c = filter(c)
+				}
+				pixels = append(pixels, color.RGBAModel.Convert(c).(color.RGBA))
+			}
+		}
+		if len(pixels) > 0 {
+			break
+		}
+	}
+	palette := Colors[first:]
+	seen := make(map[uint8]bool)
+	var indices []uint8
+	for _, c := range medianCut(pixels, n) {
+		i := uint8(first + palette.Index(c))
+		if !seen[i] {
+			seen[i] = true
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
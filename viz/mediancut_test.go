@@ -0,0 +1,88 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestMedianCut(t *testing.T) {
+	//Two clusters of reds and blues.
+	var pixels []color.RGBA
+	for i := uint8(0); i < 10; i++ {
+		pixels = append(pixels, color.RGBA{200 + i, 0, 0, 255}, color.RGBA{0, 0, 200 + i, 255})
+	}
+	palette := medianCut(pixels, 2)
+	if len(palette) != 2 {
+		t.Fatalf("expected 2 colors, got %v", palette)
+	}
+	for _, expected := range []color.RGBA{{205, 0, 0, 255}, {0, 0, 205, 255}} {
+		if c := palette.Convert(expected); c != expected {
+			t.Errorf("expected %v in the palette, got %v", expected, palette)
+		}
+	}
+
+	//There are no more colors than distinct pixels.
+	if palette := medianCut([]color.RGBA{{1, 2, 3, 255}, {1, 2, 3, 255}}, 8); len(palette) != 1 {
+		t.Errorf("expected a single color, got %v", palette)
+	}
+}
+
+func TestMaxColors(t *testing.T) {
+	src := NewPattern(PatternGradient, 256, 64)
+	distinct := func(img Image) int {
+		seen := make(map[uint8]bool)
+		for _, col := range img.frames[0].picture {
+			for _, c := range col {
+				seen[c] = true
+			}
+		}
+		return len(seen)
+	}
+	full := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 64, Height: 16}}
+	reduced := full
+	reduced.MaxColors = 4
+	for _, img := range []*Image{&full, &reduced} {
+		if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+	}
+	if n := distinct(full); n <= 4 {
+		t.Fatalf("expected the gradients to use many colors, got %v", n)
+	}
+	if n := distinct(reduced); n > 4 {
+		t.Errorf("expected at most 4 colors, got %v", n)
+	}
+}
+
+func TestMaxColorsTransparent(t *testing.T) {
+	//A red and blue sprite on a transparent background that covers most of the image.
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for x := 4; x < 8; x++ {
+		for y := 4; y < 12; y++ {
+			src.Set(x, y, color.RGBA{255, 0, 0, 255})
+			src.Set(x+4, y, color.RGBA{0, 0, 255, 255})
+		}
+	}
+	img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 16, Height: 16}, ColorMode: NoSystemColors,
+		Transparent: true, MaxColors: 2}
+	if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	f := img.frames[0]
+	for _, p := range []image.Point{{5, 8}, {10, 8}} {
+		expected := uint8(img.firstColor() + img.colorMode().palette().Index(src.At(p.X, p.Y)))
+		if got := f.picture[p.X][p.Y]; got != expected || f.transparent[p.X][p.Y] {
+			t.Errorf("%v: expected opaque color %v, got %v (reduced to %v)", p, expected, got, img.reduced)
+		}
+	}
+	if !f.transparent[0][0] {
+		t.Error("expected the background to stay transparent")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Symmetry is how an image is mirrored (see Image.Mirror).
+type Symmetry int
+
+const (
+	// MirrorNone draws the image as is.
+	MirrorNone Symmetry = iota
+	// MirrorHorizontal draws the image and its mirror image
+	// side by side, the mirror on the right.
+	MirrorHorizontal
+	// MirrorVertical draws the image above its upside down
+	// mirror image.
+	MirrorVertical
+	// MirrorQuad draws the image mirrored into four quadrants,
+	// like a kaleidoscope.
+	MirrorQuad
+)
+
+// Mirrors are the symmetries by name.
+var Mirrors = map[string]Symmetry{
+	"none":       MirrorNone,
+	"horizontal": MirrorHorizontal,
+	"vertical":   MirrorVertical,
+	"quad":       MirrorQuad,
+}
+
+// size returns the dimensions of a w x h image once mirrored.
+func (s Symmetry) size(w, h int) (int, int) {
+	if s == MirrorHorizontal || s == MirrorQuad {
+		w *= 2
+	}
+	if s == MirrorVertical || s == MirrorQuad {
+		h *= 2
+	}
+	return w, h
+}
+
+// mirror returns src mirrored with the symmetry, or src itself
+// for MirrorNone.
+func (s Symmetry) mirror(src image.Image) image.Image {
+	if s == MirrorNone {
+		return src
+	}
+	b := src.Bounds()
+	w, h := s.size(b.Dx(), b.Dy())
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), mirrored{src, b.Dx(), b.Dy(), w, h}, image.ZP, draw.Src)
+	return dst
+}
+
+// mirrored is a view of a w x h src reflected across its right
+// and bottom edges up to mw x mh pixels.
+type mirrored struct {
+	src          image.Image
+	w, h, mw, mh int
+}
+
+func (m mirrored) ColorModel() color.Model { return m.src.ColorModel() }
+func (m mirrored) Bounds() image.Rectangle { return image.Rect(0, 0, m.mw, m.mh) }
+
+func (m mirrored) At(x, y int) color.Color {
+	if x >= m.w {
+		x = 2*m.w - 1 - x
+	}
+	if y >= m.h {
+		y = 2*m.h - 1 - y
+	}
+	min := m.src.Bounds().Min
+	return m.src.At(min.X+x, min.Y+y)
+}
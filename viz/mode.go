@@ -0,0 +1,424 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// RenderMode is the way pixels are drawn with characters.
+type RenderMode int
+
+const (
+	// HalfBlocks draws two pixels per character, one above the
+	// other, with the lower half block (▄).
+	HalfBlocks RenderMode = iota
+	// Sextants draws 2x3 pixels per character with the sextant
+	// block characters added in Unicode 13, for a higher resolution
+	// than half blocks at the cost of using only two colors per
+	// character. The terminal font must include the "Symbols for
+	// Legacy Computing" block; use HalfBlocks if the output shows
+	// replacement glyphs instead.
+	Sextants
+	// Shades draws a pixel per character with a shade character
+	// ( ░▒▓█) according to its luminance, for a retro look. The
+	// ramp is inverted on light backgrounds.
+	Shades
+	// Braille draws 2x4 pixels per character with the Braille
+	// patterns (⣿), a dot per pixel brighter than the
+	// threshold (see Image.DotThreshold), for the highest
+	// resolution at the cost of colors: all the dots are drawn
+	// in the same color. Line art and text come out sharp, and
+	// photographs read well when dithered.
+	Braille
+)
+
+// RenderModes are the render modes by name.
+var RenderModes = map[string]RenderMode{
+	"halfblocks": HalfBlocks,
+	"sextants":   Sextants,
+	"shades":     Shades,
+	"braille":    Braille,
+}
+
+// Glyph is the character drawn by HalfBlocks.
+type Glyph int
+
+const (
+	// GlyphHalfBlock draws the lower half block (▄) in the color
+	// of the bottom pixel over the color of the top pixel.
+	GlyphHalfBlock Glyph = iota
+	// GlyphFullBlock draws a full block (█) using the foreground
+	// color only, for terminals where background colors don't
+	// fill the cells properly or to preserve the colors when
+	// copying the output to some editors. As a character then
+	// has a single color, the two pixels are averaged, halving
+	// the vertical resolution.
+	GlyphFullBlock
+)
+
+// ResetMode is when the colors set for drawing characters are
+// reset to the terminal's defaults.
+type ResetMode int
+
+const (
+	// ResetCells sets both colors of every character and resets
+	// them after it.
+	ResetCells ResetMode = iota
+	// ResetLines only sets the colors that change from one
+	// character to the next, and resets them at the end of each
+	// line, which makes the output several times smaller.
+	ResetLines
+	// ResetFrames only sets the colors that change from one
+	// character to the next, and resets them at the end of each
+	// frame, for the smallest output. Terminals that fill the
+	// lines they scroll in with the current background color
+	// (background color erase) may fill the right of the lines
+	// below the top of the image with the color of its edge
+	// while it's drawn at the bottom of the terminal.
+	ResetFrames
+)
+
+// ResetModes are the reset modes by name.
+var ResetModes = map[string]ResetMode{
+	"cells":  ResetCells,
+	"lines":  ResetLines,
+	"frames": ResetFrames,
+}
+
+// RedrawMode is how the cursor is moved back over an
+// animation to draw its next frame.
+type RedrawMode int
+
+const (
+	// RedrawLineUp moves the cursor up the lines of the image.
+	RedrawLineUp RedrawMode = iota
+	// RedrawSaveRestore saves the cursor position before the
+	// first frame and restores it before the next ones, for
+	// terminals and multiplexers where moving the cursor up is
+	// unreliable. The image must fit below the cursor, as the
+	// saved position doesn't follow the terminal if drawing the
+	// first frame scrolls it.
+	RedrawSaveRestore
+	// RedrawClear clears the screen and draws every frame from
+	// its top left corner, e.g. for serial terminals that
+	// support little more than clearing the screen.
+	RedrawClear
+)
+
+// RedrawModes are the redraw modes by name.
+var RedrawModes = map[string]RedrawMode{
+	"lineup": RedrawLineUp,
+	"save":   RedrawSaveRestore,
+	"clear":  RedrawClear,
+}
+
+// begin prepares the canvas for drawing the first frame.
+func (m RedrawMode) begin(canvas Canvas) error {
+	switch m {
+	case RedrawSaveRestore:
+		return printText(canvas, "\0337")
+	case RedrawClear:
+		return printText(canvas, "\033[2J\033[H")
+	}
+	return nil
+}
+
+// leave is called once a frame of lines lines is drawn, before
+// the delay for which it is displayed. RedrawLineUp moves the
+// cursor up right away, which canvases such as StdoutCanvas
+// send along with the next frame; the other modes wait for
+// redraw not to blank or move the frame while it's displayed.
+func (m RedrawMode) leave(canvas Canvas, lines int) error {
+	if m == RedrawLineUp {
+		return canvas.LineUp(lines)
+	}
+	return nil
+}
+
+// redraw moves the cursor to where the next frame is drawn,
+// after the delay of the previous one (see leave).
+func (m RedrawMode) redraw(canvas Canvas) error {
+	switch m {
+	case RedrawSaveRestore:
+		return printText(canvas, "\0338")
+	case RedrawClear:
+		return printText(canvas, "\033[2J\033[H")
+	}
+	return nil
+}
+
+// cellSize returns the number of pixels drawn per character.
+func (m RenderMode) cellSize() (w, h int) {
+	switch m {
+	case Sextants:
+		return 2, 3
+	case Shades:
+		return 1, 1
+	case Braille:
+		return 2, 4
+	default:
+		return 1, 2
+	}
+}
+
+// paintCell paints the character whose top left pixel is at
+// x,y, with the pen if not nil (see ResetMode).
+func (img *Image) paintCell(canvas Canvas, f frame, x, y int, p *pen) error {
+	if img.keyed != nil || f.transparent != nil {
+		if text, ok := img.transparentCell(f, x, y, p); ok {
+			return printText(canvas, text)
+		}
+	}
+	picture := f.picture
+	if p != nil {
+		return printText(canvas, img.penCell(p, f, x, y))
+	}
+	switch img.Mode {
+	case Sextants:
+		return printText(canvas, sextant(picture, x, y))
+	case Shades:
+		return printText(canvas, img.shade(picture[x][y]))
+	case Braille:
+		return printText(canvas, img.braille(picture, x, y))
+	default:
+		if img.Glyph == GlyphFullBlock {
+			return printText(canvas, foreground("█", average(picture[x][y], picture[x][y+1])))
+		}
+		return canvas.Paint(picture[x][y], picture[x][y+1])
+	}
+}
+
+// penCell returns the text of the character whose top left
+// pixel is at x,y, drawn with the pen.
+func (img *Image) penCell(p *pen, f frame, x, y int) string {
+	picture := f.picture
+	switch img.Mode {
+	case Sextants:
+		r, fg, bg := sextantCell(picture, x, y)
+		return p.paint(string(r), int(fg), int(bg))
+	case Shades:
+		fg := defaultColor
+		if img.ColorShades {
+			fg = int(picture[x][y])
+		}
+		return p.paint(string(img.shadeRune(picture[x][y])), fg, defaultColor)
+	case Braille:
+		return p.paint(string(img.brailleRune(picture, x, y)), img.dot, defaultColor)
+	default:
+		if img.Glyph == GlyphFullBlock {
+			return p.paint("█", f.averagePixel(x, y), anyColor)
+		}
+		return p.paint("▄", f.pixel(x, y+1), f.pixel(x, y))
+	}
+}
+
+// uniformRun returns the color and length (in characters) of
+// the run of characters starting at x,y, up to maxX, whose
+// pixels all have the same color, or 0 if the character at x,y
+// isn't one color or can't be drawn as a colored space.
+func (img *Image) uniformRun(f frame, x, y, maxX int) (c, n int) {
+	if img.Mode != HalfBlocks || img.Glyph == GlyphFullBlock {
+		return 0, 0
+	}
+	transparent := img.keyed != nil || f.transparent != nil
+	c = f.pixel(x, y)
+	for ; x < maxX; x++ {
+		if f.pixel(x, y) != c || f.pixel(x, y+1) != c {
+			break
+		}
+		if transparent && (img.clear(f, x, y) || img.clear(f, x, y+1)) {
+			break
+		}
+		n++
+	}
+	return c, n
+}
+
+// fillColor returns the color of most of the characters of the
+// region r drawn with CompactRuns, if they are one color, so
+// that the region can be filled with it at once (see fill) and
+// the flat areas spanning several lines (e.g. the background of
+// a logo) skipped over with the cursor instead of painted line
+// by line. Regions of a single line and frames with transparent
+// pixels, which must not be painted over, aren't filled.
+func (img *Image) fillColor(f frame, r image.Rectangle) (c int, ok bool) {
+	cw, ch := img.Mode.cellSize()
+	if !img.CompactRuns || r.Dy() < 2*ch || img.keyed != nil || f.transparent != nil {
+		return 0, false
+	}
+	counts := make(map[int]int)
+	for y := r.Min.Y; y < r.Max.Y; y += ch {
+		for x := r.Min.X; x < r.Max.X; x += cw {
+			if c, n := img.uniformRun(f, x, y, x+cw); n > 0 {
+				counts[c]++
+			}
+		}
+	}
+	most := 0
+	for k, n := range counts {
+		if n > most || (n == most && k < c) { //ties broken for deterministic output
+			c, most = k, n
+		}
+	}
+	return c, most*2 > (r.Dx()/cw)*(r.Dy()/ch)
+}
+
+// paintFill paints every character of the region r with the color c
+// by erasing its lines (terminals erase with the current
+// background color), then moves the cursor back to the top left
+// of the region.
+func (img *Image) paintFill(canvas Canvas, r image.Rectangle, c int) error {
+	cw, ch := img.Mode.cellSize()
+	lines := r.Dy() / ch
+	if err := printText(canvas, newPen(img.colorDepth()).code(c, 40)); err != nil {
+		return err
+	}
+	for l := 0; l < lines; l++ {
+		if l > 0 {
+			if err := canvas.NewLine(); err != nil {
+				return err
+			}
+		}
+		if err := printText(canvas, fmt.Sprintf("\x1b[%vX", r.Dx()/cw)); err != nil {
+			return err
+		}
+	}
+	return printText(canvas, fmt.Sprintf("\x1b[0m\x1b[%vA", lines-1))
+}
+
+// sextant returns the sextant character (in color) that best
+// matches the 2x3 pixels whose top left pixel is at x,y.
+func sextant(picture [][]uint8, x, y int) string {
+	r, fg, bg := sextantCell(picture, x, y)
+	return colorize(string(r), fg, bg)
+}
+
+// sextantCell returns the sextant character and colors that
+// best match the 2x3 pixels whose top left pixel is at x,y.
+// Every pair of colors among the pixels is tried, assigning each
+// pixel to the closest color of the pair, and the pair with the
+// least error wins.
+func sextantCell(picture [][]uint8, x, y int) (r rune, fg, bg uint8) {
+	var px [6]uint8 //numbered left to right, then top to bottom
+	for i := range px {
+		px[i] = picture[x+i%2][y+i/2]
+	}
+
+	fg, bg, bits := px[0], px[0], 0
+	best := uint64(1<<64 - 1)
+	for i := 0; i < len(px); i++ {
+		for j := i; j < len(px); j++ {
+			if j > i && px[i] == px[j] {
+				continue
+			}
+			var err uint64
+			b := 0
+			for k, p := range px {
+				di, dj := distance(p, px[i]), distance(p, px[j])
+				if dj < di {
+					err += dj
+					b |= 1 << uint(k)
+				} else {
+					err += di
+				}
+			}
+			if err < best {
+				best, fg, bg, bits = err, px[j], px[i], b
+			}
+		}
+	}
+	return sextantRune(bits), fg, bg
+}
+
+// shadeRamp are the shade characters from darkest to
+// brightest.
+var shadeRamp = []rune(" ░▒▓█")
+
+// asciiRamp are the ASCII characters from darkest to
+// brightest (see Image.ASCII).
+var asciiRamp = []rune(" .:-=+*#%@")
+
+// shade returns the shade character for a pixel.
+func (img *Image) shade(c uint8) string {
+	text := string(img.shadeRune(c))
+	if img.ColorShades {
+		return foreground(text, c)
+	}
+	return text
+}
+
+// shadeRune returns the shade character for a pixel, without
+// color.
+func (img *Image) shadeRune(c uint8) rune {
+	y := int(luminance(Colors[c]))
+	if luminance(img.bg) > 128 {
+		y = 255 - y //the character is drawn in the foreground color, which is dark on light backgrounds
+	}
+	ramp := shadeRamp
+	if img.ASCII {
+		ramp = asciiRamp
+	}
+	return ramp[y*len(ramp)/256]
+}
+
+// average returns the palette color closest to the average
+// of two palette colors.
+func average(a, b uint8) uint8 {
+	if a == b {
+		return a
+	}
+	ra, ga, ba, _ := Colors[a].RGBA()
+	rb, gb, bb, _ := Colors[b].RGBA()
+	return uint8(Colors.Index(color.RGBA64{
+		R: uint16((ra + rb) / 2),
+		G: uint16((ga + gb) / 2),
+		B: uint16((ba + bb) / 2),
+		A: 0xffff,
+	}))
+}
+
+// luminance returns the luminance of a color (0-255).
+func luminance(c color.Color) uint8 {
+	return color.GrayModel.Convert(c).(color.Gray).Y
+}
+
+// sextantRune returns the character whose set sextants are
+// given by the bits, where bit k is the (k+1)th sextant.
+func sextantRune(bits int) rune {
+	switch bits {
+	case 0:
+		return ' '
+	case 21: //left column
+		return '▌'
+	case 42: //right column
+		return '▐'
+	case 63:
+		return '█'
+	}
+	//U+1FB00 onwards are the sextants in order, except for the above
+	r := 0x1FB00 + bits - 1
+	if bits > 21 {
+		r--
+	}
+	if bits > 42 {
+		r--
+	}
+	return rune(r)
+}
+
+// distance returns the squared distance between two
+// terminal colors.
+func distance(a, b uint8) uint64 {
+	if a == b {
+		return 0
+	}
+	ca, cb := color.RGBAModel.Convert(Colors[a]).(color.RGBA), color.RGBAModel.Convert(Colors[b]).(color.RGBA)
+	dr, dg, db := int64(ca.R)-int64(cb.R), int64(ca.G)-int64(cb.G), int64(ca.B)-int64(cb.B)
+	return uint64(dr*dr + dg*dg + db*db)
+}
@@ -0,0 +1,386 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSextantRune(t *testing.T) {
+	for bits, expected := range map[int]rune{
+		0:  ' ',
+		1:  '\U0001FB00',
+		20: '\U0001FB13',
+		21: '▌',
+		22: '\U0001FB14',
+		42: '▐',
+		43: '\U0001FB28',
+		62: '\U0001FB3B',
+		63: '█',
+	} {
+		if got := sextantRune(bits); got != expected {
+			t.Errorf("bits %v: expected %U, got %U", bits, expected, got)
+		}
+	}
+}
+
+func TestSextantColors(t *testing.T) {
+	//Left column is color 9, right column is color 12.
+	picture := [][]uint8{{9, 9, 9}, {12, 12, 12}}
+	if got, expected := sextant(picture, 0, 0), colorize("▐", 12, 9); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestChromaKey(t *testing.T) {
+	img := Image{ChromaKey: Colors[10], ChromaTolerance: 1}
+	img.keyed = img.keyColors()
+	//Color 10 is keyed, color 9 isn't.
+	for _, c := range []struct {
+		top, bottom uint8
+		text        string
+		ok          bool
+	}{
+		{10, 10, cursorForward, true},
+		{10, 9, foreground("▄", 9), true},
+		{9, 10, foreground("▀", 9), true},
+		{9, 9, "", false},
+	} {
+		text, ok := img.transparentCell(frame{picture: [][]uint8{{c.top, c.bottom}}}, 0, 0, nil)
+		if text != c.text || ok != c.ok {
+			t.Errorf("%v/%v: expected %q %v, got %q %v", c.top, c.bottom, c.text, c.ok, text, ok)
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	//2x4 pixels, given column by column; color 10 is keyed.
+	img := Image{ChromaKey: Colors[10], ChromaTolerance: 1, w: 2, h: 4}
+	img.keyed = img.keyColors()
+	img.frames = []frame{{picture: [][]uint8{{1, 2, 10, 10}, {3, 4, 10, 9}}}}
+
+	underneath := Cell{'x', DefaultColor, DefaultColor}
+	buf := [][]Cell{{underneath, underneath}, {underneath, underneath}, {underneath, underneath}}
+	img.Compose(buf, 1, 0)
+	expected := [][]Cell{
+		{underneath, underneath},
+		{{'▄', 2, 1}, {'▄', 4, 3}},
+		{underneath, {'▄', 9, DefaultColor}},
+	}
+	for r := range expected {
+		for c := range expected[r] {
+			if buf[r][c] != expected[r][c] {
+				t.Errorf("line %v, column %v: expected %v, got %v", r, c, expected[r][c], buf[r][c])
+			}
+		}
+	}
+
+	//Clipped at the edges of the buffer.
+	buf = [][]Cell{{underneath}}
+	img.Compose(buf, -1, -1)
+	if expected := (Cell{'▄', 9, DefaultColor}); buf[0][0] != expected {
+		t.Errorf("expected %v, got %v", expected, buf[0][0])
+	}
+}
+
+// styledCell is a character as displayed by a terminal, with
+// its colors (-1 for the default ones).
+type styledCell struct {
+	r      rune
+	fg, bg int
+}
+
+var (
+	sgr    = regexp.MustCompile(`^\x1b\[([0-9;]*)m`)
+	cursor = regexp.MustCompile(`^\x1b\[([0-9]*)([ACX])`)
+)
+
+// emulate interprets the text of a frame, returning its cells
+// line by line, and whether the colors were reset at the end of
+// each line. Characters skipped over with the cursor are the
+// zero styledCell unless painted before.
+func emulate(t *testing.T, text string) (cells [][]styledCell, reset []bool) {
+	fg, bg := -1, -1
+	row, col := 0, 0
+	line := func() {
+		for len(cells) <= row {
+			cells, reset = append(cells, nil), append(reset, false)
+		}
+	}
+	set := func(c styledCell) {
+		line()
+		for len(cells[row]) <= col {
+			cells[row] = append(cells[row], styledCell{})
+		}
+		cells[row][col] = c
+	}
+	for text != "" {
+		if m := sgr.FindStringSubmatch(text); m != nil {
+			params := strings.Split(m[1], ";")
+			switch {
+			case m[1] == "0":
+				fg, bg = -1, -1
+			case m[1] == "39":
+				fg = -1
+			case m[1] == "49":
+				bg = -1
+			case len(params) == 3 && (params[0] == "38" || params[0] == "48"):
+				c, _ := strconv.Atoi(params[2])
+				if params[0] == "38" {
+					fg = c
+				} else {
+					bg = c
+				}
+			default:
+				t.Fatalf("unexpected escape sequence %q", m[0])
+			}
+			text = text[len(m[0]):]
+			continue
+		}
+		if m := cursor.FindStringSubmatch(text); m != nil {
+			n, err := strconv.Atoi(m[1])
+			if err != nil {
+				n = 1
+			}
+			switch m[2] {
+			case "A":
+				row -= n
+			case "C":
+				col += n
+			case "X":
+				for i := 0; i < n; i++ {
+					set(styledCell{' ', -1, bg})
+					col++
+				}
+				col -= n
+			}
+			text = text[len(m[0]):]
+			continue
+		}
+		r := []rune(text)[0]
+		text = text[len(string(r)):]
+		if r == '\n' {
+			line()
+			reset[row] = fg == -1 && bg == -1
+			row, col = row+1, 0
+			continue
+		}
+		c := styledCell{r, fg, bg}
+		if r == ' ' {
+			c.fg = -1 //not visible
+		}
+		set(c)
+		col++
+	}
+	return cells, reset
+}
+
+func TestResetModes(t *testing.T) {
+	frames := func(img Image) []string {
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		var texts []string
+		img.DrawFunc(func(text string, _ time.Duration) error {
+			texts = append(texts, text)
+			return nil
+		})
+		return texts
+	}
+	for _, base := range []Image{
+		{},
+		{CompactRuns: true},
+		{Glyph: GlyphFullBlock},
+		{Mode: Sextants},
+		{Mode: Shades, ColorShades: true},
+	} {
+		base.Filename, base.LoopCount, base.DelayMultiplier, base.UserWidth = testData+"disposalBackground.gif", 1, 1, 16
+		perCell := frames(base)
+		for _, mode := range []ResetMode{ResetLines, ResetFrames} {
+			img := base
+			img.Reset = mode
+			for i, text := range frames(img) {
+				if len(text) >= len(perCell[i]) {
+					t.Errorf("%+v, frame %v: expected less output than %v bytes, got %v", base, i, len(perCell[i]), len(text))
+				}
+				expected, _ := emulate(t, perCell[i])
+				got, reset := emulate(t, text)
+				if len(got) != len(expected) {
+					t.Fatalf("%+v, frame %v: expected %v lines, got %v", base, i, len(expected), len(got))
+				}
+				for l := range got {
+					if !reflect.DeepEqual(got[l], expected[l]) {
+						t.Errorf("%+v, frame %v, line %v: expected %v, got %v", base, i, l, expected[l], got[l])
+					}
+					if (mode == ResetLines || l == len(got)-1) && !reset[l] {
+						t.Errorf("%+v, frame %v, line %v: expected the colors to be reset", base, i, l)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTrueColor(t *testing.T) {
+	//The same pixels drawn with 256 and 24-bit colors.
+	top, bottom := color.RGBA{255, 0, 0, 255}, color.RGBA{10, 20, 30, 255}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for x := 0; x < 2; x++ {
+		src.Set(x, 0, top)
+		src.Set(x, 1, bottom)
+	}
+	text := func(img Image) string {
+		img.LoopCount, img.DelayMultiplier, img.Size = 1, 1, Size{Width: 2, Height: 2}
+		if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		var texts []string
+		img.DrawFunc(func(text string, _ time.Duration) error {
+			texts = append(texts, text)
+			return nil
+		})
+		return strings.Join(texts, "")
+	}
+	for _, tc := range []struct {
+		img      Image
+		expected []string
+	}{
+		{Image{}, []string{"\x1b[38;5;" + strconv.Itoa(Colors.Index(bottom)) + "m", "\x1b[48;5;" + strconv.Itoa(Colors.Index(top)) + "m"}},
+		{Image{TrueColor: true}, []string{"\x1b[38;2;10;20;30m", "\x1b[48;2;255;0;0m"}},
+		{Image{TrueColor: true, Reset: ResetFrames}, []string{"\x1b[38;2;10;20;30m", "\x1b[48;2;255;0;0m"}},
+		{Image{TrueColor: true, Glyph: GlyphFullBlock}, []string{"\x1b[38;2;133;10;15m█"}},
+		{Image{TrueColor: true, CompactRuns: true, Background: top}, []string{"\x1b[38;2;10;20;30m"}},
+	} {
+		out := text(tc.img)
+		for _, e := range tc.expected {
+			if !strings.Contains(out, e) {
+				t.Errorf("%+v: expected %q in %q", tc.img, e, out)
+			}
+		}
+		if tc.img.TrueColor && strings.Contains(out, ";5;") {
+			t.Errorf("%+v: expected 24-bit colors only, got %q", tc.img, out)
+		}
+	}
+
+	//Uniform runs are only merged if the 24-bit colors match.
+	src.Set(1, 0, color.RGBA{254, 0, 0, 255})
+	src.Set(1, 1, color.RGBA{254, 0, 0, 255})
+	src.Set(0, 1, top)
+	if out := text(Image{TrueColor: true, CompactRuns: true}); strings.Count(out, " ") != 2 {
+		t.Errorf("expected two runs of one space, got %q", out)
+	}
+
+	for name, img := range map[string]Image{
+		"mode":   {TrueColor: true, Mode: Sextants},
+		"reduce": {TrueColor: true, MaxColors: 4},
+		"basic":  {TrueColor: true, ColorMode: BasicColors},
+	} {
+		if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err == nil {
+			t.Errorf("%v: expected an invalid combination error", name)
+		}
+	}
+}
+
+func TestBraille(t *testing.T) {
+	//A horizontal gradient from black to white.
+	src := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 32; y++ {
+			src.Set(x, y, color.Gray{uint8(x * 255 / 63)})
+		}
+	}
+	dots := func(img Image) (left, right int) {
+		if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		for x := 0; x < img.w; x += 2 {
+			for y := 0; y < img.h; y += 4 {
+				n := bits.OnesCount(uint(img.brailleRune(img.frames[0].picture, x, y) - 0x2800))
+				if x < img.w/2 {
+					left += n
+				} else {
+					right += n
+				}
+			}
+		}
+		return left, right
+	}
+	img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 16, Height: 8}, Mode: Braille, Background: color.Black}
+
+	//Thresholded, the dots are where the gradient is brighter than the threshold.
+	if left, right := dots(img); left != 0 || right != 16*16 {
+		t.Errorf("expected dots on the right half only, got %v on the left and %v on the right", left, right)
+	}
+	img.DotThreshold = 0.25
+	if left, right := dots(img); left != 8*16 || right != 16*16 {
+		t.Errorf("expected dots on the right three quarters, got %v on the left and %v on the right", left, right)
+	}
+
+	//Dithered, gray areas are patterns of dots, denser where brighter.
+	img.DotThreshold, img.Dither = 0, DitherBayer4x4
+	if left, right := dots(img); left == 0 || right == 16*16 || left >= right {
+		t.Errorf("expected a gradient of dots, got %v on the left and %v on the right", left, right)
+	}
+
+	img.DotColor = color.RGBA{0, 255, 0, 255}
+	if err := img.InitFrames([]image.Image{src}, []time.Duration{0}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	green := Colors.Index(img.DotColor)
+	if text := img.braille(img.frames[0].picture, img.w-2, 0); text != foreground("⣿", uint8(green)) {
+		t.Errorf("expected full green dots, got %q", text)
+	}
+}
+
+func TestCells(t *testing.T) {
+	for name, base := range map[string]Image{
+		"halfblocks":    {},
+		"full blocks":   {Glyph: GlyphFullBlock},
+		"sextants":      {Mode: Sextants},
+		"colored shade": {Mode: Shades, ColorShades: true},
+		"braille":       {Mode: Braille},
+		"reset frames":  {Reset: ResetFrames},
+	} {
+		img := base
+		img.Filename, img.LoopCount, img.DelayMultiplier, img.UserWidth = testData+"disposalBackground.gif", 1, 1, 16
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		i := 0
+		img.DrawFunc(func(text string, _ time.Duration) error {
+			expected, _ := emulate(t, text)
+			grid := img.Cells(i)
+			if len(grid) != len(expected) {
+				t.Fatalf("%v, frame %v: expected %v lines, got %v", name, i, len(expected), len(grid))
+			}
+			for l, line := range grid {
+				got := make([]styledCell, len(line))
+				for c, cell := range line {
+					got[c] = styledCell{cell.Glyph, cell.FG, cell.BG}
+					if cell.Glyph == ' ' {
+						got[c].fg = -1
+					}
+				}
+				if !reflect.DeepEqual(got, expected[l]) {
+					t.Errorf("%v, frame %v, line %v: expected %v, got %v", name, i, l, expected[l], got)
+				}
+			}
+			i++
+			return nil
+		})
+		if i != img.FrameCount() || img.Cells(i) != nil {
+			t.Errorf("%v: expected cells for the %v frames only", name, i)
+		}
+	}
+}
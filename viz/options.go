@@ -0,0 +1,529 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"errors"
+	"image/color"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// Option configures an Image created by NewImage.
+type Option func(img *Image) error
+
+// NewImage returns an Image for the file configured with the
+// options, which validate their arguments, ready to be
+// initialized with Init. Images are played once at their
+// original speed unless specified otherwise.
+// This is the preferred way to create images; the fields of
+// Image can still be set directly, in which case they must be
+// valid and DelayMultiplier must be set for animations.
+func NewImage(filename string, opts ...Option) (*Image, error) {
+	img := &Image{
+		Filename:        filename,
+		LoopCount:       1,
+		DelayMultiplier: 1,
+		FillPercent:     100,
+	}
+	for _, opt := range opts {
+		if err := opt(img); err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// WithFS opens the file from the filesystem (see Image.FS).
+func WithFS(fsys fs.FS) Option {
+	return func(img *Image) error {
+		if fsys == nil {
+			return errors.New("filesystem must not be nil")
+		}
+		img.FS = fsys
+		return nil
+	}
+}
+
+// WithReader reads the image from a stream (see Image.Reader).
+func WithReader(r io.Reader) Option {
+	return func(img *Image) error {
+		if r == nil {
+			return errors.New("reader must not be nil")
+		}
+		img.Reader = r
+		return nil
+	}
+}
+
+// WithWidth renders the image with the specified width in
+// pixels, computing the height according to the aspect ratio.
+func WithWidth(width int) Option {
+	return func(img *Image) error {
+		if width <= 0 {
+			return errors.New("width must be positive")
+		}
+		img.Size = Size{Width: width}
+		return nil
+	}
+}
+
+// WithSize renders the image with the specified dimensions
+// (see Size).
+func WithSize(size Size) Option {
+	return func(img *Image) error {
+		if size.Width < 0 || size.Height < 0 || size == (Size{}) {
+			return errors.New("size must be positive")
+		}
+		if size.Fit && (size.Width == 0 || size.Height == 0) {
+			return errors.New("both dimensions must be specified to fit the image within them")
+		}
+		img.Size = size
+		return nil
+	}
+}
+
+// WithLines scales the image to the specified number of
+// terminal lines (see Image.Lines).
+func WithLines(lines int) Option {
+	return func(img *Image) error {
+		if lines <= 0 {
+			return errors.New("number of lines must be positive")
+		}
+		img.Lines = lines
+		return nil
+	}
+}
+
+// WithFillPercent fills the specified percentage (0-100] of
+// the terminal width and height.
+func WithFillPercent(percent float64) Option {
+	return func(img *Image) error {
+		if percent <= 0 || percent > 100 {
+			return errors.New("fill percentage must be between 0 and 100")
+		}
+		img.FillPercent = percent
+		return nil
+	}
+}
+
+// WithUsedLines fits the image below the specified number of
+// terminal lines (see Image.UsedLines).
+func WithUsedLines(lines int) Option {
+	return func(img *Image) error {
+		if lines < 0 {
+			return errors.New("used lines must not be negative")
+		}
+		img.UsedLines = lines
+		return nil
+	}
+}
+
+// WithLoop plays animations the specified number of times, or
+// renders the first frame only if count is 0. LoopForever and
+// LoopAuto are also accepted.
+func WithLoop(count int) Option {
+	return func(img *Image) error {
+		if count < 0 && count != LoopForever && count != LoopAuto {
+			return errors.New("loop count must not be negative")
+		}
+		img.LoopCount = count
+		return nil
+	}
+}
+
+// WithSpeed multiplies the delays between frames (see
+// Image.DelayMultiplier).
+func WithSpeed(delayMultiplier float64) Option {
+	return func(img *Image) error {
+		if delayMultiplier <= 0 {
+			return errors.New("delay multiplier must be positive")
+		}
+		img.DelayMultiplier = delayMultiplier
+		return nil
+	}
+}
+
+// WithDelayRange clamps the delays of the frames to [min, max]
+// milliseconds (see Image.MinDelay), where 0 leaves either
+// bound unset.
+func WithDelayRange(min, max int) Option {
+	return func(img *Image) error {
+		if min < 0 || max < 0 || (max > 0 && min > max) {
+			return errors.New("invalid delay range")
+		}
+		img.MinDelay, img.MaxDelay = min, max
+		return nil
+	}
+}
+
+// WithMaxFPS caps the frame rate of animations (see
+// Image.MaxFPS).
+func WithMaxFPS(fps float64) Option {
+	return func(img *Image) error {
+		if fps <= 0 {
+			return errors.New("frame rate must be positive")
+		}
+		img.MaxFPS = fps
+		return nil
+	}
+}
+
+// WithRenderMode draws the pixels in the specified mode.
+func WithRenderMode(mode RenderMode) Option {
+	return func(img *Image) error {
+		if mode < HalfBlocks || mode > Braille {
+			return errors.New("unknown render mode")
+		}
+		img.Mode = mode
+		return nil
+	}
+}
+
+// WithColorMode maps the pixels to the specified range of
+// terminal colors.
+func WithColorMode(mode ColorMode) Option {
+	return func(img *Image) error {
+		if mode < AllColors || mode > BasicColors {
+			return errors.New("unknown color mode")
+		}
+		img.ColorMode = mode
+		img.ExcludeSystemColors = false
+		return nil
+	}
+}
+
+// WithFilter adjusts the colors of the pixels before they are
+// mapped to terminal colors: the channels are adjusted first,
+// then the luminance is mapped through the colormap if it isn't
+// nil.
+func WithFilter(adjust ChannelAdjust, colormap Colormap) Option {
+	return func(img *Image) error {
+		if colormap != nil && len(colormap) == 0 {
+			return errors.New("colormap must have colors")
+		}
+		img.ChannelAdjust = adjust
+		img.Colormap = colormap
+		return nil
+	}
+}
+
+// WithOverlay blends every pixel toward the color c by alpha
+// (0-1) (see Image.Overlay).
+func WithOverlay(c color.Color, alpha float64) Option {
+	return func(img *Image) error {
+		if alpha < 0 || alpha > 1 {
+			return errors.New("overlay alpha must be between 0 and 1")
+		}
+		img.Overlay = c
+		img.OverlayAlpha = alpha
+		return nil
+	}
+}
+
+// WithResampling scales the image with the specified filter.
+func WithResampling(r Resampling) Option {
+	return func(img *Image) error {
+		if r < ResampleAuto || r > ResampleBox {
+			return errors.New("unknown resampling filter")
+		}
+		img.Resampling = r
+		return nil
+	}
+}
+
+// WithMaxColors reduces the image to at most n colors (see
+// Image.MaxColors).
+func WithMaxColors(n int) Option {
+	return func(img *Image) error {
+		if n <= 0 {
+			return errors.New("number of colors must be positive")
+		}
+		img.MaxColors = n
+		return nil
+	}
+}
+
+// WithPreview samples the image at the rendered size instead
+// of resizing it (see Image.Preview).
+func WithPreview() Option {
+	return func(img *Image) error {
+		img.Preview = true
+		return nil
+	}
+}
+
+// WithDecimate keeps only every nth pixel of every nth row of
+// the image before scaling it (see Image.Decimate).
+func WithDecimate(n int) Option {
+	return func(img *Image) error {
+		if n < 1 {
+			return errors.New("decimation must be at least 1")
+		}
+		img.Decimate = n
+		return nil
+	}
+}
+
+// WithDither dithers the colors in the specified mode (see
+// Image.Dither).
+func WithDither(mode DitherMode) Option {
+	return func(img *Image) error {
+		if mode < DitherNone || mode > DitherBayer8x8 {
+			return errors.New("unknown dither mode")
+		}
+		img.Dither = mode
+		return nil
+	}
+}
+
+// WithCompactRuns draws runs of identical characters with one
+// escape sequence each (see Image.CompactRuns).
+func WithCompactRuns() Option {
+	return func(img *Image) error {
+		img.CompactRuns = true
+		return nil
+	}
+}
+
+// WithBackground composites transparent pixels onto the
+// specified color.
+func WithBackground(c color.Color) Option {
+	return func(img *Image) error {
+		if c == nil {
+			return errors.New("background color must not be nil")
+		}
+		img.Background = c
+		return nil
+	}
+}
+
+// WithTransparency leaves the transparent pixels of the image
+// undrawn (see Image.Transparent).
+func WithTransparency() Option {
+	return func(img *Image) error {
+		img.Transparent = true
+		return nil
+	}
+}
+
+// WithChromaKey draws the pixels within tolerance of the key
+// color as transparent (see Image.ChromaKey).
+func WithChromaKey(key color.Color, tolerance float64) Option {
+	return func(img *Image) error {
+		if key == nil || tolerance < 0 {
+			return errors.New("chroma key must be a color with a tolerance of at least 0")
+		}
+		img.ChromaKey, img.ChromaTolerance = key, tolerance
+		return nil
+	}
+}
+
+// WithLimits sets the limits for decoding untrusted images
+// (see Image.MaxPixels).
+func WithLimits(maxPixels, maxFrames int) Option {
+	return func(img *Image) error {
+		if maxPixels < 0 || maxFrames < 0 {
+			return errors.New("limits must not be negative")
+		}
+		img.MaxPixels, img.MaxFrames = maxPixels, maxFrames
+		return nil
+	}
+}
+
+// WithMaxBytes sets the maximum size of the images read into
+// memory (see Image.MaxBytes).
+func WithMaxBytes(n int64) Option {
+	return func(img *Image) error {
+		if n <= 0 {
+			return errors.New("maximum size must be positive")
+		}
+		img.MaxBytes = n
+		return nil
+	}
+}
+
+// WithOutput sizes the image for the terminal f is connected
+// to (see Image.Output).
+func WithOutput(f *os.File) Option {
+	return func(img *Image) error {
+		if f == nil {
+			return errors.New("output must not be nil")
+		}
+		img.Output = f
+		return nil
+	}
+}
+
+// WithLogger sends diagnostic messages to the logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(img *Image) error {
+		img.Logger = logger
+		return nil
+	}
+}
+
+// WithGrayLevels renders the image in n levels of gray (see
+// Image.GrayLevels).
+func WithGrayLevels(n int) Option {
+	return func(img *Image) error {
+		if n < 2 {
+			return errors.New("number of gray levels must be at least 2")
+		}
+		img.GrayLevels = n
+		return nil
+	}
+}
+
+// WithAlphaThreshold sets the opacity below which pixels are
+// transparent (see Image.AlphaThreshold).
+func WithAlphaThreshold(t float64) Option {
+	return func(img *Image) error {
+		if t <= 0 || t > 1 {
+			return errors.New("alpha threshold must be greater than 0 and at most 1")
+		}
+		img.AlphaThreshold = t
+		return nil
+	}
+}
+
+// WithFrameCounter overlays the frame number and the elapsed
+// time on animations (see Image.FrameCounter).
+func WithFrameCounter() Option {
+	return func(img *Image) error {
+		img.FrameCounter = true
+		return nil
+	}
+}
+
+// WithResetMode sets when the colors of the characters are
+// reset (see Image.Reset).
+func WithResetMode(mode ResetMode) Option {
+	return func(img *Image) error {
+		if mode < ResetCells || mode > ResetFrames {
+			return errors.New("unknown reset mode")
+		}
+		img.Reset = mode
+		return nil
+	}
+}
+
+// WithRedrawMode sets how the cursor is moved back to draw
+// each frame of animations (see Image.Redraw).
+func WithRedrawMode(mode RedrawMode) Option {
+	return func(img *Image) error {
+		if mode < RedrawLineUp || mode > RedrawClear {
+			return errors.New("unknown redraw mode")
+		}
+		img.Redraw = mode
+		return nil
+	}
+}
+
+// WithMirror draws the image mirrored with the symmetry (see
+// Image.Mirror).
+func WithMirror(s Symmetry) Option {
+	return func(img *Image) error {
+		if s < MirrorNone || s > MirrorQuad {
+			return errors.New("unknown symmetry")
+		}
+		img.Mirror = s
+		return nil
+	}
+}
+
+// WithForceColors uses 256 colors whatever the terminal
+// supports (see Image.ForceColors).
+func WithForceColors() Option {
+	return func(img *Image) error {
+		img.ForceColors = true
+		return nil
+	}
+}
+
+// WithBell rings the terminal bell once the image is drawn
+// (see Image.Bell).
+func WithBell() Option {
+	return func(img *Image) error {
+		img.Bell = true
+		return nil
+	}
+}
+
+// WithOnComplete calls f once the image is drawn (see
+// Image.OnComplete).
+func WithOnComplete(f func()) Option {
+	return func(img *Image) error {
+		img.OnComplete = f
+		return nil
+	}
+}
+
+// WithOnProgress calls f as Init prepares each frame (see
+// Image.OnProgress).
+func WithOnProgress(f func(done, total int)) Option {
+	return func(img *Image) error {
+		img.OnProgress = f
+		return nil
+	}
+}
+
+// WithSpinner draws a spinner on stderr while Init runs (see
+// Image.Spinner).
+func WithSpinner() Option {
+	return func(img *Image) error {
+		img.Spinner = true
+		return nil
+	}
+}
+
+// WithFirstFrame draws the first frame of animations onto
+// canvas as soon as it is ready (see Image.FirstFrameCanvas).
+func WithFirstFrame(canvas Canvas) Option {
+	return func(img *Image) error {
+		img.FirstFrameCanvas = canvas
+		return nil
+	}
+}
+
+// WithDots sets the luminance threshold (0-1) above which the
+// pixels of the Braille mode are drawn as dots and the color of
+// the dots, or the terminal's foreground color if nil (see
+// Image.DotThreshold).
+func WithDots(threshold float64, c color.Color) Option {
+	return func(img *Image) error {
+		if threshold <= 0 || threshold >= 1 {
+			return errors.New("dot threshold must be between 0 and 1")
+		}
+		img.DotThreshold = threshold
+		img.DotColor = c
+		return nil
+	}
+}
+
+// WithAutoTrim crops the borders of uniform color within
+// tolerance (see Image.AutoTrim).
+func WithAutoTrim(tolerance float64) Option {
+	return func(img *Image) error {
+		if tolerance < 0 {
+			return errors.New("trim tolerance must not be negative")
+		}
+		img.AutoTrim = true
+		img.TrimTolerance = tolerance
+		return nil
+	}
+}
+
+// WithBottomUp draws the lines of the frames from the bottom up
+// (see Image.BottomUp).
+func WithBottomUp() Option {
+	return func(img *Image) error {
+		img.BottomUp = true
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestNewImage(t *testing.T) {
+	img, err := NewImage(testData+"disposalNone.gif", WithWidth(16), WithColorMode(NoSystemColors))
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if img.LoopCount != 1 || img.DelayMultiplier != 1 || img.FillPercent != 100 {
+		t.Errorf("expected defaults to play the image once at its speed, got %+v", img)
+	}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if img.w != 16 || img.firstColor() != 16 {
+		t.Errorf("expected the options to apply, got width %v and first color %v", img.w, img.firstColor())
+	}
+
+	for name, img := range map[string]Image{
+		"glyph":          {Mode: Sextants, Glyph: GlyphFullBlock},
+		"colored shades": {ColorShades: true},
+		"color mode":     {ColorMode: BasicColors + 1},
+		"loop count":     {LoopCount: -3},
+	} {
+		if err := img.InitFrames([]image.Image{filledFrame(0)}, []time.Duration{0}); err == nil {
+			t.Errorf("%v: expected an invalid combination error", name)
+		}
+	}
+
+	for name, opt := range map[string]Option{
+		"width":      WithWidth(0),
+		"fit":        WithSize(Size{Width: 10, Fit: true}),
+		"loop":       WithLoop(-3),
+		"speed":      WithSpeed(0),
+		"mode":       WithRenderMode(Braille + 1),
+		"color mode": WithColorMode(BasicColors + 1),
+		"fill":       WithFillPercent(150),
+		"delays":     WithDelayRange(200, 100),
+		"overlay":    WithOverlay(color.Black, 1.5),
+		"redraw":     WithRedrawMode(RedrawClear + 1),
+		"decimate":   WithDecimate(0),
+	} {
+		if _, err := NewImage("", opt); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
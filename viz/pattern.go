@@ -0,0 +1,86 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Pattern is a test pattern for checking how a terminal
+// renders colors without an image file.
+type Pattern int
+
+const (
+	// PatternBars draws vertical color bars: white, yellow,
+	// cyan, green, magenta, red, blue and black.
+	PatternBars Pattern = iota
+	// PatternPalette draws the 256 terminal colors as a grid
+	// of 16x16 swatches, in order.
+	PatternPalette
+	// PatternGradient draws horizontal red, green, blue and
+	// gray ramps from black to full intensity, one above the
+	// other.
+	PatternGradient
+)
+
+// Patterns are the test patterns by name.
+var Patterns = map[string]Pattern{
+	"bars":     PatternBars,
+	"palette":  PatternPalette,
+	"gradient": PatternGradient,
+}
+
+// NewPattern returns the test pattern at w x h pixels, to be
+// rendered with Image.InitFrames like any other picture.
+func NewPattern(p Pattern, w, h int) image.Image {
+	m := image.NewRGBA(image.Rect(0, 0, w, h))
+	switch p {
+	case PatternPalette:
+		for i, c := range Colors {
+			col, row := i%16, i/16
+			r := image.Rect(col*w/16, row*h/16, (col+1)*w/16, (row+1)*h/16)
+			draw.Draw(m, r, image.NewUniform(c), image.ZP, draw.Src)
+		}
+	case PatternGradient:
+		for y := 0; y < h; y++ {
+			band := y * 4 / h
+			for x := 0; x < w; x++ {
+				v := uint8(x * 255 / maxInt(w-1, 1))
+				c := color.RGBA{A: 0xff}
+				switch band {
+				case 0:
+					c.R = v
+				case 1:
+					c.G = v
+				case 2:
+					c.B = v
+				default:
+					c.R, c.G, c.B = v, v, v
+				}
+				m.SetRGBA(x, y, c)
+			}
+		}
+	default:
+		bars := []color.RGBA{
+			{0xff, 0xff, 0xff, 0xff}, {0xff, 0xff, 0x00, 0xff}, {0x00, 0xff, 0xff, 0xff}, {0x00, 0xff, 0x00, 0xff},
+			{0xff, 0x00, 0xff, 0xff}, {0xff, 0x00, 0x00, 0xff}, {0x00, 0x00, 0xff, 0xff}, {0x00, 0x00, 0x00, 0xff},
+		}
+		for i, c := range bars {
+			r := image.Rect(i*w/len(bars), 0, (i+1)*w/len(bars), h)
+			draw.Draw(m, r, image.NewUniform(c), image.ZP, draw.Src)
+		}
+	}
+	return m
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestPattern(t *testing.T) {
+	m := NewPattern(PatternPalette, 160, 80)
+	for _, i := range []int{0, 9, 16, 231, 255} {
+		x, y := i%16*10+5, i/16*5+2
+		if c := color.RGBAModel.Convert(m.At(x, y)); c != color.RGBAModel.Convert(Colors[i]) {
+			t.Errorf("expected color %v at %v,%v, got %v", i, x, y, c)
+		}
+	}
+
+	m = NewPattern(PatternGradient, 256, 4)
+	if c := m.At(255, 0); c != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("expected the red ramp to end with red, got %v", c)
+	}
+	if c := m.At(128, 3); c != (color.RGBA{128, 128, 128, 255}) {
+		t.Errorf("expected the gray ramp in the bottom band, got %v", c)
+	}
+
+	//Patterns go through the normal pipeline.
+	img := Image{LoopCount: 1, DelayMultiplier: 1, Size: Size{Width: 64, Height: 8}}
+	if err := img.InitFrames([]image.Image{NewPattern(PatternBars, 80, 40)}, []time.Duration{0}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if c := img.frames[0].picture[3][4]; c != 15 {
+		t.Errorf("expected the first bar to be white, got color %v", c)
+	}
+}
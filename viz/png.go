@@ -0,0 +1,124 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"reflect"
+)
+
+// EncodePNG writes the first frame of the image to w as a PNG
+// with one pixel per rendered pixel (i.e. two per terminal
+// character), in terminal colors.
+// If metadata is set, the source file name (as an iTXt chunk,
+// since it may not be Latin-1) and the render parameters (the
+// render mode, color mode, dithering, number of colors,
+// resampling filter and dimensions, in a tEXt Comment) are
+// recorded so that the PNG documents how it was produced.
+func (img *Image) EncodePNG(w io.Writer, metadata bool) error {
+	if len(img.frames) == 0 {
+		return errors.New("image is not initialized")
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, PictureImage(img.frames[0].picture)); err != nil {
+		return err
+	}
+	if !metadata {
+		_, err := b.WriteTo(w)
+		return err
+	}
+
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4 //signature, then length, type, data and CRC of IHDR
+	data := b.Bytes()
+	if _, err := w.Write(data[:ihdrEnd]); err != nil {
+		return err
+	}
+	if img.Filename != "" {
+		//iTXt: keyword, no compression, no language tag nor translated keyword, then UTF-8 text
+		if err := writeChunk(w, "iTXt", "Title\x00\x00\x00\x00\x00"+filepath.Base(img.Filename)); err != nil {
+			return err
+		}
+	}
+	for _, kv := range [][2]string{
+		{"Software", "img"},
+		{"Comment", fmt.Sprintf("mode=%v colormode=%v dither=%v colors=%v resample=%v width=%v height=%v",
+			nameOf(RenderModes, img.Mode), nameOf(ColorModes, img.colorMode()), nameOf(DitherModes, img.Dither),
+			img.MaxColors, nameOf(Resamplings, img.Resampling), img.w, img.h)},
+	} {
+		if err := writeChunk(w, "tEXt", kv[0]+"\x00"+kv[1]); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(data[ihdrEnd:])
+	return err
+}
+
+// nameOf returns the name of value in names, a map of names
+// to values such as RenderModes.
+func nameOf(names, value interface{}) string {
+	iter := reflect.ValueOf(names).MapRange()
+	for iter.Next() {
+		if iter.Value().Interface() == value {
+			return iter.Key().String()
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// writeChunk writes a PNG chunk of the specified type.
+func writeChunk(w io.Writer, chunkType, data string) error {
+	chunk := []byte(chunkType + data)
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint32(len(chunk)-4))
+	b.Write(chunk)
+	binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// PictureImage returns a picture of terminal colors, indexed
+// by column then row as returned by Scale, as an image with one
+// pixel per rendered pixel. This is the inverse of Scale, which
+// lets renders be compared or processed as images.
+func PictureImage(picture [][]uint8) *image.Paletted {
+	w, h := len(picture), 0
+	if w > 0 {
+		h = len(picture[0])
+	}
+	p := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette(Colors))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			p.SetColorIndex(x, y, picture[x][y])
+		}
+	}
+	return p
+}
+
+// FrameImage returns the ith frame of the image, in the order
+// they are stored (see FrameCount), as rendered (see
+// PictureImage).
+func (img *Image) FrameImage(i int) (*image.Paletted, error) {
+	if i < 0 || i >= len(img.frames) {
+		return nil, fmt.Errorf("frame %v out of range [0, %v)", i, len(img.frames))
+	}
+	return PictureImage(img.frames[i].picture), nil
+}
+
+// FrameCount returns the number of distinct frames of the
+// image once initialized (identical consecutive frames are
+// merged).
+func (img *Image) FrameCount() int {
+	return len(img.frames)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image/png"
+	"testing"
+)
+
+func TestPictureImageRoundTrip(t *testing.T) {
+	//Every terminal color, 16 per column.
+	picture := make([][]uint8, 16)
+	for x := range picture {
+		picture[x] = make([]uint8, 16)
+		for y := range picture[x] {
+			picture[x][y] = uint8(y*16 + x)
+		}
+	}
+	m := PictureImage(picture)
+	if b := m.Bounds(); b.Dx() != 16 || b.Dy() != 16 {
+		t.Fatalf("expected 16x16 pixels, got %v", b)
+	}
+
+	//Mapping the colors back gives the same colors, and the same indices from then on
+	//(the palette has duplicates, e.g. 0 and 16 are both black).
+	again := Scale(m, 16, 16, AllColors)
+	for x := range picture {
+		for y := range picture[x] {
+			i, j := picture[x][y], again[x][y]
+			if Colors[i] != Colors[j] {
+				t.Errorf("color %v: expected %v, got %v (color %v)", i, Colors[i], Colors[j], j)
+			}
+			if k := uint8(Colors.Index(PictureImage(again).At(x, y))); k != j {
+				t.Errorf("color %v: expected the index to be stable, got %v then %v", i, j, k)
+			}
+		}
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	img := newTestImage("color_matrix.png", 1, t)
+	var b bytes.Buffer
+	if err := img.EncodePNG(&b, true); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	encoded := b.Bytes()
+	m, err := png.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal("expecting a valid PNG, got", err)
+	}
+	expected, _ := img.FrameImage(0)
+	for x := 0; x < img.w; x++ {
+		for y := 0; y < img.h; y++ {
+			if Colors.Index(m.At(x, y)) != Colors.Index(expected.At(x, y)) {
+				t.Fatalf("pixel %v,%v: expected %v, got %v", x, y, expected.At(x, y), m.At(x, y))
+			}
+		}
+	}
+
+	text := textChunks(encoded, t)
+	for keyword, value := range map[string]string{
+		"iTXt Title":    "color_matrix.png",
+		"tEXt Software": "img",
+		"tEXt Comment":  fmt.Sprintf("mode=halfblocks colormode=all dither=none colors=0 resample=auto width=%v height=%v", img.w, img.h),
+	} {
+		if text[keyword] != value {
+			t.Errorf("%v: expected %q, got %q", keyword, value, text[keyword])
+		}
+	}
+	if len(text) != 3 {
+		t.Errorf("expected 3 text chunks, got %v", text)
+	}
+
+	//No title without a file name.
+	img.Filename = ""
+	b.Reset()
+	if err := img.EncodePNG(&b, true); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if text := textChunks(b.Bytes(), t); len(text) != 2 || text["iTXt Title"] != "" {
+		t.Errorf("expected no title, got %v", text)
+	}
+}
+
+// textChunks parses the tEXt and iTXt chunks of a PNG into
+// their text by type and keyword (e.g. "tEXt Comment"),
+// checking their CRCs.
+func textChunks(data []byte, t *testing.T) map[string]string {
+	text := map[string]string{}
+	for data = data[8:]; len(data) >= 12; {
+		n := int(binary.BigEndian.Uint32(data))
+		chunk := data[4 : 8+n]
+		if crc := binary.BigEndian.Uint32(data[8+n:]); crc != crc32.ChecksumIEEE(chunk) {
+			t.Fatalf("%s: expected CRC %x, got %x", chunk[:4], crc32.ChecksumIEEE(chunk), crc)
+		}
+		fields := bytes.SplitN(chunk[4:], []byte{0}, 2)
+		switch string(chunk[:4]) {
+		case "tEXt":
+			text["tEXt "+string(fields[0])] = string(fields[1])
+		case "iTXt": //compression flag and method, then language tag and translated keyword
+			rest := fields[1]
+			if rest[0] != 0 {
+				t.Fatal("expected an uncompressed iTXt chunk")
+			}
+			parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+			text["iTXt "+string(fields[0])] = string(parts[2])
+		}
+		data = data[12+n:]
+	}
+	return text
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+// Resampling is the filter with which images are scaled,
+// trading quality for speed.
+type Resampling int
+
+const (
+	// ResampleAuto scales still images and small animations with
+	// Lanczos, and animations with many frames or pixels with the
+	// faster bilinear filter, whose loss of sharpness goes
+	// unnoticed in motion (see autoResampleFrames).
+	ResampleAuto Resampling = iota
+	// ResampleNearest picks the nearest pixel, which is the
+	// fastest and keeps pixel art crisp.
+	ResampleNearest
+	// ResampleBilinear interpolates linearly between pixels.
+	ResampleBilinear
+	// ResampleBicubic interpolates with cubic splines.
+	ResampleBicubic
+	// ResampleLanczos (Lanczos3) gives the sharpest results, at
+	// a higher cost.
+	ResampleLanczos
+	// ResampleBox averages the source pixels covered by each
+	// pixel (area averaging), which gives faithful thumbnails of
+	// screenshots and documents without the ringing of Lanczos
+	// around sharp edges (e.g. text). Enlarged images are
+	// blocky, as with ResampleNearest.
+	ResampleBox
+)
+
+// Resamplings are the resampling filters by name.
+var Resamplings = map[string]Resampling{
+	"auto":     ResampleAuto,
+	"nearest":  ResampleNearest,
+	"bilinear": ResampleBilinear,
+	"bicubic":  ResampleBicubic,
+	"lanczos":  ResampleLanczos,
+	"box":      ResampleBox,
+}
+
+// Thresholds above which ResampleAuto scales animations with
+// the bilinear filter: the number of frames, or the total
+// number of source pixels to scale (frames x width x height).
+// Below them, scaling with Lanczos takes well under a second.
+const (
+	autoResampleFrames = 100
+	autoResamplePixels = 8 << 20
+)
+
+// interpolation returns the filter with which frames of
+// w x h pixels are scaled.
+func (r Resampling) interpolation(frames, w, h int) resize.InterpolationFunction {
+	switch r {
+	case ResampleNearest:
+		return resize.NearestNeighbor
+	case ResampleBilinear:
+		return resize.Bilinear
+	case ResampleBicubic:
+		return resize.Bicubic
+	case ResampleLanczos:
+		return resize.Lanczos3
+	case ResampleBox:
+		return resize.NearestNeighbor //unused: see scale
+	}
+	if frames >= autoResampleFrames || (frames > 1 && frames*w*h >= autoResamplePixels) {
+		return resize.Bilinear
+	}
+	return resize.Lanczos3
+}
+
+// resamplingNames are the names of the interpolation
+// functions, for logging.
+var resamplingNames = map[resize.InterpolationFunction]string{
+	resize.NearestNeighbor: "nearest",
+	resize.Bilinear:        "bilinear",
+	resize.Bicubic:         "bicubic",
+	resize.Lanczos3:        "lanczos",
+}
+
+// initResampling chooses the filter with which the frames of
+// w x h pixels are scaled, and records their number for
+// OnProgress.
+func (img *Image) initResampling(frames, w, h int) {
+	img.total = frames
+	if img.Preview {
+		img.logf("previewing %v frame(s) of %vx%v with nearest-neighbor sampling", frames, w, h)
+		return
+	}
+	img.interp = img.Resampling.interpolation(frames, w, h)
+	if img.Resampling == ResampleBox {
+		img.logf("scaling %v frame(s) of %vx%v by averaging pixels", frames, w, h)
+	} else if img.Resampling == ResampleAuto {
+		img.logf("scaling %v frame(s) of %vx%v with the %v filter", frames, w, h, resamplingNames[img.interp])
+	}
+}
+
+// scale resizes f to w x h pixels with the resolved filter.
+func (img *Image) scale(f image.Image, w, h int) image.Image {
+	if img.Resampling == ResampleBox {
+		return boxResize(f, w, h)
+	}
+	return resize.Resize(uint(w), uint(h), f, img.interp)
+}
+
+// boxResize resizes src to w x h pixels, where each pixel is
+// the average of the source pixels it covers, weighted by the
+// covered area.
+func boxResize(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sx, sy := float64(b.Dx())/float64(w), float64(b.Dy())/float64(h)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := float64(y)*sy, float64(y+1)*sy
+		for x := 0; x < w; x++ {
+			x0, x1 := float64(x)*sx, float64(x+1)*sx
+			var sum [4]float64
+			var area float64
+			for j := int(y0); float64(j) < y1 && j < b.Dy(); j++ {
+				wy := math.Min(y1, float64(j+1)) - math.Max(y0, float64(j))
+				for i := int(x0); float64(i) < x1 && i < b.Dx(); i++ {
+					weight := wy * (math.Min(x1, float64(i+1)) - math.Max(x0, float64(i)))
+					r, g, bl, a := src.At(b.Min.X+i, b.Min.Y+j).RGBA()
+					sum[0] += weight * float64(r)
+					sum[1] += weight * float64(g)
+					sum[2] += weight * float64(bl)
+					sum[3] += weight * float64(a)
+					area += weight
+				}
+			}
+			var c [4]uint8
+			for k := range c {
+				c[k] = uint8(math.Round(sum[k] / area / 0x101))
+			}
+			dst.SetRGBA(x, y, color.RGBA{c[0], c[1], c[2], c[3]})
+		}
+	}
+	return dst
+}
+
+// decimated is a view of src keeping every nth pixel of every
+// nth row, from the top left one (see Image.Decimate).
+type decimated struct {
+	src image.Image
+	n   int
+}
+
+func (d decimated) ColorModel() color.Model { return d.src.ColorModel() }
+
+func (d decimated) Bounds() image.Rectangle {
+	b := d.src.Bounds()
+	return image.Rect(0, 0, (b.Dx()+d.n-1)/d.n, (b.Dy()+d.n-1)/d.n)
+}
+
+func (d decimated) At(x, y int) color.Color {
+	b := d.src.Bounds()
+	return d.src.At(b.Min.X+x*d.n, b.Min.Y+y*d.n)
+}
+
+// decimate returns f keeping every nth pixel of every nth row,
+// or f if n is 0 or 1.
+func decimate(f image.Image, n int) image.Image {
+	if n <= 1 {
+		return f
+	}
+	return decimated{f, n}
+}
+
+// sampled is a view of src at w x h pixels, where each pixel is
+// the source pixel at its center (nearest-neighbor sampling).
+// Unlike resizing, only the w x h sampled pixels of src are
+// read, so it takes no time for huge images.
+type sampled struct {
+	src  image.Image
+	w, h int
+}
+
+func (s sampled) ColorModel() color.Model { return s.src.ColorModel() }
+func (s sampled) Bounds() image.Rectangle { return image.Rect(0, 0, s.w, s.h) }
+
+func (s sampled) At(x, y int) color.Color {
+	b := s.src.Bounds()
+	return s.src.At(b.Min.X+(2*x+1)*b.Dx()/(2*s.w), b.Min.Y+(2*y+1)*b.Dy()/(2*s.h))
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"time"
+)
+
+// RevealDirection is the order in which Draw reveals a
+// static image (see Image.Reveal).
+type RevealDirection int
+
+const (
+	// RevealNone draws the image at once.
+	RevealNone RevealDirection = iota
+	// RevealRows draws the image line by line, from top to bottom.
+	RevealRows
+	// RevealColumns draws the image column by column, from left to right.
+	RevealColumns
+)
+
+// RevealDirections maps the names of the reveal directions to
+// their values (e.g. for command line flags).
+var RevealDirections = map[string]RevealDirection{
+	"none":    RevealNone,
+	"rows":    RevealRows,
+	"columns": RevealColumns,
+}
+
+// Easing maps the elapsed fraction (0-1) of the time a reveal
+// takes to the fraction of the image revealed (0-1), growing
+// from 0 to 1.
+type Easing func(t float64) float64
+
+// Easing curves.
+var (
+	// EaseLinear reveals the image at a constant pace.
+	EaseLinear Easing = func(t float64) float64 { return t }
+	// EaseIn starts slowly and speeds up.
+	EaseIn Easing = func(t float64) float64 { return t * t }
+	// EaseOut starts quickly and slows down.
+	EaseOut Easing = func(t float64) float64 { return 1 - (1-t)*(1-t) }
+	// EaseInOut starts and ends slowly.
+	EaseInOut Easing = func(t float64) float64 { return t * t * (3 - 2*t) }
+)
+
+// Easings maps the names of the easing curves to their values
+// (e.g. for command line flags).
+var Easings = map[string]Easing{
+	"linear": EaseLinear,
+	"in":     EaseIn,
+	"out":    EaseOut,
+	"inout":  EaseInOut,
+}
+
+// EaseDelays returns delays between the steps of a reveal (see
+// Image.RevealDelays) that pace it along the easing curve,
+// taking as long in total as with the average delay between
+// every step.
+func EaseDelays(e Easing, average time.Duration) func(step, steps int) time.Duration {
+	//The time at which the fraction p of the image is revealed.
+	at := func(p float64) float64 {
+		lo, hi := 0.0, 1.0
+		for i := 0; i < 32; i++ {
+			if mid := (lo + hi) / 2; e(mid) < p {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		return (lo + hi) / 2
+	}
+	return func(step, steps int) time.Duration {
+		if steps < 2 {
+			return 0
+		}
+		n := float64(steps - 1)
+		d := at(float64(step+1)/n) - at(float64(step)/n)
+		return time.Duration(math.Round(float64(average) * n * d))
+	}
+}
+
+// revealDelay returns the delay in milliseconds after revealing
+// the step (0-based) out of steps lines or columns.
+func (img *Image) revealDelay(step, steps int) int {
+	if img.RevealDelays != nil {
+		return int(img.RevealDelays(step, steps) / time.Millisecond)
+	}
+	return int(img.RevealDelay / time.Millisecond)
+}
+
+// reveal draws a frame incrementally in the direction of
+// Reveal, sleeping between lines or columns (see revealDelay),
+// and leaves the cursor on the line below it as drawFrame does.
+func (img *Image) reveal(canvas Canvas, frame frame) error {
+	cw, ch := img.Mode.cellSize()
+	if img.Reveal == RevealRows {
+		lines := img.lines()
+		for y := 0; y < img.h; y = y + ch {
+			if err := img.drawRegion(canvas, frame, image.Rect(0, y, img.w, y+ch)); err != nil {
+				return err
+			}
+			if y+ch >= img.h {
+				break
+			}
+			if err := canvas.Sleep(img.revealDelay(y/ch, lines)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	//Make room for the image so the terminal doesn't scroll
+	//while the columns are drawn from the top line down.
+	lines := img.lines()
+	for l := 0; l < lines; l++ {
+		if err := canvas.NewLine(); err != nil {
+			return err
+		}
+	}
+	if err := canvas.LineUp(lines); err != nil {
+		return err
+	}
+	var p *pen
+	if img.colorDepth() != 256 {
+		p = newPen(img.colorDepth()) //only the pen draws the basic and 24-bit colors
+	}
+	for x := 0; x < img.w; x = x + cw {
+		for y := 0; y < img.h; y = y + ch {
+			if err := img.paintCell(canvas, frame, x, y, p); err != nil {
+				return err
+			}
+			if p != nil {
+				if reset := p.reset(); reset != "" { //each cell stands alone as the cursor jumps between them
+					if err := printText(canvas, reset); err != nil {
+						return err
+					}
+				}
+			}
+			if y+ch < img.h {
+				if err := printText(canvas, "\x1b[B\x1b[D"); err != nil { //down to the next cell of the column
+					return err
+				}
+			}
+		}
+		if lines > 1 {
+			if err := printText(canvas, fmt.Sprintf("\x1b[%vA", lines-1)); err != nil { //back to the top line
+				return err
+			}
+		}
+		if x+cw >= img.w {
+			break
+		}
+		if err := canvas.Sleep(img.revealDelay(x/cw, img.w/cw)); err != nil {
+			return err
+		}
+	}
+	for l := 0; l < lines; l++ {
+		if err := canvas.NewLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+// Scroll renders a view of the first frame of the image that
+// fits a terminal of the specified width and number of lines,
+// and lets the user move it around with the arrow (or h/j/k/l),
+// Page Up/Down (or b/Space) and Home/End (or g/G) keys read from
+// keys, until q is pressed. This is useful to read images that are larger than
+// the terminal (see NoShrink).
+// keys would typically be stdin with the terminal in raw mode
+// (see terminal.MakeRaw), so new lines are accompanied by
+// carriage returns.
+func (img *Image) Scroll(canvas Canvas, keys io.Reader, width, lines int) error {
+	return img.browse(canvas, keys, width, lines, false)
+}
+
+// Page renders the first frame of the image a screenful of
+// the specified number of lines at a time, like a pager, with
+// a position indicator on the line below. This is the way to
+// read images taller than the terminal (e.g. screenshots),
+// rendered at the terminal width: Space (or f, Page Down) shows
+// the next screenful, b (or Page Up) the previous one, Enter
+// (or j, Down) and k (or Up) move by a line, Home/End (or g/G)
+// go to the top/bottom, and q quits.
+// As with Scroll, keys would typically be stdin with the
+// terminal in raw mode.
+func (img *Image) Page(canvas Canvas, keys io.Reader, lines int) error {
+	cw, _ := img.Mode.cellSize()
+	return img.browse(canvas, keys, (img.w+cw-1)/cw, lines, true)
+}
+
+// browse implements Scroll and Page, which shows the position
+// of the view on the line below it if indicator is set.
+func (img *Image) browse(canvas Canvas, keys io.Reader, width, lines int, indicator bool) error {
+	canvas = rawCanvas{canvas}
+	cw, ch := img.Mode.cellSize()
+	view := image.Rect(0, 0, width*cw, lines*ch).Intersect(image.Rect(0, 0, img.w, img.h))
+	vw, vh, total := view.Dx(), view.Dy(), img.h/ch
+
+	if err := printText(canvas, "\x1b[?25l"); err != nil { //hide cursor
+		return err
+	}
+	buf := make([]byte, 8)
+	for {
+		if err := img.DrawRegion(canvas, view); err != nil {
+			return err
+		}
+		if indicator {
+			top, bottom := view.Min.Y/ch, view.Max.Y/ch
+			percent := 100
+			if total > 0 {
+				percent = 100 * bottom / total
+			}
+			text := fmt.Sprintf(" lines %v-%v of %v (%v%%) ", top+1, bottom, total, percent)
+			if err := printText(canvas, "\x1b[7m"+text+"\x1b[0m\x1b[K"); err != nil { //reverse video
+				return err
+			}
+		}
+		if err := canvas.Sleep(0); err != nil { //display the view
+			return err
+		}
+
+		n, err := keys.Read(buf)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		x, y := view.Min.X, view.Min.Y
+		switch string(buf[:n]) {
+		case "\x1b[A", "k":
+			y -= ch
+		case "\x1b[B", "j", "\r", "\n":
+			y += ch
+		case "\x1b[D", "h":
+			x -= cw
+		case "\x1b[C", "l":
+			x += cw
+		case "\x1b[5~", "b":
+			y -= vh
+		case "\x1b[6~", " ", "f":
+			y += vh
+		case "\x1b[H", "g":
+			y = 0
+		case "\x1b[F", "G":
+			y = img.h
+		case "q", "\x03":
+			n = 0
+		}
+		if indicator {
+			if err := printText(canvas, "\r"); err != nil {
+				return err
+			}
+		}
+		if n == 0 {
+			break
+		}
+
+		x = clamp(x, 0, img.w-vw)
+		y = clamp(y, 0, img.h-vh)
+		view = image.Rect(x, y, x+vw, y+vh)
+		if vh/ch > 0 { //an empty view takes no lines, and moving up 0 lines moves up 1 on most terminals
+			if err := canvas.LineUp(vh / ch); err != nil {
+				return err
+			}
+		}
+	}
+	cleanup := "\x1b[?25h" //show cursor
+	if indicator {
+		cleanup = "\x1b[K" + cleanup //erase the indicator
+	}
+	if err := printText(canvas, cleanup); err != nil {
+		return err
+	}
+	return canvas.Close()
+}
+
+// rawCanvas emits carriage returns along with new lines
+// as terminals in raw mode don't.
+type rawCanvas struct {
+	Canvas
+}
+
+func (rc rawCanvas) Print(text string) error {
+	return printText(rc.Canvas, text)
+}
+
+func (rc rawCanvas) NewLine() error {
+	if err := printText(rc.Canvas, "\r"); err != nil {
+		return err
+	}
+	return rc.Canvas.NewLine()
+}
+
+func clamp(v, min, max int) int {
+	if v > max {
+		v = max
+	}
+	if v < min {
+		v = min
+	}
+	return v
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Slide transitions.
+const (
+	// TransitionNone draws the next slide below the current one.
+	TransitionNone = "none"
+	// TransitionReplace erases the current slide and draws the
+	// next one in its place.
+	TransitionReplace = "replace"
+)
+
+// Slideshow is a sequence of images rendered one after the
+// other, as described by a manifest file.
+type Slideshow struct {
+	Slides []Slide `json:"slides"`
+}
+
+// Slide is an image of a slideshow.
+type Slide struct {
+	// File is the image, relative to the manifest.
+	File string `json:"file"`
+	// Duration is the number of seconds for which the slide is
+	// displayed, after its animation has played if it's a GIF.
+	Duration float64 `json:"duration"`
+	// Caption is printed below the image, wrapped to its width.
+	Caption string `json:"caption"`
+	// Align is the alignment of the caption ("left" or
+	// "center"). Defaults to "left".
+	Align string `json:"align"`
+	// Transition is how the slide replaces the previous one
+	// (TransitionNone or TransitionReplace). Defaults to
+	// TransitionNone.
+	Transition string `json:"transition"`
+}
+
+// LoadSlideshow reads a JSON manifest such as:
+//
+//	{"slides": [
+//	  {"file": "car.png", "duration": 2, "caption": "The car"},
+//	  {"file": "wheel.gif", "transition": "replace"}
+//	]}
+//
+// It fails if any of the files is missing, listing all of them.
+func LoadSlideshow(filename string) (*Slideshow, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var show Slideshow
+	if err := json.Unmarshal(b, &show); err != nil {
+		return nil, fmt.Errorf("invalid slideshow %s: %v", filename, err)
+	}
+	if len(show.Slides) == 0 {
+		return nil, fmt.Errorf("slideshow %s has no slides", filename)
+	}
+
+	var missing []string
+	dir := filepath.Dir(filename)
+	for i := range show.Slides {
+		s := &show.Slides[i]
+		if s.File == "" {
+			return nil, fmt.Errorf("slide %v of %s has no file", i+1, filename)
+		}
+		if !filepath.IsAbs(s.File) {
+			s.File = filepath.Join(dir, s.File)
+		}
+		if _, err := os.Stat(s.File); err != nil {
+			missing = append(missing, s.File)
+		}
+		if _, ok := Aligns[s.Align]; !ok && s.Align != "" {
+			return nil, fmt.Errorf("slide %v of %s has an unknown caption alignment %q", i+1, filename, s.Align)
+		}
+		switch s.Transition {
+		case "":
+			s.Transition = TransitionNone
+		case TransitionNone, TransitionReplace:
+		default:
+			return nil, fmt.Errorf("slide %v of %s has an unknown transition %q", i+1, filename, s.Transition)
+		}
+		if s.Duration < 0 {
+			return nil, fmt.Errorf("slide %v of %s has a negative duration", i+1, filename)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing files in slideshow %s: %s", filename, strings.Join(missing, ", "))
+	}
+	return &show, nil
+}
+
+// Draw renders the slides onto the canvas in order and closes
+// it. Each slide is rendered with the options of template,
+// except for the file name and the caption, so a GIF looping
+// forever never gives way to the next slide.
+func (show *Slideshow) Draw(canvas Canvas, template Image) error {
+	lines := 0 //occupied by the previous slide
+	for _, s := range show.Slides {
+		img := template
+		img.Filename = s.File
+		img.Caption, img.CaptionAlign = s.Caption, Aligns[s.Align]
+		if err := img.Init(); err != nil {
+			return err
+		}
+
+		if s.Transition == TransitionReplace && lines > 0 {
+			if err := canvas.LineUp(lines); err != nil {
+				return err
+			}
+			if err := printText(canvas, "\x1b[J"); err != nil { //erase below the cursor
+				return err
+			}
+		}
+		if err := img.play(canvas); err != nil {
+			return err
+		}
+		lines = img.lines() + len(img.captionLines())
+		if err := canvas.Sleep(int(s.Duration * 1000)); err != nil {
+			return err
+		}
+	}
+	return canvas.Close()
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeManifest(t *testing.T, manifest string) string {
+	filename := filepath.Join(t.TempDir(), "show.json")
+	if err := os.WriteFile(filename, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadSlideshowMissingFiles(t *testing.T) {
+	filename := writeManifest(t, `{"slides": [{"file": "a.png"}, {"file": "`+
+		testData+`color_matrix.png"}, {"file": "b.gif"}]}`)
+	_, err := LoadSlideshow(filename)
+	if err == nil {
+		t.Fatal("expecting an error for missing files")
+	}
+	for _, f := range []string{"a.png", "b.gif"} {
+		if !strings.Contains(err.Error(), f) {
+			t.Errorf("expected %v to be listed in %q", f, err)
+		}
+	}
+}
+
+func TestSlideshowDraw(t *testing.T) {
+	abs, err := filepath.Abs(testData + "color_matrix.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	show, err := LoadSlideshow(writeManifest(t, `{"slides": [{"file": "`+abs+`", "caption": "first"}, `+
+		`{"file": "`+abs+`", "transition": "replace"}]}`))
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+
+	rc := &recordingCanvas{}
+	if err := show.Draw(rc, Image{LoopCount: 1, DelayMultiplier: 1, UserWidth: 8}); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	img := Image{Filename: abs, LoopCount: 1, DelayMultiplier: 1, UserWidth: 8}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if rc.count(`print "first"`) != 1 {
+		t.Error("expected the caption to be printed")
+	}
+	//The second slide replaces the first one and its caption.
+	if up := fmt.Sprintf("lineup %v", img.Rows()+1); rc.count(up) != 1 {
+		t.Errorf("expected a %q call, got %v", up, rc.calls)
+	}
+	if rc.count("close") != 1 {
+		t.Error("expected the canvas to be closed once")
+	}
+}
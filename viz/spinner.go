@@ -0,0 +1,91 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/codeliveroil/img/terminal"
+)
+
+// spinnerOutput returns the writer onto which Spinner is
+// drawn, nil if stderr is not a terminal.
+// This function can be overriden for test cases.
+var spinnerOutput = func() io.Writer {
+	if terminal.IsTerminal(os.Stderr) {
+		return os.Stderr
+	}
+	return nil
+}
+
+var spinnerInterval = 100 * time.Millisecond
+
+var spinnerChars = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// spinner draws a progress indicator on a line of its own
+// while Init prepares the frames (see Image.Spinner), turning
+// even when no frame is done (e.g. while decoding a GIF). It
+// is first drawn after spinnerInterval so that fast renders
+// don't flicker.
+type spinner struct {
+	w           io.Writer
+	mu          sync.Mutex
+	tick        int //number of times drawn
+	done, total int
+	stop        chan struct{}
+	stopped     chan struct{}
+}
+
+func startSpinner(w io.Writer) *spinner {
+	s := &spinner{w: w, stop: make(chan struct{}), stopped: make(chan struct{})}
+	go func() {
+		defer close(s.stopped)
+		t := time.NewTicker(spinnerInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-t.C:
+				s.draw()
+			}
+		}
+	}()
+	return s
+}
+
+// update records the number of frames prepared out of total.
+func (s *spinner) update(done, total int) {
+	s.mu.Lock()
+	s.done, s.total = done, total
+	s.mu.Unlock()
+}
+
+func (s *spinner) draw() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := spinnerChars[s.tick%len(spinnerChars)]
+	s.tick++
+	if s.total > 1 {
+		fmt.Fprintf(s.w, "\r%c preparing frames %v/%v", c, s.done, s.total)
+	} else {
+		fmt.Fprintf(s.w, "\r%c loading", c)
+	}
+}
+
+// close stops the spinner and clears its line if it was
+// drawn.
+func (s *spinner) close() {
+	close(s.stop)
+	<-s.stopped
+	if s.tick > 0 {
+		fmt.Fprint(s.w, "\r\033[K")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stats are performance statistics of the last Init and Draw
+// of an image, to diagnose slow renders.
+type Stats struct {
+	// Time spent by Init reading, decoding and compositing the frames, i.e. other than scaling them.
+	Decode time.Duration
+	// Time spent by Init scaling the frames and mapping them to terminal colors.
+	Scale time.Duration
+	// Number of frames prepared by Init, after merging identical consecutive frames.
+	Frames int
+	// Time spent by Draw, including the delays between frames, and the number of frames and bytes of
+	// text (including escape sequences) it drew.
+	Draw          time.Duration
+	FramesDrawn   int
+	BytesRendered int64
+	// Frame rates of animations: the one intended by the delays between the frames (within MaxFPS) and
+	// the one achieved by Draw, which is lower if the terminal can't keep pace. Zero for still images.
+	TargetFPS   float64
+	AchievedFPS float64
+}
+
+// Stats returns the statistics of the last Init and Draw.
+func (img *Image) Stats() Stats {
+	return img.stats
+}
+
+func (s Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "decode: %v\n", s.Decode.Round(time.Microsecond))
+	fmt.Fprintf(&b, "scale: %v\n", s.Scale.Round(time.Microsecond))
+	fmt.Fprintf(&b, "frames: %v (%v drawn)\n", s.Frames, s.FramesDrawn)
+	fmt.Fprintf(&b, "draw: %v\n", s.Draw.Round(time.Microsecond))
+	fmt.Fprintf(&b, "output: %v bytes", s.BytesRendered)
+	if s.TargetFPS > 0 {
+		fmt.Fprintf(&b, "\nfps: %.1f (target %.1f)", s.AchievedFPS, s.TargetFPS)
+	}
+	return b.String()
+}
+
+// countingCanvas counts the bytes of text drawn onto a canvas.
+type countingCanvas struct {
+	Canvas
+	n int64
+}
+
+func (cc *countingCanvas) Paint(topColor, bottomColor uint8) error {
+	cc.n += int64(len(makeTwoPixels(topColor, bottomColor)))
+	return cc.Canvas.Paint(topColor, bottomColor)
+}
+
+func (cc *countingCanvas) Print(text string) error {
+	cc.n += int64(len(text))
+	return printText(cc.Canvas, text)
+}
+
+func (cc *countingCanvas) NewLine() error {
+	cc.n++
+	return cc.Canvas.NewLine()
+}
+
+func (cc *countingCanvas) LineUp(count int) error {
+	cc.n += int64(len(fmt.Sprintf("\033[%dA", count)))
+	return cc.Canvas.LineUp(count)
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	img := initGIF(writeGIF(t, []uint8{0, 1, 2}, []int{2, 2, 2}, nil), t)
+	if s := img.Stats(); s.Frames != 3 || s.Scale <= 0 || s.Decode <= 0 {
+		t.Errorf("expected the times of Init for 3 frames, got %+v", s)
+	}
+	n, err := img.WriteTo(io.Discard)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	s := img.Stats()
+	if s.FramesDrawn != 3 || s.BytesRendered != n {
+		t.Errorf("expected 3 frames and %v bytes drawn, got %+v", n, s)
+	}
+	if s.TargetFPS != 50 || s.AchievedFPS <= 0 || s.AchievedFPS > 50 {
+		t.Errorf("expected a frame rate of up to 50 fps, got %+v", s)
+	}
+	if !strings.Contains(s.String(), "(target 50.0)") {
+		t.Errorf("expected the target frame rate in %q", s)
+	}
+}
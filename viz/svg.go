@@ -0,0 +1,144 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+// isSVG reports whether the file looks like an SVG document: an
+// <svg element near its start, after the XML declaration, the
+// doctype or comments.
+func isSVG(r io.ReadSeeker) (bool, error) {
+	head := make([]byte, 1024)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	head = bytes.TrimSpace(head[:n])
+	svg := bytes.HasPrefix(head, []byte("<")) && bytes.Contains(head, []byte("<svg"))
+	_, err = r.Seek(0, io.SeekStart)
+	return svg, err
+}
+
+// identifySVG returns the intrinsic dimensions of an SVG
+// document (see Identify and svgSize).
+func identifySVG(r io.Reader) (Info, error) {
+	var doc bytes.Buffer
+	icon, err := oksvg.ReadIconStream(io.TeeReader(r, &doc), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return Info{}, err
+	}
+	w, h := svgSize(doc.Bytes(), icon.ViewBox.W, icon.ViewBox.H)
+	return Info{Width: int(math.Ceil(w)), Height: int(math.Ceil(h)), Format: "svg", Frames: 1}, nil
+}
+
+// svgSize returns the intrinsic size of an SVG document: the
+// width and height attributes of its root element if they are
+// in pixels (with or without the px unit), the dimensions of
+// its viewBox (vw x vh) otherwise. A single attribute is
+// completed with the aspect ratio of the viewBox.
+func svgSize(doc []byte, vw, vh float64) (w, h float64) {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return vw, vh
+		}
+		root, ok := t.(xml.StartElement) //skipping the XML declaration, doctype and comments
+		if !ok {
+			continue
+		}
+		var aw, ah float64
+		for _, a := range root.Attr {
+			switch a.Name.Local {
+			case "width":
+				aw = pixels(a.Value)
+			case "height":
+				ah = pixels(a.Value)
+			}
+		}
+		switch {
+		case aw > 0 && ah > 0:
+			return aw, ah
+		case aw > 0 && vw > 0:
+			return aw, aw * vh / vw
+		case ah > 0 && vh > 0:
+			return ah * vw / vh, ah
+		}
+		return vw, vh
+	}
+}
+
+// pixels parses a length in pixels, returning 0 if it is in
+// another unit (e.g. a percentage) or invalid.
+func pixels(length string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(length), "px"), 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return v
+}
+
+// initSVG prepares the frame of an SVG document by rasterizing
+// it directly at the rendered size, for sharper edges than
+// scaling down a rasterized copy. The size is computed from the
+// width and height of the document (or its viewBox, see
+// svgSize), and the viewBox is centered in it keeping its
+// aspect ratio, as with the default preserveAspectRatio of
+// browsers. The document is a single, static frame.
+func (img *Image) initSVG(r io.Reader) error {
+	var doc bytes.Buffer
+	icon, err := oksvg.ReadIconStream(io.TeeReader(r, &doc), oksvg.IgnoreErrorMode) //unsupported elements are skipped
+	if err != nil {
+		return err
+	}
+	vb := icon.ViewBox
+	if vb.W <= 0 || vb.H <= 0 {
+		return errors.New("SVG has no viewBox or dimensions")
+	}
+	img.logf("decoded %v as svg", img.Filename)
+	img.format = "svg"
+
+	img.initBackground()
+	img.trim = image.Rectangle{} //rasterized at the rendered size, so there is nothing to crop
+	sw, sh := svgSize(doc.Bytes(), vb.W, vb.H)
+	iw, ih := int(math.Ceil(sw)), int(math.Ceil(sh))
+	if err := img.initSize(iw, ih, false); err != nil {
+		return err
+	}
+	img.frames = nil
+
+	//Rasterize the tile that is mirrored (see Mirror), whose pixels are scaled as is.
+	w, h := img.w-img.pad, img.h
+	if img.Mirror == MirrorHorizontal || img.Mirror == MirrorQuad {
+		w = (w + 1) / 2
+	}
+	if img.Mirror == MirrorVertical || img.Mirror == MirrorQuad {
+		h = (h + 1) / 2
+	}
+	scale := math.Min(float64(w)/vb.W, float64(h)/vb.H)
+	dw, dh := vb.W*scale, vb.H*scale
+	icon.SetTarget((float64(w)-dw)/2, (float64(h)-dh)/2, dw, dh)
+	canvas := img.newCanvas(w, h)
+	scanner := rasterx.NewScannerGV(w, h, canvas, canvas.Bounds())
+	icon.Draw(rasterx.NewDasher(w, h, scanner), 1)
+
+	img.initResampling(1, w, h)
+	img.appendImg(canvas, 0)
+	img.logf("prepared %v frame(s)", len(img.frames))
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSVG writes a 200x100 SVG document (with a 20x10
+// viewBox), red on the left and blue on the right.
+func writeSVG(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "halves.svg")
+	svg := `<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100" viewBox="0 0 20 10">
+  <rect x="0" y="0" width="10" height="10" fill="#ff0000"/>
+  <rect x="10" y="0" width="10" height="10" fill="#0000ff"/>
+</svg>`
+	if err := os.WriteFile(filename, []byte(svg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestSVG(t *testing.T) {
+	filename := writeSVG(t)
+	red, blue, white := uint8(Colors.Index(color.RGBA{255, 0, 0, 255})), uint8(Colors.Index(color.RGBA{0, 0, 255, 255})),
+		uint8(Colors.Index(color.White))
+	for _, tc := range []struct {
+		size Size
+		rows []int //rows drawn, the others showing Background
+	}{
+		{Size{Width: 8, Height: 4}, []int{0, 1, 2, 3}},
+		{Size{Width: 8, Height: 8}, []int{2, 3, 4, 5}}, //centered keeping the aspect ratio
+	} {
+		img := Image{Filename: filename, LoopCount: 1, DelayMultiplier: 1, Size: tc.size, Background: color.White}
+		if err := img.Init(); err != nil {
+			t.Fatal("expecting no error, got", err)
+		}
+		if img.Format() != "svg" {
+			t.Errorf("expected the svg format, got %q", img.Format())
+		}
+		pic := img.frames[0].picture
+		if len(pic) != tc.size.Width || len(pic[0]) != tc.size.Height {
+			t.Fatalf("%v: expected %vx%v pixels, got %vx%v", tc.size, tc.size.Width, tc.size.Height, len(pic), len(pic[0]))
+		}
+		drawn := make(map[int]bool)
+		for _, y := range tc.rows {
+			drawn[y] = true
+		}
+		for x := range pic {
+			for y, c := range pic[x] {
+				expected := white
+				switch {
+				case drawn[y] && x < tc.size.Width/2:
+					expected = red
+				case drawn[y]:
+					expected = blue
+				}
+				if c != expected { //rasterized at the rendered size, so the edges are sharp
+					t.Errorf("%v: pixel %v,%v: expected %v, got %v", tc.size, x, y, Colors[expected], Colors[c])
+				}
+			}
+		}
+	}
+
+	info, err := Identify(filename)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if expected := (Info{Width: 200, Height: 100, Format: "svg", Frames: 1}); info != expected {
+		t.Errorf("expected %v, got %v", expected, info)
+	}
+}
+
+func TestSVGSize(t *testing.T) {
+	for _, tc := range []struct {
+		attrs string
+		w, h  float64
+	}{
+		{`width="200" height="100"`, 200, 100},
+		{`width="200px" height="100px"`, 200, 100},
+		{`width="40"`, 40, 20}, //the aspect ratio of the viewBox
+		{`height="40"`, 80, 40},
+		{`width="100%" height="100%"`, 20, 10}, //not in pixels
+		{``, 20, 10},
+	} {
+		doc := `<?xml version="1.0"?><!-- icon --><svg xmlns="http://www.w3.org/2000/svg" ` + tc.attrs + ` viewBox="0 0 20 10"/>`
+		if w, h := svgSize([]byte(doc), 20, 10); w != tc.w || h != tc.h {
+			t.Errorf("%q: expected %vx%v, got %vx%v", tc.attrs, tc.w, tc.h, w, h)
+		}
+	}
+}
+
+func TestSVGStatic(t *testing.T) {
+	img := Image{Filename: writeSVG(t), LoopCount: LoopForever, DelayMultiplier: 1, UserWidth: 8}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if img.LoopCount != LoopForever {
+		t.Errorf("expected LoopCount to be left as is, got %v", img.LoopCount)
+	}
+	rc := &recordingCanvas{}
+	if err := img.Draw(rc); err != nil { //returns instead of looping forever
+		t.Fatal("expecting no error, got", err)
+	}
+	for _, call := range rc.calls {
+		if strings.HasPrefix(call, "lineup") {
+			t.Errorf("expected the document to be drawn once, got %v", rc.calls)
+			break
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// initTrim resolves the rectangle that the frames are cropped
+// to (see AutoTrim) from the first one, and returns the size of
+// the frames once cropped.
+func (img *Image) initTrim(first image.Image) (w, h int) {
+	img.trim = image.Rectangle{}
+	b := first.Bounds()
+	if img.AutoTrim {
+		if r := trimBounds(first, img.TrimTolerance); r != b {
+			img.trim = r.Sub(b.Min)
+			img.logf("trimmed the borders of the %vx%v image to %v", b.Dx(), b.Dy(), img.trim)
+			b = r
+		}
+	}
+	return b.Dx(), b.Dy()
+}
+
+// trimmed returns f cropped to the rectangle resolved by
+// initTrim, or f itself if not trimming.
+func (img *Image) trimmed(f image.Image) image.Image {
+	if img.trim.Empty() {
+		return f
+	}
+	r := img.trim.Add(f.Bounds().Min).Intersect(f.Bounds())
+	if s, ok := f.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	c := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(c, c.Bounds(), f, r.Min, draw.Src)
+	return c
+}
+
+// trimBounds returns the bounds of f without the rows and
+// columns on its edges whose pixels are all within tolerance of
+// the color of its top left pixel (compared by their Euclidean
+// distance in 8-bit RGBA), or its bounds if it's all uniform.
+func trimBounds(f image.Image, tolerance float64) image.Rectangle {
+	b := f.Bounds()
+	border := f.At(b.Min.X, b.Min.Y)
+	uniform := func(x0, y0, x1, y1 int) bool {
+		for x := x0; x < x1; x++ {
+			for y := y0; y < y1; y++ {
+				if colorDistance(f.At(x, y), border) > tolerance {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	r := b
+	for r.Min.Y < r.Max.Y && uniform(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1) {
+		r.Min.Y++
+	}
+	if r.Min.Y == r.Max.Y {
+		return b
+	}
+	for uniform(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y) {
+		r.Max.Y--
+	}
+	for uniform(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y) {
+		r.Min.X++
+	}
+	for uniform(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y) {
+		r.Max.X--
+	}
+	return r
+}
+
+// colorDistance returns the Euclidean distance between two
+// colors in 8-bit RGBA.
+func colorDistance(a, b color.Color) float64 {
+	ra, ga, ba, aa := a.RGBA()
+	rb, gb, bb, ab := b.RGBA()
+	var sum float64
+	for _, d := range []float64{
+		float64(ra>>8) - float64(rb>>8), float64(ga>>8) - float64(gb>>8),
+		float64(ba>>8) - float64(bb>>8), float64(aa>>8) - float64(ab>>8),
+	} {
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/codeliveroil/img/terminal"
+)
+
+// trueColor flags the colors of the pen that are 24-bit colors
+// (0xRRGGBB) rather than palette colors.
+const trueColor = 1 << 24
+
+// rgbColor returns c as a 24-bit color of the pen.
+func rgbColor(c color.Color) int32 {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return trueColor | int32(rgba.R)<<16 | int32(rgba.G)<<8 | int32(rgba.B)
+}
+
+// colorDepth returns the number of colors the image is drawn
+// with: 16 with BasicColors, terminal.TrueColor with TrueColor
+// and 256 otherwise.
+func (img *Image) colorDepth() int {
+	switch {
+	case img.TrueColor:
+		return terminal.TrueColor
+	case img.colorMode() == BasicColors:
+		return 16
+	default:
+		return 256
+	}
+}
+
+// trueColors returns the 24-bit colors of the scaled image,
+// after applying the filter (if not nil), padded with pad
+// columns of the background color bg.
+func trueColors(scaled image.Image, filter func(c color.Color) color.Color, pad int, bg color.Color) [][]int32 {
+	b := scaled.Bounds()
+	rgb := make([][]int32, b.Dx(), b.Dx()+pad)
+	for x := range rgb {
+		rgb[x] = make([]int32, b.Dy())
+		for y := range rgb[x] {
+			c := scaled.At(b.Min.X+x, b.Min.Y+y)
+			if filter != nil {
+				c = filter(c)
+			}
+			rgb[x][y] = rgbColor(c)
+		}
+	}
+	for x := 0; x < pad; x++ {
+		col := make([]int32, b.Dy())
+		for y := range col {
+			col[y] = rgbColor(bg)
+		}
+		rgb = append(rgb, col)
+	}
+	return rgb
+}
+
+// pixel returns the color of the pixel at x,y for the pen: its
+// 24-bit color if the frame has them, its palette color
+// otherwise.
+func (f frame) pixel(x, y int) int {
+	if f.rgb != nil {
+		return int(f.rgb[x][y])
+	}
+	return int(f.picture[x][y])
+}
+
+// averagePixel returns the color for the pen of the average of
+// the pixels at x,y and x,y+1 (see average).
+func (f frame) averagePixel(x, y int) int {
+	if f.rgb == nil {
+		return int(average(f.picture[x][y], f.picture[x][y+1]))
+	}
+	a, b := f.rgb[x][y], f.rgb[x][y+1]
+	avg := func(shift uint) int32 { return ((a>>shift&0xff + b>>shift&0xff + 1) / 2) << shift }
+	return int(trueColor | avg(16) | avg(8) | avg(0))
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+// Package viztest provides utilities for testing custom
+// implementations of viz.Canvas.
+package viztest
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/codeliveroil/img/viz"
+)
+
+// TestCanvas checks that the canvases returned by factory
+// honor the contract of viz.Canvas, by calling them as Draw
+// does and interpreting their output as a terminal would.
+// factory is called for every check and must return a new
+// canvas along with a function returning everything the canvas
+// has output (including escape sequences), which is called
+// after the canvas is closed.
+//
+// A canvas passes if Paint draws one character showing the two
+// pixels with the lower half block (▄), the upper half block
+// (▀), a full block or a space, if Print writes its text as is
+// (for canvases that are a viz.Printer), and if NewLine and
+// LineUp move the cursor as documented.
+func TestCanvas(t *testing.T, factory func() (canvas viz.Canvas, output func() []byte)) {
+	t.Run("Paint", func(t *testing.T) {
+		s := draw(t, factory, func(c viz.Canvas) error {
+			return calls(c.Paint(1, 2), c.Paint(3, 4), c.NewLine())
+		})
+		s.expectPixels(t, 0, 0, 1, 2)
+		s.expectPixels(t, 0, 1, 3, 4)
+		s.expectCursor(t, 1, 0)
+	})
+
+	t.Run("Print", func(t *testing.T) {
+		printer := true
+		s := draw(t, factory, func(c viz.Canvas) error {
+			p, ok := c.(viz.Printer)
+			if printer = ok; !ok {
+				return nil
+			}
+			return calls(p.Print("ab"), c.Paint(1, 2), p.Print("c"), c.NewLine())
+		})
+		if !printer {
+			t.Skip("the canvas isn't a viz.Printer")
+		}
+		s.expectRune(t, 0, 0, 'a')
+		s.expectRune(t, 0, 1, 'b')
+		s.expectPixels(t, 0, 2, 1, 2)
+		s.expectRune(t, 0, 3, 'c')
+		s.expectCursor(t, 1, 0)
+	})
+
+	t.Run("NewLine", func(t *testing.T) {
+		s := draw(t, factory, func(c viz.Canvas) error {
+			return calls(c.Paint(1, 2), c.Paint(3, 4), c.NewLine(), c.Paint(5, 6), c.NewLine())
+		})
+		s.expectPixels(t, 1, 0, 5, 6)
+		s.expectCursor(t, 2, 0)
+	})
+
+	t.Run("LineUp", func(t *testing.T) {
+		s := draw(t, factory, func(c viz.Canvas) error {
+			return calls(
+				c.Paint(1, 2), c.Paint(3, 4), c.NewLine(),
+				c.Paint(5, 6), c.Paint(7, 8), c.NewLine(),
+				c.LineUp(2), c.Sleep(0),
+				c.Paint(9, 10), c.NewLine(),
+			)
+		})
+		s.expectPixels(t, 0, 0, 9, 10)
+		s.expectPixels(t, 0, 1, 3, 4)
+		s.expectPixels(t, 1, 0, 5, 6)
+		s.expectCursor(t, 1, 0)
+	})
+}
+
+// calls returns the first error among the results of calls
+// made to a canvas.
+func calls(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// draw renders with f onto a new canvas, closes it and returns
+// the resulting screen.
+func draw(t *testing.T, factory func() (viz.Canvas, func() []byte), f func(c viz.Canvas) error) *screen {
+	c, output := factory()
+	if err := f(c); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal("expecting no error on close, got", err)
+	}
+	s := &screen{fg: -1, bg: -1}
+	s.write(string(output()))
+	return s
+}
+
+// cell is a character on the screen along with its colors
+// (-1 for the default colors).
+type cell struct {
+	r      rune
+	fg, bg int
+}
+
+// screen is a minimal terminal emulator supporting the escape
+// sequences that canvases output.
+type screen struct {
+	cells    [][]cell
+	row, col int
+	fg, bg   int
+}
+
+func (s *screen) write(out string) {
+	for len(out) > 0 {
+		if strings.HasPrefix(out, "\x1b[") {
+			end := strings.IndexFunc(out[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+			if end < 0 {
+				return
+			}
+			s.escape(out[2:2+end], out[2+end])
+			out = out[3+end:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(out)
+		switch r {
+		case '\r':
+			s.col = 0
+		case '\n':
+			s.row, s.col = s.row+1, 0
+		default:
+			s.put(r)
+		}
+		out = out[size:]
+	}
+}
+
+// escape interprets a control sequence with the specified
+// parameters and final byte.
+func (s *screen) escape(params string, final byte) {
+	if strings.HasPrefix(params, "?") {
+		return //private modes (e.g. hiding the cursor) don't matter
+	}
+	n, err := strconv.Atoi(params)
+	if err != nil || n == 0 {
+		n = 1
+	}
+	switch final {
+	case 'A':
+		if s.row -= n; s.row < 0 {
+			s.row = 0
+		}
+	case 'B':
+		s.row += n
+	case 'C':
+		s.col += n
+	case 'D':
+		if s.col -= n; s.col < 0 {
+			s.col = 0
+		}
+	case 'm':
+		s.sgr(strings.Split(params, ";"))
+	}
+}
+
+// sgr sets the colors according to the parameters of a select
+// graphic rendition sequence.
+func (s *screen) sgr(params []string) {
+	for i := 0; i < len(params); i++ {
+		switch params[i] {
+		case "", "0":
+			s.fg, s.bg = -1, -1
+		case "38", "48":
+			if i+2 >= len(params) || params[i+1] != "5" {
+				return
+			}
+			c, _ := strconv.Atoi(params[i+2])
+			if params[i] == "38" {
+				s.fg = c
+			} else {
+				s.bg = c
+			}
+			i += 2
+		}
+	}
+}
+
+func (s *screen) put(r rune) {
+	for len(s.cells) <= s.row {
+		s.cells = append(s.cells, nil)
+	}
+	for len(s.cells[s.row]) <= s.col {
+		s.cells[s.row] = append(s.cells[s.row], cell{' ', -1, -1})
+	}
+	s.cells[s.row][s.col] = cell{r, s.fg, s.bg}
+	s.col++
+}
+
+func (s *screen) at(t *testing.T, row, col int) (cell, bool) {
+	t.Helper()
+	if row >= len(s.cells) || col >= len(s.cells[row]) {
+		t.Errorf("expected a character at line %v, column %v, got none", row, col)
+		return cell{}, false
+	}
+	return s.cells[row][col], true
+}
+
+func (s *screen) expectRune(t *testing.T, row, col int, expected rune) {
+	t.Helper()
+	if c, ok := s.at(t, row, col); ok && c.r != expected {
+		t.Errorf("expected %q at line %v, column %v, got %q", expected, row, col, c.r)
+	}
+}
+
+func (s *screen) expectPixels(t *testing.T, row, col, top, bottom int) {
+	t.Helper()
+	c, ok := s.at(t, row, col)
+	if !ok {
+		return
+	}
+	var gotTop, gotBottom int
+	switch c.r {
+	case '▄':
+		gotTop, gotBottom = c.bg, c.fg
+	case '▀':
+		gotTop, gotBottom = c.fg, c.bg
+	case '█':
+		gotTop, gotBottom = c.fg, c.fg
+	case ' ':
+		gotTop, gotBottom = c.bg, c.bg
+	default:
+		t.Errorf("expected a block at line %v, column %v, got %q", row, col, c.r)
+		return
+	}
+	if gotTop != top || gotBottom != bottom {
+		t.Errorf("expected colors %v/%v at line %v, column %v, got %v/%v", top, bottom, row, col, gotTop, gotBottom)
+	}
+}
+
+func (s *screen) expectCursor(t *testing.T, row, col int) {
+	t.Helper()
+	if s.row != row || s.col != col {
+		t.Errorf("expected the cursor at line %v, column %v, got line %v, column %v", row, col, s.row, s.col)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viztest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeliveroil/img/viz"
+)
+
+func TestWriterCanvas(t *testing.T) {
+	TestCanvas(t, func() (viz.Canvas, func() []byte) {
+		var b bytes.Buffer
+		return viz.NewWriterCanvas(&b, 0), b.Bytes
+	})
+}
+
+func TestThrottledWriterCanvas(t *testing.T) {
+	TestCanvas(t, func() (viz.Canvas, func() []byte) {
+		var b bytes.Buffer
+		return viz.NewWriterCanvas(&b, 1<<20), b.Bytes
+	})
+}
+
+func TestMultiCanvas(t *testing.T) {
+	TestCanvas(t, func() (viz.Canvas, func() []byte) {
+		var a, b bytes.Buffer
+		return viz.NewMultiCanvas(viz.NewWriterCanvas(&a, 0), viz.NewWriterCanvas(&b, 0)), func() []byte {
+			if !bytes.Equal(a.Bytes(), b.Bytes()) {
+				t.Errorf("expected the canvases to get the same output, got %q and %q", a.Bytes(), b.Bytes())
+			}
+			return a.Bytes()
+		}
+	})
+}
+
+// failingCanvas fails every call.
+type failingCanvas struct{}
+
+var errCanvas = errors.New("canvas failure")
+
+func (failingCanvas) Paint(topColor, bottomColor uint8) error { return errCanvas }
+func (failingCanvas) NewLine() error                          { return errCanvas }
+func (failingCanvas) LineUp(count int) error                  { return errCanvas }
+func (failingCanvas) Sleep(delayMS int) error                 { return errCanvas }
+func (failingCanvas) Close() error                            { return errCanvas }
+
+// countingCanvas counts the calls of each method.
+type countingCanvas struct {
+	calls map[string]int
+}
+
+func (cc *countingCanvas) Paint(topColor, bottomColor uint8) error { cc.calls["Paint"]++; return nil }
+func (cc *countingCanvas) NewLine() error                          { cc.calls["NewLine"]++; return nil }
+func (cc *countingCanvas) LineUp(count int) error                  { cc.calls["LineUp"]++; return nil }
+func (cc *countingCanvas) Sleep(delayMS int) error                 { cc.calls["Sleep"]++; return nil }
+func (cc *countingCanvas) Close() error                            { cc.calls["Close"]++; return nil }
+
+func TestMultiCanvasFailure(t *testing.T) {
+	before, after := &countingCanvas{map[string]int{}}, &countingCanvas{map[string]int{}}
+	mc := viz.NewMultiCanvas(before, failingCanvas{}, after)
+	for name, call := range map[string]func() error{
+		"Paint":   func() error { return mc.Paint(1, 2) },
+		"NewLine": mc.NewLine,
+		"LineUp":  func() error { return mc.LineUp(1) },
+		"Sleep":   func() error { return mc.Sleep(0) },
+		"Close":   mc.Close,
+	} {
+		if err := call(); err != errCanvas {
+			t.Errorf("%v: expected %v, got %v", name, errCanvas, err)
+		}
+		for _, cc := range []*countingCanvas{before, after} {
+			if cc.calls[name] != 1 {
+				t.Errorf("%v: expected every canvas to be called once, got %v", name, cc.calls[name])
+			}
+		}
+	}
+}
+
+func TestWriterCanvasRate(t *testing.T) {
+	var b bytes.Buffer
+	wc := viz.NewWriterCanvas(&b, 1000)
+	start := time.Now()
+	if err := wc.Print(strings.Repeat("x", 300)); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	//The first chunk of 100 bytes is written right away.
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected writing 300 bytes at 1000 bytes/s to take at least 200ms, took %v", elapsed)
+	}
+	if b.Len() != 300 {
+		t.Errorf("expected 300 bytes, got %v", b.Len())
+	}
+}
+
+func TestCastCanvas(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	TestCanvas(t, func() (viz.Canvas, func() []byte) {
+		n++
+		filename := filepath.Join(dir, fmt.Sprintf("%v.cast", n))
+		cc, err := viz.NewCastCanvas(filename, 80, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cc, func() []byte {
+			//Concatenate the output of the events, skipping the header.
+			f, err := os.Open(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			var out bytes.Buffer
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				var event []interface{}
+				if json.Unmarshal(scanner.Bytes(), &event) == nil && len(event) == 3 {
+					out.WriteString(event[2].(string))
+				}
+			}
+			return out.Bytes()
+		}
+	})
+}
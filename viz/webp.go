@@ -0,0 +1,189 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	"golang.org/x/image/riff"
+	"golang.org/x/image/webp"
+)
+
+var errInvalidWebP = errors.New("invalid animated WebP")
+
+// webpAnimation is a decoded animated WebP. The standard
+// WebP decoder only handles still images, so the frames are
+// extracted from the ANMF chunks of the RIFF container and
+// decoded one by one.
+type webpAnimation struct {
+	width, height int
+	loopCount     int //0 to loop forever
+	frames        []webpFrame
+}
+
+// webpFrame is a frame of an animated WebP along with how it
+// is composited onto the canvas.
+type webpFrame struct {
+	image   image.Image
+	bounds  image.Rectangle //position on the canvas
+	delay   int             //milliseconds
+	blend   bool            //alpha-blend onto the canvas instead of replacing it
+	dispose bool            //clear the frame to the background once displayed
+}
+
+// isAnimatedWebP reports whether the file is an animated WebP
+// (a VP8X chunk with the animation flag) and rewinds it.
+func isAnimatedWebP(r io.ReadSeeker) (bool, error) {
+	var header [21]byte
+	n, err := io.ReadFull(r, header[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	const animationBit = 1 << 1
+	animated := n == len(header) &&
+		string(header[0:4]) == "RIFF" && string(header[8:16]) == "WEBPVP8X" &&
+		header[20]&animationBit != 0
+	_, err = r.Seek(0, io.SeekStart)
+	return animated, err
+}
+
+// decodeWebPAnimation decodes the frames of an animated WebP.
+// If maxFrames is not zero, it fails as soon as there are more
+// frames, before decoding them.
+func decodeWebPAnimation(r io.Reader, maxFrames int) (*webpAnimation, error) {
+	formType, chunks, err := riff.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	if formType != (riff.FourCC{'W', 'E', 'B', 'P'}) {
+		return nil, errInvalidWebP
+	}
+
+	a := &webpAnimation{}
+	for {
+		id, _, data, err := chunks.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(data)
+		if err != nil {
+			return nil, err
+		}
+		switch string(id[:]) {
+		case "VP8X":
+			if len(b) < 10 {
+				return nil, errInvalidWebP
+			}
+			a.width, a.height = u24(b[4:])+1, u24(b[7:])+1
+		case "ANIM":
+			if len(b) < 6 {
+				return nil, errInvalidWebP
+			}
+			a.loopCount = int(binary.LittleEndian.Uint16(b[4:]))
+		case "ANMF":
+			if a.width == 0 {
+				return nil, errInvalidWebP //the canvas must be specified first
+			}
+			if maxFrames > 0 && len(a.frames) == maxFrames {
+				return nil, fmt.Errorf("image has more than the maximum of %v frames", maxFrames)
+			}
+			f, err := decodeWebPFrame(b, image.Rect(0, 0, a.width, a.height))
+			if err != nil {
+				return nil, err
+			}
+			a.frames = append(a.frames, f)
+		}
+	}
+	if a.width == 0 || len(a.frames) == 0 {
+		return nil, errInvalidWebP
+	}
+	return a, nil
+}
+
+// decodeWebPFrame decodes the payload of an ANMF chunk: a
+// header positioning the frame, followed by the chunks of a
+// still image, which must lie within the canvas.
+func decodeWebPFrame(b []byte, canvas image.Rectangle) (webpFrame, error) {
+	if len(b) < 16+8 {
+		return webpFrame{}, errInvalidWebP
+	}
+	x, y := 2*u24(b[0:]), 2*u24(b[3:])
+	w, h := u24(b[6:])+1, u24(b[9:])+1
+	f := webpFrame{
+		bounds:  image.Rect(x, y, x+w, y+h),
+		delay:   u24(b[12:]),
+		blend:   b[15]&2 == 0,
+		dispose: b[15]&1 != 0,
+	}
+	if !f.bounds.In(canvas) {
+		return webpFrame{}, errInvalidWebP //and decoding it could exhaust memory
+	}
+
+	//Wrap the chunks in a WebP file of their own. An alpha
+	//channel must be announced by a VP8X chunk.
+	var still bytes.Buffer
+	still.WriteString("RIFF\x00\x00\x00\x00WEBP")
+	if string(b[16:20]) == "ALPH" {
+		const alphaBit = 1 << 4
+		vp8x := []byte{'V', 'P', '8', 'X', 10, 0, 0, 0, alphaBit, 0, 0, 0}
+		vp8x = append(vp8x, byte(w-1), byte((w-1)>>8), byte((w-1)>>16))
+		vp8x = append(vp8x, byte(h-1), byte((h-1)>>8), byte((h-1)>>16))
+		still.Write(vp8x)
+	}
+	still.Write(b[16:])
+	data := still.Bytes()
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+
+	cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return webpFrame{}, err
+	}
+	if cfg.Width != w || cfg.Height != h {
+		return webpFrame{}, errInvalidWebP
+	}
+	if f.image, err = webp.Decode(bytes.NewReader(data)); err != nil {
+		return webpFrame{}, err
+	}
+	return f, nil
+}
+
+// composite renders the frames onto a canvas filled with bg and
+// passes it to f after each frame, until f returns false.
+// Frames that don't blend replace the canvas underneath them,
+// showing bg through their transparent pixels.
+func (a *webpAnimation) composite(bg color.Color, f func(canvas image.Image, delayMS int) bool) {
+	canvas := image.NewRGBA(image.Rect(0, 0, a.width, a.height))
+	background := image.NewUniform(bg)
+	draw.Draw(canvas, canvas.Bounds(), background, image.ZP, draw.Src)
+	for _, frame := range a.frames {
+		r := frame.bounds.Intersect(canvas.Bounds())
+		if !frame.blend {
+			draw.Draw(canvas, r, background, image.ZP, draw.Src)
+		}
+		draw.Draw(canvas, r, frame.image, frame.image.Bounds().Min, draw.Over)
+		if !f(canvas, frame.delay) {
+			return
+		}
+		if frame.dispose {
+			draw.Draw(canvas, r, background, image.ZP, draw.Src)
+		}
+	}
+}
+
+// u24 decodes a 24 bit little endian number.
+func u24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2018 codeliveroil. All rights reserved.
+//
+// This work is licensed under the terms of the MIT license.
+// For a copy, see <https://opensource.org/licenses/MIT>.
+
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// bitWriter writes bits least significant first, as VP8L does.
+type bitWriter struct {
+	b     []byte
+	nbits uint
+}
+
+func (bw *bitWriter) write(v uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if bw.nbits%8 == 0 {
+			bw.b = append(bw.b, 0)
+		}
+		bw.b[len(bw.b)-1] |= byte(v>>i&1) << (bw.nbits % 8)
+		bw.nbits++
+	}
+}
+
+// solidVP8L returns a VP8L chunk of a w x h image filled with
+// c, where each prefix code has a single symbol so that pixels
+// take no bits.
+func solidVP8L(w, h int, c color.NRGBA) []byte {
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	bw.write(1, 1) //alpha is used
+	bw.write(0, 3) //version
+	bw.write(0, 1) //no transform
+	bw.write(0, 1) //no color cache
+	bw.write(0, 1) //no meta prefix codes
+	for _, symbol := range []uint8{c.G, c.R, c.B, c.A, 0} {
+		bw.write(1, 1) //simple code
+		bw.write(0, 1) //one symbol
+		bw.write(1, 1) //8 bit symbol
+		bw.write(uint32(symbol), 8)
+	}
+	return chunk("VP8L", bw.b)
+}
+
+func chunk(id string, data []byte) []byte {
+	b := append([]byte(id), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func u24Bytes(v int) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16)}
+}
+
+type testWebPFrame struct {
+	r       image.Rectangle
+	c       color.NRGBA
+	delay   int
+	noBlend bool
+	dispose bool
+}
+
+// encodeWebPAnimation returns an animated WebP of solid color
+// frames on a w x h canvas.
+func encodeWebPAnimation(w, h, loopCount int, frames []testWebPFrame) []byte {
+	vp8x := append([]byte{1<<1 | 1<<4, 0, 0, 0}, u24Bytes(w-1)...)
+	vp8x = append(vp8x, u24Bytes(h-1)...)
+	body := append([]byte("WEBP"), chunk("VP8X", vp8x)...)
+	body = append(body, chunk("ANIM", []byte{0, 0, 0, 0, byte(loopCount), byte(loopCount >> 8)})...)
+	for _, f := range frames {
+		var anmf []byte
+		anmf = append(anmf, u24Bytes(f.r.Min.X/2)...)
+		anmf = append(anmf, u24Bytes(f.r.Min.Y/2)...)
+		anmf = append(anmf, u24Bytes(f.r.Dx()-1)...)
+		anmf = append(anmf, u24Bytes(f.r.Dy()-1)...)
+		anmf = append(anmf, u24Bytes(f.delay)...)
+		var flags byte
+		if f.noBlend {
+			flags |= 2
+		}
+		if f.dispose {
+			flags |= 1
+		}
+		anmf = append(anmf, flags)
+		anmf = append(anmf, solidVP8L(f.r.Dx(), f.r.Dy(), f.c)...)
+		body = append(body, chunk("ANMF", anmf)...)
+	}
+	return chunk("RIFF", body)
+}
+
+var (
+	webpRed   = color.NRGBA{R: 255, A: 255}
+	webpBlue  = color.NRGBA{B: 255, A: 255}
+	webpClear = color.NRGBA{G: 255}
+)
+
+// testWebPFrames are a red canvas, then a blue square at the
+// bottom right that is disposed of to the background, then a
+// transparent square at the top left that replaces the canvas
+// underneath.
+var testWebPFrames = []testWebPFrame{
+	{r: image.Rect(0, 0, 4, 4), c: webpRed, delay: 100},
+	{r: image.Rect(2, 2, 4, 4), c: webpBlue, delay: 200, dispose: true},
+	{r: image.Rect(0, 0, 2, 2), c: webpClear, delay: 300, noBlend: true},
+}
+
+func TestWebPComposite(t *testing.T) {
+	a, err := decodeWebPAnimation(bytes.NewReader(encodeWebPAnimation(4, 4, 3, testWebPFrames)), 0)
+	if err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if a.width != 4 || a.height != 4 || a.loopCount != 3 || len(a.frames) != 3 {
+		t.Fatalf("unexpected animation %vx%v, loop count %v, %v frames", a.width, a.height, a.loopCount, len(a.frames))
+	}
+
+	black := color.RGBA{A: 255}
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	expected := [][2]color.RGBA{ //top left and bottom right pixels
+		{red, red},
+		{red, blue},
+		{black, black},
+	}
+	i := 0
+	a.composite(color.Black, func(canvas image.Image, delayMS int) bool {
+		c := canvas.(*image.RGBA)
+		if got := [2]color.RGBA{c.RGBAAt(0, 0), c.RGBAAt(3, 3)}; got != expected[i] {
+			t.Errorf("frame %v: expected %v, got %v", i, expected[i], got)
+		}
+		if delayMS != testWebPFrames[i].delay {
+			t.Errorf("frame %v: expected delay %v, got %v", i, testWebPFrames[i].delay, delayMS)
+		}
+		i++
+		return true
+	})
+	if i != len(expected) {
+		t.Errorf("expected %v frames, got %v", len(expected), i)
+	}
+}
+
+func TestWebPInit(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.webp")
+	if err := os.WriteFile(filename, encodeWebPAnimation(4, 4, 3, testWebPFrames), 0644); err != nil {
+		t.Fatal(err)
+	}
+	img := Image{Filename: filename, LoopCount: LoopAuto, DelayMultiplier: 1, UserWidth: 4}
+	if err := img.Init(); err != nil {
+		t.Fatal("expecting no error, got", err)
+	}
+	if img.LoopCount != 3 || len(img.frames) != 3 {
+		t.Errorf("expected 3 loops of 3 frames, got %v loops of %v frames", img.LoopCount, len(img.frames))
+	}
+}
+
+func TestWebPLimits(t *testing.T) {
+	outside := []testWebPFrame{{r: image.Rect(2, 2, 6, 6), c: webpRed}}
+	if _, err := decodeWebPAnimation(bytes.NewReader(encodeWebPAnimation(4, 4, 0, outside)), 0); err == nil {
+		t.Error("expecting an error for a frame outside the canvas")
+	}
+	if _, err := decodeWebPAnimation(bytes.NewReader(encodeWebPAnimation(4, 4, 0, testWebPFrames)), 2); err == nil {
+		t.Error("expecting an error for too many frames")
+	}
+}